@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AchievementFeedLimit caps the notifications returned at once
+const AchievementFeedLimit = 10
+
+// Achievement is a best or record set by an activity, kept until the device
+// acknowledges it
+type Achievement struct {
+	ID         int64   `json:"id"` // Pass it back to acknowledge
+	ActivityID string  `json:"activity_id"`
+	Date       string  `json:"date"` // Of the activity, YYYY-MM-DD
+	Type       string  `json:"type"` // intervals.icu type, e.g. BEST_POWER
+	Title      string  `json:"title"`
+	Message    string  `json:"message"` // As worded by intervals.icu
+	Watts      float64 `json:"watts,omitempty"`
+	Secs       float64 `json:"secs,omitempty"`
+	Value      float64 `json:"value,omitempty"`
+	DetectedAt string  `json:"detected_at"`
+}
+
+// AchievementFeed is the response for the achievements endpoint
+type AchievementFeed struct {
+	Unseen        int           `json:"unseen"` // All unacknowledged, may exceed the list
+	Notifications []Achievement `json:"notifications"`
+}
+
+// formatPRDuration formats a best effort duration for a headline:
+// "5s", "1-min", "20-min", "1-hour"
+func formatPRDuration(seconds float64) string {
+	secs := int(seconds + 0.5)
+	switch {
+	case secs < 60 || secs%60 != 0:
+		return fmt.Sprintf("%ds", secs)
+	case secs%3600 == 0:
+		return fmt.Sprintf("%d-hour", secs/3600)
+	}
+	return fmt.Sprintf("%d-min", secs/60)
+}
+
+// achievementTitle is the short headline shown on the device, e.g.
+// "New 20-min power PR: 312W"
+func achievementTitle(achievementType, message string, watts, secs float64) string {
+	if watts > 0 && secs > 0 && strings.Contains(achievementType, "POWER") {
+		return fmt.Sprintf("New %s power PR: %.0fW", formatPRDuration(secs), watts)
+	}
+	if message != "" {
+		return message
+	}
+	return "New achievement"
+}
+
+// recordAchievements stores the achievements of the activities, ignoring
+// those already stored, and returns how many are new. The first sync of an
+// athlete stores them as seen: they are a baseline, not news to announce.
+func recordAchievements(athleteID string, activities []TrainingActivity) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Stored in UTC so detected_at sorts and compares as text
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	// Achievements stored before syncs were tracked mean it is not the first
+	result, err := tx.Exec(`
+		INSERT OR IGNORE INTO achievement_syncs (athlete_id, first_synced_at)
+		SELECT ?, ? WHERE NOT EXISTS (SELECT 1 FROM achievements WHERE athlete_id = ?)
+	`, athleteID, now, athleteID)
+	if err != nil {
+		return 0, err
+	}
+	var seenAt any
+	if n, _ := result.RowsAffected(); n > 0 {
+		seenAt = now
+	}
+
+	added := 0
+	for _, a := range activities {
+		for i, achievement := range a.Achievements {
+			// Achievements are identified within their activity, by position
+			// when the provider gives no id
+			id := achievement.ID
+			if id == "" {
+				id = fmt.Sprintf("%d", i)
+			}
+			result, err := tx.Exec(`
+				INSERT OR IGNORE INTO achievements
+					(athlete_id, activity_id, achievement_id, activity_date, type, title, message, watts, secs, value, detected_at, seen_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			`, athleteID, a.ID, id, localDate(a.StartDateLocal), achievement.Type,
+				achievementTitle(achievement.Type, achievement.Message, achievement.Watts, achievement.Secs),
+				achievement.Message, achievement.Watts, achievement.Secs, achievement.Value, now, seenAt)
+			if err != nil {
+				return 0, err
+			}
+			if n, _ := result.RowsAffected(); n > 0 && seenAt == nil {
+				log.Printf("New achievement on %s: %s", a.ID, achievement.Message)
+				added++
+			}
+		}
+	}
+	if seenAt != nil {
+		log.Printf("First sync of %s, existing achievements stored as seen", athleteID)
+	}
+
+	return added, tx.Commit()
+}
+
+// GetUnseenAchievements returns up to limit unacknowledged achievements of
+// an athlete, newest activity first, and how many are unacknowledged in all.
+// An empty athleteID matches any athlete.
+func GetUnseenAchievements(athleteID string, limit int) ([]Achievement, int, error) {
+	var unseen int
+	err := db.QueryRow(`
+		SELECT COUNT(*) FROM achievements
+		WHERE (? = '' OR athlete_id = ?) AND seen_at IS NULL
+	`, athleteID, athleteID).Scan(&unseen)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	achievements, err := queryAchievements(`
+		SELECT id, activity_id, activity_date, type, title, message, watts, secs, value, detected_at
+		FROM achievements
+		WHERE (? = '' OR athlete_id = ?) AND seen_at IS NULL
+		ORDER BY activity_date DESC, id
+		LIMIT ?
+	`, athleteID, athleteID, limit)
+	return achievements, unseen, err
+}
+
+// GetPowerAchievements returns up to limit power achievements of an athlete,
+// seen or not, newest activity first. An empty athleteID matches any athlete.
+func GetPowerAchievements(athleteID string, limit int) ([]Achievement, error) {
+	return queryAchievements(`
+		SELECT id, activity_id, activity_date, type, title, message, watts, secs, value, detected_at
+		FROM achievements
+		WHERE (? = '' OR athlete_id = ?) AND watts > 0
+		ORDER BY activity_date DESC, id
+		LIMIT ?
+	`, athleteID, athleteID, limit)
+}
+
+// queryAchievements runs a query selecting the columns of Achievement
+func queryAchievements(query string, args ...any) ([]Achievement, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	achievements := []Achievement{}
+	for rows.Next() {
+		var a Achievement
+		if err := rows.Scan(&a.ID, &a.ActivityID, &a.Date, &a.Type, &a.Title, &a.Message,
+			&a.Watts, &a.Secs, &a.Value, &a.DetectedAt); err != nil {
+			return nil, err
+		}
+		achievements = append(achievements, a)
+	}
+	return achievements, rows.Err()
+}
+
+// AcknowledgeAchievements marks the achievements of an athlete with the ids
+// as seen, and returns how many were marked
+func AcknowledgeAchievements(athleteID string, ids []int64) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	args := []any{time.Now().UTC().Format(time.RFC3339), athleteID, athleteID}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	return execCount(`
+		UPDATE achievements SET seen_at = ?
+		WHERE (? = '' OR athlete_id = ?) AND seen_at IS NULL AND id IN (?`+strings.Repeat(", ?", len(ids)-1)+`)
+	`, args...)
+}
+
+// AcknowledgeAllAchievements marks every unseen achievement of an athlete as
+// seen, and returns how many were marked
+func AcknowledgeAllAchievements(athleteID string) (int, error) {
+	return execCount(`
+		UPDATE achievements SET seen_at = ?
+		WHERE (? = '' OR athlete_id = ?) AND seen_at IS NULL
+	`, time.Now().UTC().Format(time.RFC3339), athleteID, athleteID)
+}
+
+// execCount runs a statement and returns how many rows it changed
+func execCount(query string, args ...any) (int, error) {
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// handleAchievements returns the unacknowledged achievements, for the device
+// to show each once
+func handleAchievements(w http.ResponseWriter, r *http.Request) {
+	// Without credentials the achievements of any athlete are served
+	athleteID := trainingAthleteID()
+
+	achievements, unseen, err := GetUnseenAchievements(athleteID, AchievementFeedLimit)
+	if err != nil {
+		log.Printf("Error reading achievements: %v", err)
+		http.Error(w, "Failed to read achievements", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(AchievementFeed{Unseen: unseen, Notifications: achievements})
+	log.Printf("[%s] GET /api/intervals/achievements -> %d unseen",
+		time.Now().Format("15:04:05"), unseen)
+}
+
+// handleAcknowledgeAchievements marks the achievements of {"ids": [...]} as
+// seen, or all of them with {"all": true}
+func handleAcknowledgeAchievements(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Acknowledging everything must be asked for, a truncated or empty body
+	// would otherwise clear the whole feed
+	var request struct {
+		IDs []int64 `json:"ids"`
+		All bool    `json:"all"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid acknowledgement: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.All == (len(request.IDs) > 0) {
+		http.Error(w, `acknowledgement needs either "ids" or "all": true`, http.StatusBadRequest)
+		return
+	}
+
+	athleteID := trainingAthleteID()
+
+	var (
+		n   int
+		err error
+	)
+	if request.All {
+		n, err = AcknowledgeAllAchievements(athleteID)
+	} else {
+		n, err = AcknowledgeAchievements(athleteID, request.IDs)
+	}
+	if err != nil {
+		log.Printf("Error acknowledging achievements: %v", err)
+		http.Error(w, "Failed to acknowledge achievements", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]int{"acknowledged": n})
+	log.Printf("[%s] POST /api/intervals/achievements/ack -> %d acknowledged",
+		time.Now().Format("15:04:05"), n)
+}
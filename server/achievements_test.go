@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAchievementTitle(t *testing.T) {
+	tests := []struct {
+		achievementType string
+		message         string
+		watts, secs     float64
+		want            string
+	}{
+		{"BEST_POWER", "Season best 20m: 312w", 312, 1200, "New 20-min power PR: 312W"},
+		{"BEST_POWER", "", 905.6, 5, "New 5s power PR: 906W"},
+		{"BEST_POWER", "", 250, 3600, "New 1-hour power PR: 250W"},
+		{"BEST_POWER", "", 400, 90, "New 90s power PR: 400W"},
+		{"LONGEST_RIDE", "Longest ride this season", 0, 0, "Longest ride this season"},
+		{"BEST_PACE", "", 0, 0, "New achievement"},
+	}
+	for _, tt := range tests {
+		if got := achievementTitle(tt.achievementType, tt.message, tt.watts, tt.secs); got != tt.want {
+			t.Errorf("achievementTitle(%s, %q, %v, %v) = %q, want %q",
+				tt.achievementType, tt.message, tt.watts, tt.secs, got, tt.want)
+		}
+	}
+}
+
+func getAchievements(t *testing.T) AchievementFeed {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handleAchievements(rec, httptest.NewRequest("GET", "/api/intervals/achievements", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+
+	var feed AchievementFeed
+	if err := json.NewDecoder(rec.Body).Decode(&feed); err != nil {
+		t.Fatal(err)
+	}
+	return feed
+}
+
+func acknowledgeAchievements(t *testing.T, body string) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handleAcknowledgeAchievements(rec, httptest.NewRequest("POST", "/api/intervals/achievements/ack", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+
+	var response map[string]int
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	return response["acknowledged"]
+}
+
+func TestAchievementFeed(t *testing.T) {
+	useIntervalsFixtures(t)
+	if err := refreshIntervals(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first sync is a baseline, the past achievements are not news
+	if feed := getAchievements(t); feed.Unseen != 0 || len(feed.Notifications) != 0 {
+		t.Fatalf("after first sync feed = %+v", feed)
+	}
+
+	// Achievements of activities synced later are
+	day, err := GetLatestIntervals("i42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	activities := day.Activities
+	for i := range activities {
+		activities[i].ID += "-new"
+	}
+	if n, err := recordAchievements("i42", activities); err != nil || n != 2 {
+		t.Fatalf("recorded %d new achievements: %v", n, err)
+	}
+
+	feed := getAchievements(t)
+	if feed.Unseen != 2 || len(feed.Notifications) != 2 {
+		t.Fatalf("feed = %+v", feed)
+	}
+	pr := feed.Notifications[0]
+	if pr.ActivityID != "i103-new" || pr.Date != "2026-02-01" || pr.Title != "New 5-min power PR: 318W" {
+		t.Errorf("notification = %+v", pr)
+	}
+
+	// A later sync of the same activities brings nothing new
+	if err := refreshIntervals(); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := recordAchievements("i42", activities); err != nil || n != 0 {
+		t.Errorf("resync recorded %d: %v", n, err)
+	}
+	if feed := getAchievements(t); feed.Unseen != 2 {
+		t.Errorf("after resync unseen = %d, want 2", feed.Unseen)
+	}
+
+	// Acknowledged notifications are not shown again
+	if n := acknowledgeAchievements(t, fmt.Sprintf(`{"ids": [%d]}`, pr.ID)); n != 1 {
+		t.Errorf("acknowledged %d, want 1", n)
+	}
+	feed = getAchievements(t)
+	if feed.Unseen != 1 || feed.Notifications[0].ID == pr.ID {
+		t.Errorf("feed = %+v", feed)
+	}
+
+	// Clearing the feed must be asked for explicitly
+	for _, body := range []string{"", "{}", `{"ids": []}`, `{"all": false}`, fmt.Sprintf(`{"ids": [%d], "all": true}`, pr.ID)} {
+		rec := httptest.NewRecorder()
+		handleAcknowledgeAchievements(rec, httptest.NewRequest("POST", "/api/intervals/achievements/ack", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: status %d, want 400", body, rec.Code)
+		}
+	}
+	if feed := getAchievements(t); feed.Unseen != 1 {
+		t.Errorf("after rejected acknowledgements unseen = %d, want 1", feed.Unseen)
+	}
+
+	if n := acknowledgeAchievements(t, `{"all": true}`); n != 1 {
+		t.Errorf("acknowledged all %d, want 1", n)
+	}
+	if feed := getAchievements(t); feed.Unseen != 0 || len(feed.Notifications) != 0 {
+		t.Errorf("feed = %+v", feed)
+	}
+
+	rec := httptest.NewRecorder()
+	handleAcknowledgeAchievements(rec, httptest.NewRequest("GET", "/api/intervals/achievements/ack", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status %d, want 405", rec.Code)
+	}
+}
+
+func TestRecordAchievementsFirstSync(t *testing.T) {
+	setupTestDB(t)
+
+	// An athlete without achievements yet: the next ones are news
+	if n, err := recordAchievements("i42", nil); err != nil || n != 0 {
+		t.Fatalf("first sync recorded %d: %v", n, err)
+	}
+	var activity Activity
+	if err := json.Unmarshal([]byte(`{"id": "i1", "start_date_local": "2026-02-03T08:00:00",
+		"icu_achievements": [{"id": "ps1m", "type": "BEST_POWER", "message": "Season best 1m: 455w", "watts": 455, "secs": 60}]}`), &activity); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := recordAchievements("i42", []TrainingActivity{activity.trainingActivity()}); err != nil || n != 1 {
+		t.Errorf("second sync recorded %d: %v", n, err)
+	}
+
+	// Each athlete has their own baseline
+	if n, err := recordAchievements("i43", []TrainingActivity{activity.trainingActivity()}); err != nil || n != 0 {
+		t.Errorf("other athlete's first sync recorded %d: %v", n, err)
+	}
+	if _, unseen, err := GetUnseenAchievements("", 10); err != nil || unseen != 1 {
+		t.Errorf("unseen = %d: %v", unseen, err)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const openMeteoAirQualityBaseURL = "https://air-quality-api.open-meteo.com/v1"
+
+// Air quality levels, from best to worst
+const (
+	LevelGood     = "good"
+	LevelModerate = "moderate"
+	LevelPoor     = "poor"
+)
+
+// Level colors (RGB565) so the device can color-code values without a table
+var levelColors = map[string]uint16{
+	LevelGood:     0x07E0, // Green
+	LevelModerate: 0xFD20, // Orange
+	LevelPoor:     0xF800, // Red
+}
+
+var levelRank = map[string]int{
+	LevelGood:     1,
+	LevelModerate: 2,
+	LevelPoor:     3,
+}
+
+// airQualityBand holds the upper bounds of the good and moderate levels;
+// anything above moderate is poor
+type airQualityBand struct {
+	Good     float64
+	Moderate float64
+}
+
+// Bands follow the European AQI: "good" and "fair" are reported as good,
+// "poor" and worse as poor. Pollen is in grains/m³.
+var (
+	bandAQI   = airQualityBand{Good: 40, Moderate: 60}
+	bandPM25  = airQualityBand{Good: 20, Moderate: 25}   // µg/m³
+	bandPM10  = airQualityBand{Good: 40, Moderate: 50}   // µg/m³
+	bandOzone = airQualityBand{Good: 100, Moderate: 130} // µg/m³
+	bandGrass = airQualityBand{Good: 10, Moderate: 50}
+	bandBirch = airQualityBand{Good: 10, Moderate: 100}
+)
+
+// level returns the level of value within the band
+func (b airQualityBand) level(value float64) string {
+	switch {
+	case value <= b.Good:
+		return LevelGood
+	case value <= b.Moderate:
+		return LevelModerate
+	default:
+		return LevelPoor
+	}
+}
+
+// AirQuality represents the response for the air quality endpoint
+type AirQuality struct {
+	Location string  `json:"location"`
+	Time     string  `json:"time"` // Local time of the reading, "15:04"
+	AQI      int     `json:"aqi"`  // European AQI
+	PM25     float64 `json:"pm2_5"`
+	PM10     float64 `json:"pm10"`
+	Ozone    float64 `json:"ozone"`
+
+	// Pollen is only modelled in Europe and during the season, null otherwise
+	GrassPollen *float64 `json:"grass_pollen"`
+	BirchPollen *float64 `json:"birch_pollen"`
+
+	Level  string           `json:"level"` // Worst of all levels
+	Color  uint16           `json:"color"` // RGB565 color of Level
+	Levels AirQualityLevels `json:"levels"`
+}
+
+// AirQualityLevels holds the level of each reading
+type AirQualityLevels struct {
+	AQI   string `json:"aqi"`
+	PM25  string `json:"pm2_5"`
+	PM10  string `json:"pm10"`
+	Ozone string `json:"ozone"`
+	Grass string `json:"grass_pollen,omitempty"`
+	Birch string `json:"birch_pollen,omitempty"`
+}
+
+// classify fills in the levels and the overall level from the readings
+func (aq *AirQuality) classify() {
+	aq.Levels = AirQualityLevels{
+		AQI:   bandAQI.level(float64(aq.AQI)),
+		PM25:  bandPM25.level(aq.PM25),
+		PM10:  bandPM10.level(aq.PM10),
+		Ozone: bandOzone.level(aq.Ozone),
+	}
+	if aq.GrassPollen != nil {
+		aq.Levels.Grass = bandGrass.level(*aq.GrassPollen)
+	}
+	if aq.BirchPollen != nil {
+		aq.Levels.Birch = bandBirch.level(*aq.BirchPollen)
+	}
+
+	aq.Level = LevelGood
+	for _, level := range []string{aq.Levels.AQI, aq.Levels.PM25, aq.Levels.PM10, aq.Levels.Ozone, aq.Levels.Grass, aq.Levels.Birch} {
+		if levelRank[level] > levelRank[aq.Level] {
+			aq.Level = level
+		}
+	}
+	aq.Color = levelColors[aq.Level]
+}
+
+// AirQualityProvider fetches current air quality and pollen for a location
+type AirQualityProvider interface {
+	Name() string
+	GetAirQuality(loc Location) (*AirQuality, error)
+}
+
+// airQualityProvider is the provider used by the air quality handler
+var airQualityProvider AirQualityProvider = newOpenMeteoAirQualityProvider()
+
+// newAirQualityProvider returns the provider registered under name
+func newAirQualityProvider(name string) (AirQualityProvider, error) {
+	switch name {
+	case "", ProviderOpenMeteo:
+		return newOpenMeteoAirQualityProvider(), nil
+	case ProviderFake:
+		return newFakeAirQualityProvider(), nil
+	default:
+		return nil, fmt.Errorf("unknown air quality provider %q", name)
+	}
+}
+
+// OpenMeteoAirQualityResponse structure for parsing the air quality API response
+type OpenMeteoAirQualityResponse struct {
+	Current struct {
+		Time        string   `json:"time"`
+		EuropeanAQI float64  `json:"european_aqi"`
+		PM25        float64  `json:"pm2_5"`
+		PM10        float64  `json:"pm10"`
+		Ozone       float64  `json:"ozone"`
+		GrassPollen *float64 `json:"grass_pollen"`
+		BirchPollen *float64 `json:"birch_pollen"`
+	} `json:"current"`
+}
+
+// openMeteoAirQualityProvider fetches air quality from air-quality-api.open-meteo.com
+type openMeteoAirQualityProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+func newOpenMeteoAirQualityProvider() *openMeteoAirQualityProvider {
+	return &openMeteoAirQualityProvider{
+		baseURL: openMeteoAirQualityBaseURL,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *openMeteoAirQualityProvider) Name() string {
+	return ProviderOpenMeteo
+}
+
+func (p *openMeteoAirQualityProvider) GetAirQuality(loc Location) (*AirQuality, error) {
+	params := url.Values{}
+	params.Set("latitude", fmt.Sprintf("%.4f", loc.Latitude))
+	params.Set("longitude", fmt.Sprintf("%.4f", loc.Longitude))
+	params.Set("timezone", loc.Timezone)
+	params.Set("current", "european_aqi,pm2_5,pm10,ozone,grass_pollen,birch_pollen")
+
+	resp, err := p.client.Get(p.baseURL + "/air-quality?" + params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch air quality: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("open-meteo air quality request failed with status %d", resp.StatusCode)
+	}
+
+	var om OpenMeteoAirQualityResponse
+	if err := json.NewDecoder(resp.Body).Decode(&om); err != nil {
+		return nil, fmt.Errorf("failed to decode air quality: %w", err)
+	}
+
+	return om.toAirQuality(), nil
+}
+
+// toAirQuality converts the current block into the device payload
+func (om *OpenMeteoAirQualityResponse) toAirQuality() *AirQuality {
+	c := om.Current
+	aq := &AirQuality{
+		Time:        clockTime(c.Time),
+		AQI:         int(c.EuropeanAQI + 0.5),
+		PM25:        c.PM25,
+		PM10:        c.PM10,
+		Ozone:       c.Ozone,
+		GrassPollen: c.GrassPollen,
+		BirchPollen: c.BirchPollen,
+	}
+	aq.classify()
+	return aq
+}
+
+// airQualitySource returns the cache key and upstream call for the air
+// quality at loc
+func airQualitySource(loc Location) (string, func() (*AirQuality, error)) {
+	return "air:" + loc.Key, func() (*AirQuality, error) {
+		return airQualityProvider.GetAirQuality(loc)
+	}
+}
+
+// handleAirQuality returns the current air quality and pollen for ?loc=
+func handleAirQuality(w http.ResponseWriter, r *http.Request) {
+	loc, err := config.GetLocation(r.URL.Query().Get("loc"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	aq, info, err := fetchWeatherCached(airQualitySource(loc))
+	if err != nil {
+		log.Printf("Error fetching air quality from %s: %v", airQualityProvider.Name(), err)
+		http.Error(w, "Failed to fetch air quality", http.StatusInternalServerError)
+		return
+	}
+	aq.Location = loc.Name
+
+	writeCacheHeaders(w, info)
+	json.NewEncoder(w).Encode(aq)
+	log.Printf("[%s] GET /api/weather/air -> AQI %d (%s) for %s",
+		time.Now().Format("15:04:05"), aq.AQI, aq.Level, loc.Name)
+}
@@ -0,0 +1,43 @@
+package main
+
+import "sync/atomic"
+
+// fakeAirQualityProvider returns canned air quality, for tests and offline
+// development ("air_quality_provider": "fake")
+type fakeAirQualityProvider struct {
+	AirQuality AirQuality
+	Err        error        // Returned instead of data when set
+	Calls      atomic.Int64 // For tests, atomic as the scheduler calls it concurrently
+}
+
+func newFakeAirQualityProvider() *fakeAirQualityProvider {
+	grass, birch := 24.0, 0.0
+
+	aq := AirQuality{
+		Time:        "14:00",
+		AQI:         32,
+		PM25:        8.1,
+		PM10:        14.2,
+		Ozone:       61,
+		GrassPollen: &grass,
+		BirchPollen: &birch,
+	}
+	aq.classify()
+
+	return &fakeAirQualityProvider{AirQuality: aq}
+}
+
+func (p *fakeAirQualityProvider) Name() string {
+	return ProviderFake
+}
+
+func (p *fakeAirQualityProvider) GetAirQuality(loc Location) (*AirQuality, error) {
+	p.Calls.Add(1)
+	if p.Err != nil {
+		return nil, p.Err
+	}
+
+	aq := p.AirQuality
+	aq.Location = loc.Name
+	return &aq, nil
+}
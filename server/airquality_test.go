@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func useFakeAirQuality(t *testing.T) *fakeAirQualityProvider {
+	t.Helper()
+	setupTestDB(t)
+
+	fake := newFakeAirQualityProvider()
+	previous := airQualityProvider
+	airQualityProvider = fake
+	t.Cleanup(func() { airQualityProvider = previous })
+
+	return fake
+}
+
+func TestAirQualityClassify(t *testing.T) {
+	grass := 60.0
+
+	aq := AirQuality{AQI: 45, PM25: 12, PM10: 30, Ozone: 80, GrassPollen: &grass}
+	aq.classify()
+
+	want := AirQualityLevels{AQI: LevelModerate, PM25: LevelGood, PM10: LevelGood, Ozone: LevelGood, Grass: LevelPoor}
+	if aq.Levels != want {
+		t.Errorf("levels = %+v, want %+v", aq.Levels, want)
+	}
+	if aq.Level != LevelPoor || aq.Color != levelColors[LevelPoor] {
+		t.Errorf("overall = %s (%#04x), want poor", aq.Level, aq.Color)
+	}
+
+	clean := AirQuality{AQI: 15, PM25: 3, PM10: 6, Ozone: 40}
+	clean.classify()
+	if clean.Level != LevelGood || clean.Levels.Grass != "" {
+		t.Errorf("clean air = %+v, want good without pollen levels", clean)
+	}
+}
+
+func TestOpenMeteoAirQuality(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/air-quality" || r.URL.Query().Get("timezone") != "Europe/Paris" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`{"current": {"time": "2026-10-16T14:00", "european_aqi": 57.6, "pm2_5": 22.4,
+			"pm10": 31.0, "ozone": 72.5, "grass_pollen": null, "birch_pollen": 0.0}}`))
+	}))
+	defer server.Close()
+
+	provider := newOpenMeteoAirQualityProvider()
+	provider.baseURL = server.URL
+
+	aq, err := provider.GetAirQuality(Location{Name: "Aix", Timezone: "Europe/Paris"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if aq.Time != "14:00" || aq.AQI != 58 || aq.PM25 != 22.4 {
+		t.Errorf("unexpected readings: %+v", aq)
+	}
+	if aq.GrassPollen != nil || aq.BirchPollen == nil || aq.Levels.Birch != LevelGood {
+		t.Errorf("unexpected pollen: %+v", aq)
+	}
+	if aq.Level != LevelModerate {
+		t.Errorf("level = %s, want moderate", aq.Level)
+	}
+}
+
+func TestHandleAirQuality(t *testing.T) {
+	fake := useFakeAirQuality(t)
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		handleAirQuality(rec, httptest.NewRequest("GET", "/api/weather/air", nil))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+		}
+
+		var aq AirQuality
+		if err := json.Unmarshal(rec.Body.Bytes(), &aq); err != nil {
+			t.Fatal(err)
+		}
+		if aq.Location != "Aix-les-Bains" || aq.Level != LevelModerate {
+			t.Errorf("unexpected air quality: %+v", aq)
+		}
+	}
+
+	if fake.Calls.Load() != 1 {
+		t.Errorf("provider called %d times, want 1 (second request cached)", fake.Calls.Load())
+	}
+}
+
+func TestHandleAirQualityError(t *testing.T) {
+	fake := useFakeAirQuality(t)
+	fake.Err = errors.New("upstream down")
+
+	rec := httptest.NewRecorder()
+	handleAirQuality(rec, httptest.NewRequest("GET", "/api/weather/air?loc=nowhere", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown location status = %d, want 400", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handleAirQuality(rec, httptest.NewRequest("GET", "/api/weather/air", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Alert types
+const (
+	AlertThunderstorm = "thunderstorm"
+	AlertWind         = "wind"
+	AlertFreeze       = "freeze"
+	AlertHeavyPrecip  = "heavy_precip"
+)
+
+// Alert severities, from least to most severe
+const (
+	SeverityAdvisory = "advisory"
+	SeverityWarning  = "warning"
+	SeveritySevere   = "severe"
+)
+
+// AlertRules configures when weather alerts are raised. A zero threshold
+// disables the rule.
+type AlertRules struct {
+	ThunderstormHours int      `json:"thunderstorm_hours"` // Look-ahead for storm codes
+	LookaheadHours    int      `json:"lookahead_hours"`    // Look-ahead for the other rules
+	WindKmh           float64  `json:"wind_kmh"`
+	FreezingTemp      *float64 `json:"freezing_temp"`   // °C, null disables
+	HeavyPrecipMm     float64  `json:"heavy_precip_mm"` // mm in one hour
+}
+
+// Alert is an active weather alert, short enough to render as a banner
+type Alert struct {
+	Type     string  `json:"type"`
+	Severity string  `json:"severity"`
+	Message  string  `json:"message"`
+	Start    string  `json:"start"` // Local "15:04" of the first affected hour
+	Value    float64 `json:"value"` // Peak value in the response units; WMO code for storms
+}
+
+// AlertsResponse is the response for the alerts endpoint
+type AlertsResponse struct {
+	Location string     `json:"location"`
+	Alerts   []Alert    `json:"alerts"`
+	Units    UnitLabels `json:"units"`
+}
+
+var severityRank = map[string]int{
+	SeverityAdvisory: 1,
+	SeverityWarning:  2,
+	SeveritySevere:   3,
+}
+
+// EvaluateAlerts applies the rules to the hourly forecast and returns the
+// active alerts, most severe first. Each rule raises at most one alert, for
+// its peak hour. Rules are in metric; values and messages are in units.
+func EvaluateAlerts(rules AlertRules, hours []HourlyWeather, now time.Time, zone *time.Location, units Units) []Alert {
+	within := func(h HourlyWeather, lookahead int) bool {
+		return h.Time.Before(now.Add(time.Duration(lookahead) * time.Hour))
+	}
+	start := func(h HourlyWeather) string {
+		return h.Time.In(zone).Format("15:04")
+	}
+
+	var alerts []Alert
+
+	if rules.ThunderstormHours > 0 {
+		for _, h := range hoursFrom(hours, now) {
+			if !within(h, rules.ThunderstormHours) {
+				break
+			}
+			if h.Code != 95 && h.Code != 96 && h.Code != 99 {
+				continue
+			}
+
+			alert := Alert{
+				Type:     AlertThunderstorm,
+				Severity: SeverityWarning,
+				Message:  "Storm from " + start(h),
+				Start:    start(h),
+				Value:    float64(h.Code),
+			}
+			if h.Code != 95 {
+				alert.Severity = SeveritySevere
+				alert.Message = "Storm with hail from " + start(h)
+			}
+			alerts = append(alerts, alert)
+			break
+		}
+	}
+
+	var windPeak, coldPeak, precipPeak *HourlyWeather
+	for _, h := range hoursFrom(hours, now) {
+		if !within(h, rules.LookaheadHours) {
+			break
+		}
+		if rules.WindKmh > 0 && h.WindSpeed >= rules.WindKmh && (windPeak == nil || h.WindSpeed > windPeak.WindSpeed) {
+			windPeak = &h
+		}
+		if rules.FreezingTemp != nil && h.Temp <= *rules.FreezingTemp && (coldPeak == nil || h.Temp < coldPeak.Temp) {
+			coldPeak = &h
+		}
+		if rules.HeavyPrecipMm > 0 && h.Precip >= rules.HeavyPrecipMm && (precipPeak == nil || h.Precip > precipPeak.Precip) {
+			precipPeak = &h
+		}
+	}
+
+	if windPeak != nil {
+		severity := SeverityWarning
+		if windPeak.WindSpeed >= rules.WindKmh*1.5 {
+			severity = SeveritySevere
+		}
+		alerts = append(alerts, Alert{
+			Type:     AlertWind,
+			Severity: severity,
+			Message:  fmt.Sprintf("Wind %s at %s", units.FormatSpeed(windPeak.WindSpeed), start(*windPeak)),
+			Start:    start(*windPeak),
+			Value:    units.Speed(windPeak.WindSpeed),
+		})
+	}
+
+	if coldPeak != nil {
+		severity := SeverityAdvisory
+		if coldPeak.Temp <= *rules.FreezingTemp-5 {
+			severity = SeverityWarning
+		}
+		alerts = append(alerts, Alert{
+			Type:     AlertFreeze,
+			Severity: severity,
+			Message:  fmt.Sprintf("Freezing %s at %s", units.FormatTemp(coldPeak.Temp), start(*coldPeak)),
+			Start:    start(*coldPeak),
+			Value:    units.Temp(coldPeak.Temp),
+		})
+	}
+
+	if precipPeak != nil {
+		severity := SeverityWarning
+		if precipPeak.Precip >= rules.HeavyPrecipMm*2 {
+			severity = SeveritySevere
+		}
+		kind := "rain"
+		if isSnowCode(precipPeak.Code) {
+			kind = "snow"
+		}
+		alerts = append(alerts, Alert{
+			Type:     AlertHeavyPrecip,
+			Severity: severity,
+			Message:  fmt.Sprintf("Heavy %s %s/h at %s", kind, units.FormatPrecip(precipPeak.Precip), start(*precipPeak)),
+			Start:    start(*precipPeak),
+			Value:    units.Precip(precipPeak.Precip),
+		})
+	}
+
+	sort.SliceStable(alerts, func(i, j int) bool {
+		return severityRank[alerts[i].Severity] > severityRank[alerts[j].Severity]
+	})
+
+	return alerts
+}
+
+// isSnowCode reports whether the WMO code is a snow condition
+func isSnowCode(code int) bool {
+	return describeWeatherCode(code, true).Category == CategorySnow
+}
+
+// alertsFor evaluates the configured rules against the cached hourly forecast.
+// It never calls upstream: until the scheduler or the alerts endpoint fill the
+// cache there are no alerts. Errors are logged and yield no alerts, so they
+// never break a dashboard.
+func alertsFor(loc Location, units Units) []Alert {
+	var hours []HourlyWeather
+	if _, err := GetCachedWeather(hourlyWeatherKey(loc), &hours); err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Failed to evaluate weather alerts for %s: %v", loc.Name, err)
+		}
+		return nil
+	}
+
+	zone, err := time.LoadLocation(loc.Timezone)
+	if err != nil {
+		log.Printf("Failed to evaluate weather alerts for %s: %v", loc.Name, err)
+		return nil
+	}
+
+	return EvaluateAlerts(config.Alerts, hoursFrom(hours, time.Now()), time.Now(), zone, units)
+}
+
+// defaultAlerts returns the alerts for the default location, attached to
+// every dashboard payload so the device can show a banner anywhere
+func defaultAlerts(units Units) []Alert {
+	loc, err := config.GetLocation("")
+	if err != nil {
+		return nil
+	}
+	return alertsFor(loc, units)
+}
+
+// handleWeatherAlerts returns the active weather alerts for ?loc=
+func handleWeatherAlerts(w http.ResponseWriter, r *http.Request) {
+	loc, err := config.GetLocation(r.URL.Query().Get("loc"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	units, err := unitsFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hours, info, err := getHourlyCached(loc)
+	if err != nil {
+		log.Printf("Error fetching hourly forecast from %s: %v", weatherProvider.Name(), err)
+		http.Error(w, "Failed to fetch hourly forecast", http.StatusInternalServerError)
+		return
+	}
+
+	zone, err := time.LoadLocation(loc.Timezone)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := AlertsResponse{
+		Location: loc.Name,
+		Alerts:   EvaluateAlerts(config.Alerts, hours, time.Now(), zone, units),
+		Units:    units.Labels(),
+	}
+	if response.Alerts == nil {
+		response.Alerts = []Alert{}
+	}
+
+	writeCacheHeaders(w, info)
+	json.NewEncoder(w).Encode(response)
+	log.Printf("[%s] GET /api/weather/alerts -> %d alerts for %s",
+		time.Now().Format("15:04:05"), len(response.Alerts), loc.Name)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// calmHours returns a quiet hourly forecast starting at now
+func calmHours(now time.Time, count int) []HourlyWeather {
+	hours := make([]HourlyWeather, count)
+	for i := range hours {
+		hours[i] = HourlyWeather{
+			Time:      now.Add(time.Duration(i) * time.Hour),
+			Temp:      15,
+			WindSpeed: 10,
+			Code:      1,
+		}
+	}
+	return hours
+}
+
+func TestEvaluateAlerts(t *testing.T) {
+	now := time.Date(2026, 1, 10, 9, 0, 0, 0, time.UTC)
+	rules := DefaultConfig().Alerts
+
+	tests := []struct {
+		name   string
+		modify func(hours []HourlyWeather)
+		want   []Alert
+	}{
+		{
+			name:   "calm",
+			modify: func(hours []HourlyWeather) {},
+		},
+		{
+			name: "storm within look-ahead",
+			modify: func(hours []HourlyWeather) {
+				hours[3].Code = 95
+			},
+			want: []Alert{{Type: AlertThunderstorm, Severity: SeverityWarning, Message: "Storm from 12:00", Start: "12:00", Value: 95}},
+		},
+		{
+			name: "storm past look-ahead",
+			modify: func(hours []HourlyWeather) {
+				hours[8].Code = 99
+			},
+		},
+		{
+			name: "wind peak and heavy snow, severe first",
+			modify: func(hours []HourlyWeather) {
+				hours[2].WindSpeed = 55
+				hours[4].WindSpeed = 60
+				hours[5].Precip = 16
+				hours[5].Code = 75
+			},
+			want: []Alert{
+				{Type: AlertHeavyPrecip, Severity: SeveritySevere, Message: "Heavy snow 16 mm/h at 14:00", Start: "14:00", Value: 16},
+				{Type: AlertWind, Severity: SeverityWarning, Message: "Wind 60 km/h at 13:00", Start: "13:00", Value: 60},
+			},
+		},
+		{
+			name: "freezing",
+			modify: func(hours []HourlyWeather) {
+				hours[1].Temp = -1
+				hours[6].Temp = -6
+			},
+			want: []Alert{{Type: AlertFreeze, Severity: SeverityWarning, Message: "Freezing -6°C at 15:00", Start: "15:00", Value: -6}},
+		},
+		{
+			name: "past hours are ignored",
+			modify: func(hours []HourlyWeather) {
+				hours[0].Time = now.Add(-2 * time.Hour)
+				hours[0].WindSpeed = 120
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hours := calmHours(now, 24)
+			tt.modify(hours)
+
+			got := EvaluateAlerts(rules, hours, now, time.UTC, metricUnits)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d alerts, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("alert %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEvaluateAlertsDisabledRules(t *testing.T) {
+	now := time.Date(2026, 1, 10, 9, 0, 0, 0, time.UTC)
+	hours := calmHours(now, 24)
+	hours[1].Code = 96
+	hours[1].WindSpeed = 90
+	hours[1].Temp = -10
+
+	if got := EvaluateAlerts(AlertRules{}, hours, now, time.UTC, metricUnits); len(got) != 0 {
+		t.Errorf("expected no alerts with all rules disabled, got %+v", got)
+	}
+}
+
+func TestHandleWeatherAlerts(t *testing.T) {
+	fake := useFakeWeather(t)
+	now := time.Now().Truncate(time.Hour)
+	fake.Hourly = calmHours(now, 24)
+	fake.Hourly[2].Code = 95
+
+	// Dashboards only attach cached alerts, the hourly forecast is not fetched
+	// for them
+	rec := httptest.NewRecorder()
+	handleWeather(rec, httptest.NewRequest("GET", "/api/weather?verbose=1", nil))
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), `"alerts"`) || fake.HourlyCalls.Load() != 0 {
+		t.Errorf("cold cache: status %d, %d hourly calls, body %s", rec.Code, fake.HourlyCalls.Load(), rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	handleWeatherAlerts(rec, httptest.NewRequest("GET", "/api/weather/alerts", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	var response AlertsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Alerts) != 1 || response.Alerts[0].Type != AlertThunderstorm {
+		t.Errorf("unexpected alerts: %+v", response.Alerts)
+	}
+
+	// The same alert is attached to the verbose weather payload
+	rec = httptest.NewRecorder()
+	handleWeather(rec, httptest.NewRequest("GET", "/api/weather?verbose=1", nil))
+
+	var weather Weather
+	if err := json.NewDecoder(rec.Body).Decode(&weather); err != nil {
+		t.Fatal(err)
+	}
+	if len(weather.Alerts) != 1 {
+		t.Errorf("weather alerts = %+v, want the storm alert", weather.Alerts)
+	}
+}
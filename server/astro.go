@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"time"
+)
+
+// Moon phase keys, in cycle order from the new moon
+var moonPhases = []struct {
+	Key  string
+	Name string
+}{
+	{"new_moon", "New moon"},
+	{"waxing_crescent", "Waxing crescent"},
+	{"first_quarter", "First quarter"},
+	{"waxing_gibbous", "Waxing gibbous"},
+	{"full_moon", "Full moon"},
+	{"waning_gibbous", "Waning gibbous"},
+	{"last_quarter", "Last quarter"},
+	{"waning_crescent", "Waning crescent"},
+}
+
+// synodicMonth is the mean length of a lunar cycle in days
+const synodicMonth = 29.530588853
+
+// referenceNewMoon is a known new moon the cycle is counted from
+var referenceNewMoon = time.Date(2000, 1, 6, 18, 14, 0, 0, time.UTC)
+
+// SunDay is the sun and UV outlook for one day
+type SunDay struct {
+	Date     string  `json:"date"`    // "2006-01-02"
+	Sunrise  string  `json:"sunrise"` // Local "15:04"
+	Sunset   string  `json:"sunset"`
+	Daylight int     `json:"daylight"` // Minutes
+	UVMax    float64 `json:"uv_max"`
+}
+
+// AstronomyProvider is implemented by weather providers that report sun times
+// and UV
+type AstronomyProvider interface {
+	GetSun(loc Location, days int) ([]SunDay, error)
+}
+
+// Astronomy is the response for the astronomy endpoint
+type Astronomy struct {
+	Location string    `json:"location"`
+	Date     string    `json:"date"`          // Local date
+	Sun      *SunInfo  `json:"sun,omitempty"` // Omitted when the provider has no sun data
+	Moon     MoonPhase `json:"moon"`
+}
+
+// SunInfo holds today's sun times and UV, plus tomorrow's sunrise for early riders
+type SunInfo struct {
+	Sunrise         string  `json:"sunrise"`
+	Sunset          string  `json:"sunset"`
+	Daylight        string  `json:"daylight"` // "10:45"
+	DaylightMinutes int     `json:"daylight_min"`
+	SunriseTomorrow string  `json:"sunrise_tomorrow,omitempty"`
+	UVMax           float64 `json:"uv_max"`
+	UVLevel         string  `json:"uv_level"` // WHO category
+}
+
+// MoonPhase describes the moon on a given day. It is computed from the mean
+// lunar cycle, so phase dates can be off by up to a day.
+type MoonPhase struct {
+	Phase        string  `json:"phase"` // e.g. "waxing_crescent"
+	Name         string  `json:"name"`
+	Index        int     `json:"index"`        // 0-7 in cycle order, for picking a sprite
+	Age          float64 `json:"age"`          // Days since the new moon
+	Illumination int     `json:"illumination"` // Lit fraction, %
+	NextFull     string  `json:"next_full"`    // Local date
+	NextNew      string  `json:"next_new"`
+}
+
+// moonPhaseAt computes the moon phase at t; dates are reported in zone
+func moonPhaseAt(t time.Time, zone *time.Location) MoonPhase {
+	days := t.Sub(referenceNewMoon).Hours() / 24
+	age := math.Mod(days, synodicMonth)
+	if age < 0 {
+		age += synodicMonth
+	}
+
+	// Eight phases, each centred on its exact point in the cycle
+	index := int(age/synodicMonth*8+0.5) % 8
+
+	cycleStart := t.Add(-time.Duration(age * 24 * float64(time.Hour)))
+	cycleAt := func(fraction float64) time.Time {
+		return cycleStart.Add(time.Duration(fraction * synodicMonth * 24 * float64(time.Hour)))
+	}
+	nextFull := cycleAt(0.5)
+	if !nextFull.After(t) {
+		nextFull = cycleAt(1.5)
+	}
+
+	return MoonPhase{
+		Phase:        moonPhases[index].Key,
+		Name:         moonPhases[index].Name,
+		Index:        index,
+		Age:          math.Round(age*10) / 10,
+		Illumination: int(math.Round((1 - math.Cos(2*math.Pi*age/synodicMonth)) / 2 * 100)),
+		NextFull:     nextFull.In(zone).Format("2006-01-02"),
+		NextNew:      cycleAt(1).In(zone).Format("2006-01-02"),
+	}
+}
+
+// uvLevel returns the WHO category of a UV index
+func uvLevel(uv float64) string {
+	switch {
+	case uv < 3:
+		return "low"
+	case uv < 6:
+		return "moderate"
+	case uv < 8:
+		return "high"
+	case uv < 11:
+		return "very_high"
+	default:
+		return "extreme"
+	}
+}
+
+// newSunInfo builds the sun block from today's and, if present, tomorrow's outlook
+func newSunInfo(days []SunDay) *SunInfo {
+	if len(days) == 0 {
+		return nil
+	}
+
+	today := days[0]
+	sun := &SunInfo{
+		Sunrise:         today.Sunrise,
+		Sunset:          today.Sunset,
+		Daylight:        formatHoursMinutes(float64(today.Daylight * 60)),
+		DaylightMinutes: today.Daylight,
+		UVMax:           today.UVMax,
+		UVLevel:         uvLevel(today.UVMax),
+	}
+	if len(days) > 1 {
+		sun.SunriseTomorrow = days[1].Sunrise
+	}
+	return sun
+}
+
+// sunSource returns the cache key and upstream call for the sun times of
+// today and tomorrow at loc
+func sunSource(loc Location, provider AstronomyProvider) (string, func() (*[]SunDay, error)) {
+	return "sun:" + loc.Key, func() (*[]SunDay, error) {
+		days, err := provider.GetSun(loc, 2)
+		return &days, err
+	}
+}
+
+// handleWeatherAstro returns today's sun times, UV and moon phase for ?loc=.
+// The moon is computed locally, so it is returned even when upstream fails.
+func handleWeatherAstro(w http.ResponseWriter, r *http.Request) {
+	loc, err := config.GetLocation(r.URL.Query().Get("loc"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	zone, err := time.LoadLocation(loc.Timezone)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	response := Astronomy{
+		Location: loc.Name,
+		Date:     now.In(zone).Format("2006-01-02"),
+		Moon:     moonPhaseAt(now, zone),
+	}
+
+	if provider, ok := weatherProvider.(AstronomyProvider); ok {
+		days, info, err := fetchWeatherCached(sunSource(loc, provider))
+		if err != nil {
+			log.Printf("Error fetching sun times from %s: %v", weatherProvider.Name(), err)
+		} else {
+			// The cache may still hold yesterday's outlook just after midnight
+			for len(*days) > 0 && (*days)[0].Date < response.Date {
+				*days = (*days)[1:]
+			}
+			response.Sun = newSunInfo(*days)
+			writeCacheHeaders(w, info)
+		}
+	}
+
+	json.NewEncoder(w).Encode(response)
+	log.Printf("[%s] GET /api/weather/astro -> %s, %d%% for %s",
+		time.Now().Format("15:04:05"), response.Moon.Name, response.Moon.Illumination, loc.Name)
+}
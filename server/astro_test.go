@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMoonPhaseAt(t *testing.T) {
+	tests := []struct {
+		name  string
+		at    time.Time
+		phase string
+		min   int // Illumination bounds, %
+		max   int
+	}{
+		// 2024-04-08 total solar eclipse
+		{"new", time.Date(2024, 4, 8, 18, 21, 0, 0, time.UTC), "new_moon", 0, 2},
+		{"full", time.Date(2024, 4, 23, 23, 49, 0, 0, time.UTC), "full_moon", 98, 100},
+		{"first quarter", time.Date(2024, 4, 15, 19, 13, 0, 0, time.UTC), "first_quarter", 40, 60},
+		{"last quarter", time.Date(2024, 5, 1, 11, 27, 0, 0, time.UTC), "last_quarter", 40, 60},
+		{"waxing crescent", time.Date(2024, 4, 11, 12, 0, 0, 0, time.UTC), "waxing_crescent", 5, 35},
+		{"before reference", time.Date(1999, 12, 22, 17, 31, 0, 0, time.UTC), "full_moon", 98, 100},
+	}
+
+	for _, tt := range tests {
+		moon := moonPhaseAt(tt.at, time.UTC)
+		if moon.Phase != tt.phase || moon.Illumination < tt.min || moon.Illumination > tt.max {
+			t.Errorf("%s: got %s at %d%%, want %s", tt.name, moon.Phase, moon.Illumination, tt.phase)
+		}
+		if moonPhases[moon.Index].Key != moon.Phase {
+			t.Errorf("%s: index %d does not match %s", tt.name, moon.Index, moon.Phase)
+		}
+	}
+
+	moon := moonPhaseAt(time.Date(2024, 4, 10, 12, 0, 0, 0, time.UTC), time.UTC)
+	if moon.NextFull != "2024-04-23" && moon.NextFull != "2024-04-24" {
+		t.Errorf("next full moon = %s, want 2024-04-23", moon.NextFull)
+	}
+	if moon.NextNew != "2024-05-07" && moon.NextNew != "2024-05-08" {
+		t.Errorf("next new moon = %s, want 2024-05-08", moon.NextNew)
+	}
+}
+
+func TestUVLevel(t *testing.T) {
+	tests := map[float64]string{0: "low", 2.9: "low", 3: "moderate", 6.5: "high", 10.9: "very_high", 11: "extreme"}
+	for uv, want := range tests {
+		if got := uvLevel(uv); got != want {
+			t.Errorf("uvLevel(%v) = %s, want %s", uv, got, want)
+		}
+	}
+}
+
+func TestOpenMeteoSun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("daily") != "sunrise,sunset,daylight_duration,uv_index_max" {
+			t.Errorf("daily = %q", r.URL.Query().Get("daily"))
+		}
+		w.Write([]byte(`{"daily": {
+			"time": ["2026-10-16", "2026-10-17"],
+			"sunrise": ["2026-10-16T08:05", "2026-10-17T08:06"],
+			"sunset": ["2026-10-16T18:50", "2026-10-17T18:48"],
+			"daylight_duration": [38700.5, 38520.0],
+			"uv_index_max": [3.15]
+		}}`))
+	}))
+	defer server.Close()
+
+	provider := newOpenMeteoProvider()
+	provider.baseURL = server.URL
+
+	days, err := provider.GetSun(Location{Timezone: "Europe/Paris"}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The UV array is short, so only the first day is complete
+	want := SunDay{Date: "2026-10-16", Sunrise: "08:05", Sunset: "18:50", Daylight: 645, UVMax: 3.15}
+	if len(days) != 1 || days[0] != want {
+		t.Errorf("days = %+v, want [%+v]", days, want)
+	}
+}
+
+func TestHandleWeatherAstro(t *testing.T) {
+	fake := useFakeWeather(t)
+
+	rec := httptest.NewRecorder()
+	handleWeatherAstro(rec, httptest.NewRequest("GET", "/api/weather/astro", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	var astro Astronomy
+	if err := json.Unmarshal(rec.Body.Bytes(), &astro); err != nil {
+		t.Fatal(err)
+	}
+	if astro.Sun == nil {
+		t.Fatal("sun block missing")
+	}
+	want := SunInfo{Sunrise: "07:30", Sunset: "19:10", Daylight: "11:40", DaylightMinutes: 700,
+		SunriseTomorrow: "07:31", UVMax: 4.5, UVLevel: "moderate"}
+	if *astro.Sun != want {
+		t.Errorf("sun = %+v, want %+v", *astro.Sun, want)
+	}
+	if astro.Moon.Phase == "" || astro.Moon.NextFull == "" {
+		t.Errorf("moon = %+v", astro.Moon)
+	}
+	if fake.SunCalls.Load() != 1 {
+		t.Errorf("GetSun called %d times, want 1", fake.SunCalls.Load())
+	}
+}
+
+func TestHandleWeatherAstroWithoutSun(t *testing.T) {
+	useFakeWeather(t)
+
+	// A provider without sun data still gets the locally computed moon
+	weatherProvider = struct{ WeatherProvider }{weatherProvider}
+
+	rec := httptest.NewRecorder()
+	handleWeatherAstro(rec, httptest.NewRequest("GET", "/api/weather/astro", nil))
+
+	var astro Astronomy
+	if err := json.Unmarshal(rec.Body.Bytes(), &astro); err != nil {
+		t.Fatal(err)
+	}
+	if astro.Sun != nil || astro.Moon.Name == "" {
+		t.Errorf("astronomy = %+v, want moon only", astro)
+	}
+}
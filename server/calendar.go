@@ -0,0 +1,455 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Calendar limits
+const (
+	CalendarDefaultDays = 7 // Today and the next 6 days
+	CalendarMaxDays     = 14
+
+	// CalendarMaxAge is how long stored events are served before a poll
+	// refreshes them; plans are edited more often than past data
+	CalendarMaxAge = time.Hour
+
+	// CalendarMinFetchInterval caps events calls per range, however many
+	// devices poll
+	CalendarMinFetchInterval = 15 * time.Minute
+)
+
+// Planned event categories sent to the device
+const (
+	EventWorkout = "workout"
+	EventRace    = "race"
+	EventNote    = "note"
+)
+
+// eventCategories maps the intervals.icu categories shown on the device.
+// Other categories (holidays, eFTP changes, plan markers...) are skipped.
+var eventCategories = map[string]string{
+	"WORKOUT": EventWorkout,
+	"RACE_A":  EventRace,
+	"RACE_B":  EventRace,
+	"RACE_C":  EventRace,
+	"NOTE":    EventNote,
+}
+
+// Event is an intervals.icu calendar event
+type Event struct {
+	ID              int64       `json:"id"`
+	StartDateLocal  string      `json:"start_date_local"` // "2026-02-02T00:00:00"
+	EndDateLocal    string      `json:"end_date_local"`
+	Category        string      `json:"category"` // WORKOUT, RACE_A, NOTE...
+	Type            string      `json:"type"`     // Ride, Run...
+	Name            string      `json:"name"`
+	Description     string      `json:"description"`
+	Indoor          bool        `json:"indoor"`
+	MovingTime      float64     `json:"moving_time"` // Planned seconds
+	Distance        float64     `json:"distance"`    // Planned meters
+	IcuTrainingLoad float64     `json:"icu_training_load"`
+	WorkoutDoc      *WorkoutDoc `json:"workout_doc"`
+}
+
+// WorkoutDoc is the structured description of a planned workout
+type WorkoutDoc struct {
+	Duration float64       `json:"duration"` // Seconds
+	Distance float64       `json:"distance"` // Meters
+	Steps    []WorkoutStep `json:"steps"`
+}
+
+// WorkoutStep is one step of a workout, or a block of steps repeated Reps times
+type WorkoutStep struct {
+	Text     string        `json:"text"`
+	Duration float64       `json:"duration"` // Seconds
+	Distance float64       `json:"distance"` // Meters, for steps that are not timed
+	Reps     int           `json:"reps"`
+	Warmup   bool          `json:"warmup"`
+	Cooldown bool          `json:"cooldown"`
+	Power    *StepTarget   `json:"power"`
+	HR       *StepTarget   `json:"hr"`
+	Pace     *StepTarget   `json:"pace"`
+	Steps    []WorkoutStep `json:"steps"`
+}
+
+// StepTarget is the intensity of a step, a value or a start-end ramp
+type StepTarget struct {
+	Units string  `json:"units"` // "%ftp", "w", "power_zone", "%lthr", "hr_zone"...
+	Value float64 `json:"value"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// Calendar is the response for the calendar endpoint
+type Calendar struct {
+	Days   int            `json:"days"`
+	From   string         `json:"from"` // Today, YYYY-MM-DD
+	To     string         `json:"to"`
+	Today  *PlannedEvent  `json:"today,omitempty"` // First workout or race of today
+	Events []PlannedEvent `json:"events"`
+}
+
+// PlannedEvent is an event reduced for the device
+type PlannedEvent struct {
+	ID              int64   `json:"id"`
+	Date            string  `json:"date"`     // YYYY-MM-DD
+	Category        string  `json:"category"` // workout, race or note
+	Type            string  `json:"type,omitempty"`
+	Name            string  `json:"name"`
+	Duration        float64 `json:"duration,omitempty"`         // Planned seconds
+	DurationDisplay string  `json:"duration_display,omitempty"` // "1:05"
+	Load            float64 `json:"load,omitempty"`             // Planned training load
+	Steps           string  `json:"steps,omitempty"`            // "10m 55%, 3x(8m 95%, 4m 55%), 10m 50%"
+	Completed       bool    `json:"completed"`                  // An activity is paired with it
+}
+
+// GetEvents returns the calendar events from oldest to newest (inclusive,
+// YYYY-MM-DD)
+func (c *intervalsClient) GetEvents(oldest, newest string) ([]Event, error) {
+	query := url.Values{}
+	query.Set("oldest", oldest)
+	query.Set("newest", newest)
+
+	var events []Event
+	if err := c.get("/events", query, &events); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// calendarLimiter serialises events calls and remembers the last attempt
+var calendarLimiter = newUpstreamLimiter()
+
+// localDate returns the YYYY-MM-DD day of a local date-time such as
+// "2026-02-02T18:30:12"
+func localDate(dateTime string) string {
+	if len(dateTime) < len("2006-01-02") {
+		return dateTime
+	}
+	return dateTime[:len("2006-01-02")]
+}
+
+// SaveEvents stores the events of every day from oldest to newest, one row per
+// day, so days without events are known to be empty and events deleted
+// upstream disappear
+func SaveEvents(athleteID, oldest, newest string, events []Event) error {
+	first, err := time.Parse("2006-01-02", oldest)
+	if err != nil {
+		return err
+	}
+	last, err := time.Parse("2006-01-02", newest)
+	if err != nil {
+		return err
+	}
+
+	byDate := make(map[string][]Event)
+	for _, e := range events {
+		date := localDate(e.StartDateLocal)
+		byDate[date] = append(byDate[date], e)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Stored in UTC so last_updated sorts and compares as text
+	now := time.Now().UTC().Format(time.RFC3339)
+	for d := first; !d.After(last); d = d.AddDate(0, 0, 1) {
+		date := d.Format("2006-01-02")
+		day := byDate[date]
+		if day == nil {
+			day = []Event{}
+		}
+		eventsJSON, err := json.Marshal(day)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			INSERT OR REPLACE INTO intervals_events (athlete_id, date, version, events_json, last_updated)
+			VALUES (?, ?, ?, ?, ?)
+		`, athleteID, date, intervalsStoreVersion, string(eventsJSON), now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetStoredEvents returns the stored events of an athlete from oldest to
+// newest (inclusive) by day, and when the least recently updated day was
+// stored. Days that were never fetched are missing. An empty athleteID
+// matches any athlete.
+func GetStoredEvents(athleteID, oldest, newest string) (map[string][]Event, time.Time, error) {
+	rows, err := db.Query(`
+		SELECT date, events_json, last_updated
+		FROM intervals_events
+		WHERE (? = '' OR athlete_id = ?) AND date >= ? AND date <= ? AND version = ?
+		ORDER BY date, last_updated
+	`, athleteID, athleteID, oldest, newest, intervalsStoreVersion)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer rows.Close()
+
+	var (
+		days      = make(map[string][]Event)
+		updatedAt time.Time
+	)
+	for rows.Next() {
+		var date, eventsJSON, lastUpdated string
+		if err := rows.Scan(&date, &eventsJSON, &lastUpdated); err != nil {
+			return nil, time.Time{}, err
+		}
+
+		var events []Event
+		if err := json.Unmarshal([]byte(eventsJSON), &events); err != nil {
+			return nil, time.Time{}, err
+		}
+		updated, err := time.Parse(time.RFC3339, lastUpdated)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+
+		// Another athlete may have stored the same date, the latest wins
+		days[date] = events
+		if updatedAt.IsZero() || updated.Before(updatedAt) {
+			updatedAt = updated
+		}
+	}
+
+	return days, updatedAt, rows.Err()
+}
+
+// refreshCalendar fetches and stores the events from oldest to newest, at
+// most once per CalendarMinFetchInterval for a range. Returns the error of
+// the last attempt when skipped.
+func refreshCalendar(oldest, newest string) error {
+	key := oldest + ":" + newest
+	unlock := calendarLimiter.lock(key)
+	defer unlock()
+
+	lastAttempt, lastErr := calendarLimiter.lastAttempt(key)
+	if time.Since(lastAttempt) < CalendarMinFetchInterval {
+		return lastErr
+	}
+
+	client, err := intervalsProvider()
+	if err != nil {
+		return err
+	}
+
+	events, err := client.GetEvents(oldest, newest)
+	if err == nil {
+		err = SaveEvents(client.AthleteID(), oldest, newest, events)
+	}
+	calendarLimiter.recordAttempt(key, err)
+	return err
+}
+
+// formatStepDuration formats a step length compactly: "30s", "10m", "1h30"
+func formatStepDuration(seconds float64) string {
+	secs := int(seconds + 0.5)
+	switch {
+	case secs < 60 || (secs < 300 && secs%60 != 0):
+		return fmt.Sprintf("%ds", secs)
+	case secs < 3600:
+		return fmt.Sprintf("%dm", (secs+30)/60)
+	case secs%3600 < 60:
+		return fmt.Sprintf("%dh", secs/3600)
+	}
+	return fmt.Sprintf("%dh%02d", secs/3600, (secs%3600+30)/60)
+}
+
+// formatStepTarget formats an intensity: "95%", "250W", "Z2", "50-75%"
+func formatStepTarget(t *StepTarget) string {
+	value := func(v float64) string { return strconv.FormatFloat(round1(v), 'f', -1, 64) }
+
+	v := value(t.Value)
+	if t.Start != 0 || t.End != 0 {
+		v = value(t.Start) + "-" + value(t.End)
+	}
+	switch {
+	case strings.HasSuffix(t.Units, "_zone"):
+		return "Z" + v
+	case t.Units == "w":
+		return v + "W"
+	case strings.HasPrefix(t.Units, "%"):
+		return v + "%"
+	}
+	return v
+}
+
+// formatStep formats one step, e.g. "10m 55%" or "3x(8m 95%, 4m 55%)"
+func formatStep(s WorkoutStep) string {
+	if s.Reps > 0 && len(s.Steps) > 0 {
+		return fmt.Sprintf("%dx(%s)", s.Reps, summarizeSteps(s.Steps))
+	}
+
+	var parts []string
+	switch {
+	case s.Duration > 0:
+		parts = append(parts, formatStepDuration(s.Duration))
+	case s.Distance > 0:
+		parts = append(parts, strconv.FormatFloat(round1(s.Distance/1000), 'f', -1, 64)+"km")
+	}
+	switch {
+	case s.Power != nil:
+		parts = append(parts, formatStepTarget(s.Power))
+	case s.HR != nil:
+		parts = append(parts, formatStepTarget(s.HR)+" HR")
+	case s.Pace != nil:
+		parts = append(parts, formatStepTarget(s.Pace)+" pace")
+	}
+	if len(parts) == 0 {
+		return s.Text
+	}
+	return strings.Join(parts, " ")
+}
+
+// summarizeSteps formats the steps of a workout on one line
+func summarizeSteps(steps []WorkoutStep) string {
+	var parts []string
+	for _, s := range steps {
+		if step := formatStep(s); step != "" {
+			parts = append(parts, step)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// planEvents reduces the stored events of the dates to the device payload,
+// marking those an activity is paired with as completed
+func planEvents(days map[string][]Event, dates []string, activities []TrainingActivity) []PlannedEvent {
+	completed := make(map[int64]bool)
+	for _, a := range activities {
+		if a.PairedEventID > 0 {
+			completed[a.PairedEventID] = true
+		}
+	}
+
+	planned := []PlannedEvent{}
+	for _, date := range dates {
+		events := days[date]
+		sort.SliceStable(events, func(i, j int) bool { return events[i].StartDateLocal < events[j].StartDateLocal })
+
+		for _, e := range events {
+			category, ok := eventCategories[e.Category]
+			if !ok {
+				continue
+			}
+			p := PlannedEvent{
+				ID:        e.ID,
+				Date:      date,
+				Category:  category,
+				Type:      e.Type,
+				Name:      e.Name,
+				Duration:  e.MovingTime,
+				Load:      e.IcuTrainingLoad,
+				Completed: completed[e.ID],
+			}
+			if e.WorkoutDoc != nil {
+				if p.Duration == 0 {
+					p.Duration = e.WorkoutDoc.Duration
+				}
+				p.Steps = summarizeSteps(e.WorkoutDoc.Steps)
+			}
+			if p.Duration > 0 {
+				p.DurationDisplay = formatHoursMinutes(p.Duration)
+			}
+			planned = append(planned, p)
+		}
+	}
+	return planned
+}
+
+// calendarNeedsFetch reports whether part of the range was never stored or
+// the stored events are out of date
+func calendarNeedsFetch(days map[string][]Event, updatedAt time.Time, count int) bool {
+	return len(days) < count || time.Since(updatedAt) > CalendarMaxAge
+}
+
+// handleCalendar returns the workouts, races and notes planned from today
+// for ?days= days
+func handleCalendar(w http.ResponseWriter, r *http.Request) {
+	days := CalendarDefaultDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		var err error
+		days, err = strconv.Atoi(v)
+		if err != nil || days < 1 || days > CalendarMaxDays {
+			http.Error(w, fmt.Sprintf("days must be between 1 and %d", CalendarMaxDays), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if !providesIntervals() {
+		writeNotSupported(w, "The calendar")
+		return
+	}
+
+	// Without credentials the stored events of any athlete are served
+	athleteID := trainingAthleteID()
+
+	now := time.Now()
+	dates := make([]string, days)
+	for i := range dates {
+		dates[i] = now.AddDate(0, 0, i).Format("2006-01-02")
+	}
+	today, newest := dates[0], dates[days-1]
+
+	stored, updatedAt, err := GetStoredEvents(athleteID, today, newest)
+	if err != nil {
+		log.Printf("Error reading events: %v", err)
+		http.Error(w, "Failed to read calendar", http.StatusInternalServerError)
+		return
+	}
+
+	if calendarNeedsFetch(stored, updatedAt, days) {
+		if err := refreshCalendar(today, newest); err != nil {
+			log.Printf("Failed to fetch events from intervals.icu: %v", err)
+			if len(stored) == 0 {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("X-Cache-Stale", "true")
+		} else if stored, _, err = GetStoredEvents(athleteID, today, newest); err != nil {
+			log.Printf("Error reading events: %v", err)
+			http.Error(w, "Failed to read calendar", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Plans are completed by the latest stored activities
+	var activities []TrainingActivity
+	if day, err := GetLatestIntervals(athleteID); err == nil {
+		activities = day.Activities
+	}
+
+	response := Calendar{
+		Days:   days,
+		From:   today,
+		To:     newest,
+		Events: planEvents(stored, dates, activities),
+	}
+	for _, e := range response.Events {
+		if e.Date == today && (e.Category == EventWorkout || e.Category == EventRace) {
+			response.Today = &e
+			break
+		}
+	}
+
+	json.NewEncoder(w).Encode(response)
+	log.Printf("[%s] GET /api/intervals/calendar?days=%d -> %d events",
+		time.Now().Format("15:04:05"), days, len(response.Events))
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetEvents(t *testing.T) {
+	client := useIntervalsFixtures(t)
+
+	events, err := client.GetEvents("2026-02-02", "2026-02-08")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 4 || events[0].ID != 8812 || events[0].WorkoutDoc == nil || len(events[0].WorkoutDoc.Steps) != 4 {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+
+	if err := SaveEvents("i42", "2026-02-02", "2026-02-08", events); err != nil {
+		t.Fatal(err)
+	}
+	days, updatedAt, err := GetStoredEvents("i42", "2026-02-02", "2026-02-08")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 7 || len(days["2026-02-02"]) != 2 || len(days["2026-02-05"]) != 0 || updatedAt.IsZero() {
+		t.Errorf("stored days = %+v, %v", days, updatedAt)
+	}
+
+	// Events deleted upstream disappear on the next save
+	if err := SaveEvents("i42", "2026-02-02", "2026-02-08", events[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if days, _, _ := GetStoredEvents("i42", "2026-02-02", "2026-02-08"); len(days["2026-02-04"]) != 0 {
+		t.Errorf("deleted race still stored: %+v", days["2026-02-04"])
+	}
+
+	if days, _, _ := GetStoredEvents("i43", "2026-02-02", "2026-02-08"); len(days) != 0 {
+		t.Errorf("other athlete got %d days", len(days))
+	}
+}
+
+func TestSummarizeSteps(t *testing.T) {
+	tests := []struct {
+		step WorkoutStep
+		want string
+	}{
+		{WorkoutStep{Duration: 600, Power: &StepTarget{Units: "%ftp", Value: 55}}, "10m 55%"},
+		{WorkoutStep{Duration: 30, Power: &StepTarget{Units: "w", Value: 400}}, "30s 400W"},
+		{WorkoutStep{Duration: 90, Power: &StepTarget{Units: "power_zone", Value: 2}}, "90s Z2"},
+		{WorkoutStep{Duration: 5400, HR: &StepTarget{Units: "%lthr", Start: 70, End: 80}}, "1h30 70-80% HR"},
+		{WorkoutStep{Duration: 7200, Pace: &StepTarget{Units: "pace_zone", Value: 2}}, "2h Z2 pace"},
+		{WorkoutStep{Distance: 2500, Pace: &StepTarget{Units: "%pace", Value: 90}}, "2.5km 90% pace"},
+		{WorkoutStep{Text: "Free ride"}, "Free ride"},
+		{WorkoutStep{Reps: 2, Steps: []WorkoutStep{
+			{Duration: 60, Power: &StepTarget{Units: "%ftp", Value: 120}},
+			{Duration: 60, Power: &StepTarget{Units: "%ftp", Value: 50}},
+		}}, "2x(1m 120%, 1m 50%)"},
+	}
+	for _, tt := range tests {
+		if got := formatStep(tt.step); got != tt.want {
+			t.Errorf("formatStep(%+v) = %q, want %q", tt.step, got, tt.want)
+		}
+	}
+}
+
+func TestHandleCalendar(t *testing.T) {
+	useIntervalsFixtures(t)
+
+	// The latest activity is paired with today's workout
+	today := time.Now().Format("2006-01-02")
+	activities := []TrainingActivity{{ID: "i104", PairedEventID: 8812}}
+	if err := SaveIntervalsCache(&TrainingDay{AthleteID: "i42", Date: today, Activities: activities}); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(query string) Calendar {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handleCalendar(rec, httptest.NewRequest("GET", "/api/intervals/calendar"+query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", query, rec.Code, rec.Body)
+		}
+		var calendar Calendar
+		if err := json.NewDecoder(rec.Body).Decode(&calendar); err != nil {
+			t.Fatal(err)
+		}
+		return calendar
+	}
+
+	calendar := get("")
+	if calendar.Days != 7 || calendar.From != today || len(calendar.Events) != 3 {
+		t.Fatalf("calendar = %+v", calendar)
+	}
+
+	workout := calendar.Today
+	if workout == nil || workout.ID != 8812 || workout.Category != EventWorkout || !workout.Completed ||
+		workout.DurationDisplay != "1:00" || workout.Load != 62 {
+		t.Errorf("today = %+v", workout)
+	}
+	if want := "10m 45-65%, 3x(8m 90-95%, 4m 55%), 90s Z1 HR, 9m 120W"; workout != nil && workout.Steps != want {
+		t.Errorf("steps = %q, want %q", workout.Steps, want)
+	}
+
+	// The eFTP change is skipped, the race is in two days and not completed
+	note, race := calendar.Events[1], calendar.Events[2]
+	if note.Category != EventNote || note.Date != today || note.Completed {
+		t.Errorf("note = %+v", note)
+	}
+	if race.Category != EventRace || race.Date != time.Now().AddDate(0, 0, 2).Format("2006-01-02") ||
+		race.DurationDisplay != "2:30" || race.Completed {
+		t.Errorf("race = %+v", race)
+	}
+
+	// Stored days answer a shorter range without calling upstream again
+	if calendar := get("?days=2"); calendar.To != time.Now().AddDate(0, 0, 1).Format("2006-01-02") || len(calendar.Events) != 2 {
+		t.Errorf("2 days = %+v", calendar)
+	}
+
+	for _, query := range []string{"?days=0", "?days=15", "?days=abc"} {
+		rec := httptest.NewRecorder()
+		handleCalendar(rec, httptest.NewRequest("GET", "/api/intervals/calendar"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", query, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Location is a named place the weather endpoints can report on
+type Location struct {
+	Key       string  `json:"-"` // Config key, set by GetLocation
+	Name      string  `json:"name"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Timezone  string  `json:"timezone"` // IANA zone, e.g. "Europe/Paris"
+}
+
+// WeatherConfig holds the weather related settings
+type WeatherConfig struct {
+	Provider        string              `json:"provider"`             // "open-meteo", "met-no" or "fake"
+	AirQuality      string              `json:"air_quality_provider"` // "open-meteo" or "fake"
+	DefaultLocation string              `json:"default_location"`
+	Locations       map[string]Location `json:"locations"`
+
+	// ForecastSlots lists the forecasts returned with the current weather,
+	// as "+Nh" offsets or "tomorrow-noon" / "tomorrow-HH"
+	ForecastSlots []string `json:"forecast_slots"`
+
+	// CacheTTL is how long a fetched forecast is served before refreshing it
+	CacheTTL Duration `json:"cache_ttl"`
+	// MinFetchInterval caps upstream calls per location, however many devices poll
+	MinFetchInterval Duration `json:"min_fetch_interval"`
+
+	// HistoryInterval is how often observations are recorded, "0s" disables it
+	HistoryInterval Duration `json:"history_interval"`
+	// HistoryRetention is how long recorded observations are kept
+	HistoryRetention Duration `json:"history_retention"`
+}
+
+// IntervalsConfig holds the intervals.icu client settings. The athlete ID and
+// API key are read from KWallet.
+type IntervalsConfig struct {
+	BaseURL string   `json:"base_url"`
+	Timeout Duration `json:"timeout"` // Per request attempt
+
+	// Retries is how many times a transient failure is retried, with
+	// exponential backoff starting at RetryDelay
+	Retries    int      `json:"retries"`
+	RetryDelay Duration `json:"retry_delay"`
+
+	// BreakerFailures consecutive failed calls stop calling the API for
+	// BreakerCooldown, 0 disables the circuit breaker
+	BreakerFailures int      `json:"breaker_failures"`
+	BreakerCooldown Duration `json:"breaker_cooldown"`
+}
+
+// StravaConfig holds the Strava client settings, used when it is the
+// training provider. The client ID, client secret and refresh token are read
+// from KWallet.
+type StravaConfig struct {
+	BaseURL string   `json:"base_url"`
+	Timeout Duration `json:"timeout"` // Per request attempt
+
+	// FitnessDays of activities are fetched to estimate CTL and ATL
+	FitnessDays int `json:"fitness_days"`
+
+	// Retries and the circuit breaker work as for intervals.icu
+	Retries         int      `json:"retries"`
+	RetryDelay      Duration `json:"retry_delay"`
+	BreakerFailures int      `json:"breaker_failures"`
+	BreakerCooldown Duration `json:"breaker_cooldown"`
+}
+
+// RefreshConfig sets how often each source is refreshed in the background,
+// so handlers answer from the latest snapshot. "0s" disables a source, which
+// is then fetched when a device polls.
+type RefreshConfig struct {
+	Intervals  Duration `json:"intervals"`
+	Weather    Duration `json:"weather"` // Current, hourly, daily and sun
+	AirQuality Duration `json:"air_quality"`
+
+	// WellnessQueue is how often check-ins queued while intervals.icu was
+	// unavailable are sent again
+	WellnessQueue Duration `json:"wellness_queue"`
+
+	// Jitter spreads each wait by up to this fraction of the cadence
+	Jitter float64 `json:"jitter"`
+}
+
+// Duration is a time.Duration read from JSON strings such as "15m"
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"15m\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// Config is the server configuration, loaded from a JSON file
+type Config struct {
+	Weather   WeatherConfig   `json:"weather"`
+	Alerts    AlertRules      `json:"alerts"`
+	Units     Units           `json:"units"` // Default for requests without ?units=
+	Intervals IntervalsConfig `json:"intervals"`
+	Strava    StravaConfig    `json:"strava"`
+	Refresh   RefreshConfig   `json:"refresh"`
+
+	// TrainingProvider is the source of the intervals payloads: "intervals"
+	// or "strava"
+	TrainingProvider string `json:"training_provider"`
+
+	// Training classifies the form of the intervals payloads
+	Training TrainingThresholds `json:"training"`
+}
+
+var config = DefaultConfig()
+
+// DefaultConfig returns the configuration used when no config file exists
+func DefaultConfig() *Config {
+	freezingTemp := 0.0
+
+	return &Config{
+		Weather: WeatherConfig{
+			Provider:         ProviderOpenMeteo,
+			AirQuality:       ProviderOpenMeteo,
+			CacheTTL:         Duration{15 * time.Minute},
+			MinFetchInterval: Duration{time.Minute},
+			ForecastSlots:    []string{"+1h", "+3h", "+6h", "tomorrow-noon"},
+			HistoryInterval:  Duration{30 * time.Minute},
+			HistoryRetention: Duration{30 * 24 * time.Hour},
+			DefaultLocation:  "aix",
+			Locations: map[string]Location{
+				"aix": {
+					Name:      "Aix-les-Bains",
+					Latitude:  45.6885,
+					Longitude: 5.9153,
+					Timezone:  "Europe/Paris",
+				},
+			},
+		},
+		Alerts: AlertRules{
+			ThunderstormHours: 6,
+			LookaheadHours:    12,
+			WindKmh:           50,
+			FreezingTemp:      &freezingTemp,
+			HeavyPrecipMm:     7.5,
+		},
+		Units: metricUnits,
+		Intervals: IntervalsConfig{
+			BaseURL:         intervalsBaseURL,
+			Timeout:         Duration{15 * time.Second},
+			Retries:         2,
+			RetryDelay:      Duration{500 * time.Millisecond},
+			BreakerFailures: 3,
+			BreakerCooldown: Duration{5 * time.Minute},
+		},
+		Strava: StravaConfig{
+			BaseURL:         stravaBaseURL,
+			Timeout:         Duration{15 * time.Second},
+			FitnessDays:     90,
+			Retries:         2,
+			RetryDelay:      Duration{500 * time.Millisecond},
+			BreakerFailures: 3,
+			BreakerCooldown: Duration{5 * time.Minute},
+		},
+		TrainingProvider: ProviderIntervals,
+		Training: TrainingThresholds{
+			TransitionTsb: 25,
+			FreshTsb:      5,
+			OptimalTsb:    -10,
+			HighRiskTsb:   -30,
+			MaxRampRate:   8,
+		},
+		Refresh: RefreshConfig{
+			Intervals:     Duration{time.Hour},
+			Weather:       Duration{10 * time.Minute},
+			AirQuality:    Duration{30 * time.Minute},
+			WellnessQueue: Duration{5 * time.Minute},
+			Jitter:        0.1,
+		},
+	}
+}
+
+// configPath returns the config file location, overridable with DASHBOARD_CONFIG
+func configPath() string {
+	if path := os.Getenv("DASHBOARD_CONFIG"); path != "" {
+		return path
+	}
+	return filepath.Join(dataDir(), "config.json")
+}
+
+// LoadConfig reads the config file, keeping defaults for anything not set
+func LoadConfig() error {
+	path := configPath()
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		log.Printf("No config file at %s, using defaults", path)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	cfg := DefaultConfig()
+	// Locations are replaced as a whole rather than merged with the default one
+	cfg.Weather.Locations = nil
+	// The wind unit follows the unit system unless set
+	cfg.Units = Units{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if cfg.Weather.Locations == nil {
+		cfg.Weather.Locations = DefaultConfig().Weather.Locations
+	}
+	if cfg.Units.System == "" {
+		cfg.Units.System = UnitsMetric
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid config %s: %w", path, err)
+	}
+
+	config = cfg
+	log.Printf("Config loaded from %s", path)
+	return nil
+}
+
+// Validate checks that the configuration is usable
+func (c *Config) Validate() error {
+	if _, err := newWeatherProvider(c.Weather.Provider); err != nil {
+		return err
+	}
+	if _, err := newAirQualityProvider(c.Weather.AirQuality); err != nil {
+		return err
+	}
+	if _, err := parseForecastSlots(c.Weather.ForecastSlots); err != nil {
+		return err
+	}
+	if c.Weather.HistoryRetention.Duration <= 0 {
+		return fmt.Errorf("weather history_retention must be positive")
+	}
+	if err := c.Units.Validate(); err != nil {
+		return err
+	}
+	if u, err := url.Parse(c.Intervals.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("intervals base_url %q must be an http(s) URL", c.Intervals.BaseURL)
+	}
+	if c.Intervals.Timeout.Duration <= 0 {
+		return fmt.Errorf("intervals timeout must be positive")
+	}
+	if c.Intervals.Retries < 0 || c.Intervals.RetryDelay.Duration < 0 ||
+		c.Intervals.BreakerFailures < 0 || c.Intervals.BreakerCooldown.Duration < 0 {
+		return fmt.Errorf("intervals retry and breaker settings must not be negative")
+	}
+	switch c.TrainingProvider {
+	case "", ProviderIntervals, ProviderStrava:
+	default:
+		return fmt.Errorf("unknown training provider %q", c.TrainingProvider)
+	}
+	if u, err := url.Parse(c.Strava.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("strava base_url %q must be an http(s) URL", c.Strava.BaseURL)
+	}
+	if c.Strava.Timeout.Duration <= 0 {
+		return fmt.Errorf("strava timeout must be positive")
+	}
+	if c.Strava.FitnessDays < 1 {
+		return fmt.Errorf("strava fitness_days must be positive")
+	}
+	if c.Strava.Retries < 0 || c.Strava.RetryDelay.Duration < 0 ||
+		c.Strava.BreakerFailures < 0 || c.Strava.BreakerCooldown.Duration < 0 {
+		return fmt.Errorf("strava retry and breaker settings must not be negative")
+	}
+	if err := c.Training.Validate(); err != nil {
+		return err
+	}
+	if c.Refresh.Intervals.Duration < 0 || c.Refresh.Weather.Duration < 0 || c.Refresh.AirQuality.Duration < 0 ||
+		c.Refresh.WellnessQueue.Duration < 0 {
+		return fmt.Errorf("refresh intervals must not be negative")
+	}
+	if c.Refresh.Jitter < 0 || c.Refresh.Jitter >= 1 {
+		return fmt.Errorf("refresh jitter must be between 0 and 1")
+	}
+	if c.Alerts.ThunderstormHours < 0 || c.Alerts.LookaheadHours < 0 ||
+		c.Alerts.WindKmh < 0 || c.Alerts.HeavyPrecipMm < 0 {
+		return fmt.Errorf("alert thresholds must not be negative")
+	}
+	if len(c.Weather.Locations) == 0 {
+		return fmt.Errorf("no weather locations configured")
+	}
+	if _, ok := c.Weather.Locations[c.Weather.DefaultLocation]; !ok {
+		return fmt.Errorf("default location %q is not defined", c.Weather.DefaultLocation)
+	}
+	for key, loc := range c.Weather.Locations {
+		if loc.Timezone == "" {
+			return fmt.Errorf("location %q has no timezone", key)
+		}
+		if _, err := time.LoadLocation(loc.Timezone); err != nil {
+			return fmt.Errorf("location %q: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// GetLocation resolves a location key, falling back to the default one when empty
+func (c *Config) GetLocation(key string) (Location, error) {
+	if key == "" {
+		key = c.Weather.DefaultLocation
+	}
+	loc, ok := c.Weather.Locations[key]
+	if !ok {
+		return Location{}, fmt.Errorf("unknown location %q", key)
+	}
+	loc.Key = key
+	if loc.Name == "" {
+		loc.Name = key
+	}
+	return loc, nil
+}
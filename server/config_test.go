@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfigLocations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(path, []byte(`{
+		"weather": {
+			"default_location": "home",
+			"locations": {
+				"home":   {"name": "Aix-les-Bains", "latitude": 45.6885, "longitude": 5.9153, "timezone": "Europe/Paris"},
+				"office": {"latitude": 51.5072, "longitude": -0.1276, "timezone": "Europe/London"}
+			}
+		}
+	}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("DASHBOARD_CONFIG", path)
+	defer func() { config = DefaultConfig() }()
+
+	if err := LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+
+	loc, err := config.GetLocation("")
+	if err != nil || loc.Name != "Aix-les-Bains" {
+		t.Errorf("default location = %+v, %v", loc, err)
+	}
+
+	loc, err = config.GetLocation("office")
+	if err != nil || loc.Name != "office" || loc.Timezone != "Europe/London" {
+		t.Errorf("office location = %+v, %v", loc, err)
+	}
+
+	if _, err := config.GetLocation("moon"); err == nil {
+		t.Errorf("expected error for unknown location")
+	}
+}
+
+func TestLoadConfigInvalidDefault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(path, []byte(`{"weather": {"default_location": "nowhere"}}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("DASHBOARD_CONFIG", path)
+	defer func() { config = DefaultConfig() }()
+
+	if err := LoadConfig(); err == nil {
+		t.Errorf("expected error for undefined default location")
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Daily forecast limits; the device's StaticJsonDocument fits a week
+const (
+	DailyDefaultDays = 5
+	DailyMaxDays     = 7
+)
+
+// DailyForecast is the response for the daily forecast endpoint
+type DailyForecast struct {
+	Location string        `json:"location"`
+	Days     []DayForecast `json:"days"`
+	Units    UnitLabels    `json:"units"`
+}
+
+// DayForecast is one day of the daily forecast. Keys are kept short so a
+// whole week fits in the device's JSON buffer.
+type DayForecast struct {
+	Day        string  `json:"day"`  // "Mon"
+	Date       string  `json:"date"` // "2006-01-02"
+	TempMax    float64 `json:"hi"`
+	TempMin    float64 `json:"lo"`
+	Code       int     `json:"code"` // Dominant WMO weather code
+	Condition  string  `json:"cond"`
+	PrecipSum  float64 `json:"mm"`   // Precipitation sum
+	PrecipProb int     `json:"pop"`  // Max precipitation probability, %
+	WindMax    float64 `json:"wind"` // Max wind speed
+	Sunrise    string  `json:"rise"` // Local "15:04"
+	Sunset     string  `json:"set"`
+}
+
+// convert converts the metric values to u
+func (d *DayForecast) convert(u Units) {
+	d.TempMax = u.Temp(d.TempMax)
+	d.TempMin = u.Temp(d.TempMin)
+	d.PrecipSum = u.Precip(d.PrecipSum)
+	d.WindMax = u.Speed(d.WindMax)
+}
+
+// DailyWeatherProvider is implemented by weather providers that offer a
+// multi-day forecast
+type DailyWeatherProvider interface {
+	GetDaily(loc Location, days int) ([]DayForecast, error)
+}
+
+// dailyWeatherSource returns the cache key and upstream call for the full
+// week forecast of loc
+func dailyWeatherSource(loc Location, provider DailyWeatherProvider) (string, func() (*[]DayForecast, error)) {
+	return "daily:" + loc.Key, func() (*[]DayForecast, error) {
+		days, err := provider.GetDaily(loc, DailyMaxDays)
+		return &days, err
+	}
+}
+
+// handleWeatherDaily returns a compact forecast for the next ?days= days (1-7)
+func handleWeatherDaily(w http.ResponseWriter, r *http.Request) {
+	loc, err := config.GetLocation(r.URL.Query().Get("loc"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	units, err := unitsFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	days := DailyDefaultDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		days, err = strconv.Atoi(v)
+		if err != nil || days < 1 || days > DailyMaxDays {
+			http.Error(w, "days must be between 1 and 7", http.StatusBadRequest)
+			return
+		}
+	}
+
+	provider, ok := weatherProvider.(DailyWeatherProvider)
+	if !ok {
+		http.Error(w, "Daily forecast not supported by "+weatherProvider.Name(), http.StatusNotImplemented)
+		return
+	}
+
+	// Always fetch the full week so every ?days= value shares one cache entry
+	week, info, err := fetchWeatherCached(dailyWeatherSource(loc, provider))
+	if err != nil {
+		log.Printf("Error fetching daily forecast from %s: %v", weatherProvider.Name(), err)
+		http.Error(w, "Failed to fetch daily forecast", http.StatusInternalServerError)
+		return
+	}
+
+	response := DailyForecast{
+		Location: loc.Name,
+		Days:     (*week)[:min(days, len(*week))],
+		Units:    units.Labels(),
+	}
+	for i := range response.Days {
+		response.Days[i].convert(units)
+	}
+
+	writeCacheHeaders(w, info)
+	json.NewEncoder(w).Encode(response)
+	log.Printf("[%s] GET /api/weather/daily -> %d days for %s",
+		time.Now().Format("15:04:05"), len(response.Days), loc.Name)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"database/sql"
+	"encoding/json"
 	"log"
 	"os"
 	"path/filepath"
@@ -12,23 +13,34 @@ import (
 
 var db *sql.DB
 
-// InitDB initializes the SQLite database
-func InitDB() error {
+// dataDir returns the directory used for persistent storage (database, config)
+func dataDir() string {
 	// Get user data directory for persistent storage
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		homeDir = "."
 	}
 
-	dbDir := filepath.Join(homeDir, ".tamagotchi")
-	if err := os.MkdirAll(dbDir, 0755); err != nil {
+	dir := filepath.Join(homeDir, ".tamagotchi")
+	if err := os.MkdirAll(dir, 0755); err != nil {
 		log.Printf("Warning: Could not create data directory: %v", err)
-		dbDir = "."
+		dir = "."
 	}
 
-	dbPath := filepath.Join(dbDir, "dog.db")
+	return dir
+}
+
+// InitDB initializes the SQLite database
+func InitDB() error {
+	dbPath := filepath.Join(dataDir(), "dog.db")
 	log.Printf("Database path: %s", dbPath)
 
+	return OpenDB(dbPath)
+}
+
+// OpenDB opens the database at dbPath and creates any missing tables
+func OpenDB(dbPath string) error {
+	var err error
 	db, err = sql.Open("sqlite3", dbPath)
 	if err != nil {
 		return err
@@ -57,17 +69,18 @@ func InitDB() error {
 		return err
 	}
 
-	// Create intervals cache table
+	// Create intervals store, one row per athlete and date. The legacy
+	// single-row intervals_cache table never stored anything usable.
 	intervalsSchema := `
-	CREATE TABLE IF NOT EXISTS intervals_cache (
-		id INTEGER PRIMARY KEY CHECK (id = 1),
-		ctl REAL,
-		atl REAL,
-		ramp_rate REAL,
-		fatigue REAL,
-		stress REAL,
-		activities_json TEXT,
-		last_updated DATETIME DEFAULT CURRENT_TIMESTAMP
+	DROP TABLE IF EXISTS intervals_cache;
+	CREATE TABLE IF NOT EXISTS intervals_days (
+		athlete_id TEXT NOT NULL,
+		date TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		wellness_json TEXT NOT NULL,
+		activities_json TEXT NOT NULL,
+		last_updated DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (athlete_id, date)
 	);
 	`
 
@@ -76,6 +89,165 @@ func InitDB() error {
 		return err
 	}
 
+	// Create wellness series, one row per athlete and date
+	wellnessSchema := `
+	CREATE TABLE IF NOT EXISTS intervals_wellness (
+		athlete_id TEXT NOT NULL,
+		date TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		wellness_json TEXT NOT NULL,
+		last_updated DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (athlete_id, date)
+	);
+	`
+
+	_, err = db.Exec(wellnessSchema)
+	if err != nil {
+		return err
+	}
+
+	// Create calendar events, one row per athlete and date, even without events
+	eventsSchema := `
+	CREATE TABLE IF NOT EXISTS intervals_events (
+		athlete_id TEXT NOT NULL,
+		date TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		events_json TEXT NOT NULL,
+		last_updated DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (athlete_id, date)
+	);
+	`
+
+	_, err = db.Exec(eventsSchema)
+	if err != nil {
+		return err
+	}
+
+	// Create power curves, one row per athlete and day they were computed
+	powerSchema := `
+	CREATE TABLE IF NOT EXISTS intervals_power (
+		athlete_id TEXT NOT NULL,
+		date TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		curves_json TEXT NOT NULL,
+		last_updated DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (athlete_id, date)
+	);
+	`
+
+	_, err = db.Exec(powerSchema)
+	if err != nil {
+		return err
+	}
+
+	// Create wellness check-in queue, one pending write per athlete and date
+	queueSchema := `
+	CREATE TABLE IF NOT EXISTS wellness_queue (
+		athlete_id TEXT NOT NULL,
+		date TEXT NOT NULL,
+		payload_json TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT '',
+		queued_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (athlete_id, date)
+	);
+	`
+
+	_, err = db.Exec(queueSchema)
+	if err != nil {
+		return err
+	}
+
+	// Create achievements table, one row per achievement of a synced activity,
+	// seen_at set once the device acknowledged it or on the athlete's first sync
+	achievementsSchema := `
+	CREATE TABLE IF NOT EXISTS achievements (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		athlete_id TEXT NOT NULL,
+		activity_id TEXT NOT NULL,
+		achievement_id TEXT NOT NULL,
+		activity_date TEXT NOT NULL,
+		type TEXT NOT NULL,
+		title TEXT NOT NULL,
+		message TEXT NOT NULL,
+		watts REAL NOT NULL DEFAULT 0,
+		secs REAL NOT NULL DEFAULT 0,
+		value REAL NOT NULL DEFAULT 0,
+		detected_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		seen_at DATETIME,
+		UNIQUE (athlete_id, activity_id, achievement_id)
+	);
+	`
+
+	_, err = db.Exec(achievementsSchema)
+	if err != nil {
+		return err
+	}
+
+	// Create achievement syncs table, the athletes whose achievements were
+	// recorded at least once
+	achievementSyncsSchema := `
+	CREATE TABLE IF NOT EXISTS achievement_syncs (
+		athlete_id TEXT PRIMARY KEY,
+		first_synced_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+
+	_, err = db.Exec(achievementSyncsSchema)
+	if err != nil {
+		return err
+	}
+
+	// Create Strava token table, the refresh token last rotated from the one
+	// in KWallet, per client
+	stravaSchema := `
+	CREATE TABLE IF NOT EXISTS strava_tokens (
+		client_id TEXT PRIMARY KEY,
+		wallet_token TEXT NOT NULL,
+		refresh_token TEXT NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+
+	_, err = db.Exec(stravaSchema)
+	if err != nil {
+		return err
+	}
+
+	// Create weather cache table, one row per cache key (e.g. "current:home")
+	weatherSchema := `
+	CREATE TABLE IF NOT EXISTS weather_cache (
+		cache_key TEXT PRIMARY KEY,
+		payload_json TEXT NOT NULL,
+		last_updated DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+
+	_, err = db.Exec(weatherSchema)
+	if err != nil {
+		return err
+	}
+
+	// Create weather history table, one row per recorded observation
+	historySchema := `
+	CREATE TABLE IF NOT EXISTS weather_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		location TEXT NOT NULL,
+		observed_at DATETIME NOT NULL,
+		temp REAL,
+		humidity INTEGER,
+		wind_speed REAL,
+		code INTEGER
+	);
+	CREATE INDEX IF NOT EXISTS idx_weather_history_location
+		ON weather_history (location, observed_at);
+	`
+
+	_, err = db.Exec(historySchema)
+	if err != nil {
+		return err
+	}
+
 	log.Println("Database initialized successfully")
 	return nil
 }
@@ -191,3 +363,96 @@ func ResetDog(name string) (*Dog, error) {
 	log.Printf("Created new dog: %s", name)
 	return dog, nil
 }
+
+// GetCachedWeather decodes the cached payload stored under key into target
+// and returns when it was last updated
+func GetCachedWeather(key string, target any) (time.Time, error) {
+	var payloadJSON, lastUpdated string
+
+	row := db.QueryRow(`
+		SELECT payload_json, last_updated
+		FROM weather_cache
+		WHERE cache_key = ?
+	`, key)
+
+	if err := row.Scan(&payloadJSON, &lastUpdated); err != nil {
+		return time.Time{}, err
+	}
+
+	updatedAt, err := time.Parse(time.RFC3339, lastUpdated)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if err := json.Unmarshal([]byte(payloadJSON), target); err != nil {
+		return time.Time{}, err
+	}
+
+	return updatedAt, nil
+}
+
+// SaveWeatherCache stores the payload under key, replacing any previous entry
+func SaveWeatherCache(key string, payload any) error {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		INSERT OR REPLACE INTO weather_cache (cache_key, payload_json, last_updated)
+		VALUES (?, ?, ?)
+	`, key, string(payloadJSON), time.Now().Format(time.RFC3339))
+
+	return err
+}
+
+// SaveWeatherObservation records an observation for the location key
+func SaveWeatherObservation(location string, obs WeatherObservation) error {
+	// Stored in UTC so observed_at sorts and compares as text
+	_, err := db.Exec(`
+		INSERT INTO weather_history (location, observed_at, temp, humidity, wind_speed, code)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, location, obs.Time.UTC().Format(time.RFC3339), obs.Temp, obs.Humidity, obs.WindSpeed, obs.Code)
+
+	return err
+}
+
+// GetWeatherHistory returns the observations for the location key since the
+// given time, oldest first
+func GetWeatherHistory(location string, since time.Time) ([]WeatherObservation, error) {
+	rows, err := db.Query(`
+		SELECT observed_at, temp, humidity, wind_speed, code
+		FROM weather_history
+		WHERE location = ? AND observed_at >= ?
+		ORDER BY observed_at
+	`, location, since.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []WeatherObservation
+	for rows.Next() {
+		var obs WeatherObservation
+		var observedAt string
+		if err := rows.Scan(&observedAt, &obs.Temp, &obs.Humidity, &obs.WindSpeed, &obs.Code); err != nil {
+			return nil, err
+		}
+		obs.Time, err = time.Parse(time.RFC3339, observedAt)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, obs)
+	}
+
+	return history, rows.Err()
+}
+
+// PruneWeatherHistory deletes observations older than before
+func PruneWeatherHistory(before time.Time) error {
+	_, err := db.Exec(`
+		DELETE FROM weather_history WHERE observed_at < ?
+	`, before.UTC().Format(time.RFC3339))
+
+	return err
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// setupTestDB points the global database at a fresh file in a temp directory
+func setupTestDB(t *testing.T) {
+	t.Helper()
+
+	previous := db
+	if err := OpenDB(filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatalf("OpenDB: %v", err)
+	}
+	weatherLimiter = newUpstreamLimiter()
+	fitnessLimiter = newUpstreamLimiter()
+	fitnessFetched = newFitnessCoverage()
+	calendarLimiter = newUpstreamLimiter()
+	powerLimiter = newUpstreamLimiter()
+
+	t.Cleanup(func() {
+		db.Close()
+		db = previous
+	})
+}
+
+func TestWeatherCacheRoundTrip(t *testing.T) {
+	setupTestDB(t)
+
+	in := Weather{Location: "Home", Temp: 21.5, Forecast3h: Forecast{Precip: 30}}
+	if err := SaveWeatherCache("current:home", in); err != nil {
+		t.Fatal(err)
+	}
+
+	var out Weather
+	updatedAt, err := GetCachedWeather("current:home", &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("round trip = %+v, want %+v", out, in)
+	}
+	if updatedAt.IsZero() {
+		t.Errorf("expected last updated time")
+	}
+
+	if _, err := GetCachedWeather("current:office", &out); err == nil {
+		t.Errorf("expected error for missing key")
+	}
+}
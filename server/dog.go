@@ -22,13 +22,14 @@ type Dog struct {
 
 // GameResponse is the JSON response for the tamagotchi endpoint
 type GameResponse struct {
-	Dog            Dog    `json:"dog"`
-	State          string `json:"state"`             // Visual state: "happy", "normal", "sick", etc.
-	NeedsAttention bool   `json:"needs_attention"`   // Attention call active
-	Message        string `json:"message,omitempty"` // Action feedback message
-	Image          string `json:"image"`             // Base64 RGB565 sprite data
-	ImgWidth       int    `json:"img_width"`
-	ImgHeight      int    `json:"img_height"`
+	Dog            Dog     `json:"dog"`
+	State          string  `json:"state"`             // Visual state: "happy", "normal", "sick", etc.
+	NeedsAttention bool    `json:"needs_attention"`   // Attention call active
+	Message        string  `json:"message,omitempty"` // Action feedback message
+	Image          string  `json:"image"`             // Base64 RGB565 sprite data
+	ImgWidth       int     `json:"img_width"`
+	ImgHeight      int     `json:"img_height"`
+	Alerts         []Alert `json:"alerts,omitempty"` // Weather alerts banner
 }
 
 // Action types for game interactions
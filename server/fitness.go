@@ -0,0 +1,297 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Fitness history limits
+const (
+	FitnessDefaultDays = 42 // The intervals.icu default chart range
+	FitnessMaxDays     = 730
+	FitnessMaxPoints   = 320 // One point per pixel of the device screen
+
+	// FitnessMinFetchInterval caps wellness range calls, however many
+	// devices poll and whatever ranges they ask for
+	FitnessMinFetchInterval = 15 * time.Minute
+)
+
+// FitnessHistory is the response for the fitness history endpoint
+type FitnessHistory struct {
+	Days     int            `json:"days"`
+	From     string         `json:"from"` // First day of the range, YYYY-MM-DD
+	To       string         `json:"to"`
+	Training *TrainingState `json:"training,omitempty"` // Latest day, omitted without data
+	Points   []FitnessPoint `json:"points"`
+}
+
+// FitnessPoint is one chart point; keys are short to keep 320 points small
+type FitnessPoint struct {
+	Date string  `json:"d"` // Last day of the point, YYYY-MM-DD
+	Ctl  float64 `json:"ctl"`
+	Atl  float64 `json:"atl"`
+	Tsb  float64 `json:"tsb"` // Form, CTL - ATL
+}
+
+// fitnessLimiter serialises wellness range calls and remembers the last
+// attempt. Every range shares one slot, see refreshFitness.
+var fitnessLimiter = newUpstreamLimiter()
+
+// fitnessLimiterKey is the single fitnessLimiter slot
+const fitnessLimiterKey = "wellness"
+
+// fitnessFetched remembers, per athlete, the first day of the widest wellness
+// range fetched. Days before an athlete's first record are then known to be
+// missing upstream rather than not fetched yet.
+var fitnessFetched = newFitnessCoverage()
+
+type fitnessCoverage struct {
+	mu     sync.Mutex
+	oldest map[string]string
+}
+
+func newFitnessCoverage() *fitnessCoverage {
+	return &fitnessCoverage{oldest: make(map[string]string)}
+}
+
+// from returns the first day fetched for athleteID, empty if none was
+func (c *fitnessCoverage) from(athleteID string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.oldest[athleteID]
+}
+
+// record notes that the wellness of athleteID was fetched from oldest on
+func (c *fitnessCoverage) record(athleteID, oldest string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.oldest[athleteID] = oldest
+}
+
+// SaveWellness stores wellness records, replacing those of the same athlete
+// and date
+func SaveWellness(athleteID string, records []Fitness) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Stored in UTC so last_updated sorts and compares as text
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, record := range records {
+		wellnessJSON, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			INSERT OR REPLACE INTO intervals_wellness (athlete_id, date, version, wellness_json, last_updated)
+			VALUES (?, ?, ?, ?, ?)
+		`, athleteID, record.ID, intervalsStoreVersion, string(wellnessJSON), now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetWellness returns the stored wellness records of an athlete from oldest
+// to newest (inclusive), oldest first, and when the newest was last updated.
+// An empty athleteID matches any athlete.
+func GetWellness(athleteID, oldest, newest string) ([]Fitness, time.Time, error) {
+	rows, err := db.Query(`
+		SELECT wellness_json, last_updated
+		FROM intervals_wellness
+		WHERE (? = '' OR athlete_id = ?) AND date >= ? AND date <= ? AND version = ?
+		ORDER BY date
+	`, athleteID, athleteID, oldest, newest, intervalsStoreVersion)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer rows.Close()
+
+	var (
+		records   []Fitness
+		updatedAt time.Time
+	)
+	for rows.Next() {
+		var wellnessJSON, lastUpdated string
+		if err := rows.Scan(&wellnessJSON, &lastUpdated); err != nil {
+			return nil, time.Time{}, err
+		}
+
+		var record Fitness
+		if err := json.Unmarshal([]byte(wellnessJSON), &record); err != nil {
+			return nil, time.Time{}, err
+		}
+		updatedAt, err = time.Parse(time.RFC3339, lastUpdated)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+
+		// Another athlete may have stored the same date
+		if n := len(records); n > 0 && records[n-1].ID == record.ID {
+			records[n-1] = record
+			continue
+		}
+		records = append(records, record)
+	}
+
+	return records, updatedAt, rows.Err()
+}
+
+// refreshFitness fetches and stores the wellness records from oldest to
+// newest. The range is widened to the widest one fetched so far, so every
+// ?days= value is sliced from the same records and upstream is called at most
+// once per FitnessMinFetchInterval unless a wider range is asked for. Returns
+// the error of the last attempt when skipped.
+func refreshFitness(oldest, newest string) error {
+	unlock := fitnessLimiter.lock(fitnessLimiterKey)
+	defer unlock()
+
+	client, err := intervalsProvider()
+	if err != nil {
+		return err
+	}
+	athleteID := client.AthleteID()
+
+	// Past FitnessMaxDays no request needs the widest range anymore
+	fetchedFrom := fitnessFetched.from(athleteID)
+	floor := time.Now().AddDate(0, 0, 1-FitnessMaxDays).Format("2006-01-02")
+	if fetchedFrom < floor {
+		fetchedFrom = ""
+	}
+
+	lastAttempt, lastErr := fitnessLimiter.lastAttempt(fitnessLimiterKey)
+	covered := fetchedFrom != "" && fetchedFrom <= oldest
+	if time.Since(lastAttempt) < FitnessMinFetchInterval && (covered || lastErr != nil) {
+		return lastErr
+	}
+	if fetchedFrom != "" && fetchedFrom < oldest {
+		oldest = fetchedFrom
+	}
+
+	records, err := client.GetWellness(oldest, newest)
+	if err == nil {
+		err = SaveWellness(athleteID, records)
+	}
+	if err == nil {
+		fitnessFetched.record(athleteID, oldest)
+	}
+	fitnessLimiter.recordAttempt(fitnessLimiterKey, err)
+	return err
+}
+
+// fitnessNeedsFetch reports whether the stored records miss part of the range
+// or today's values are out of date. Days before the first record are not
+// missing once a range starting at or before oldest was fetched (fetchedFrom),
+// the athlete's history just starts later.
+func fitnessNeedsFetch(records []Fitness, updatedAt time.Time, oldest, fetchedFrom, today string) bool {
+	if (len(records) == 0 || records[0].ID > oldest) && (fetchedFrom == "" || fetchedFrom > oldest) {
+		return true
+	}
+	return len(records) == 0 || records[len(records)-1].ID != today ||
+		time.Since(updatedAt) > intervalsMaxAge
+}
+
+// downsampleFitness averages daily records into at most points equal runs
+// of days. Each point is dated with its last day.
+func downsampleFitness(records []Fitness, points int) []FitnessPoint {
+	result := make([]FitnessPoint, 0, min(len(records), points))
+	for i := 0; i < len(records); {
+		// Spread the remainder over the first buckets
+		end := len(records) * (len(result) + 1) / min(len(records), points)
+
+		var ctl, atl float64
+		for _, r := range records[i:end] {
+			ctl += r.Ctl
+			atl += r.Atl
+		}
+		n := float64(end - i)
+		ctl, atl = round1(ctl/n), round1(atl/n)
+		result = append(result, FitnessPoint{Date: records[end-1].ID, Ctl: ctl, Atl: atl, Tsb: round1(ctl - atl)})
+		i = end
+	}
+	return result
+}
+
+// handleFitnessHistory returns the CTL, ATL and TSB series of the last
+// ?days= days, downsampled to at most ?points= points
+func handleFitnessHistory(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	days := FitnessDefaultDays
+	if v := query.Get("days"); v != "" {
+		var err error
+		days, err = strconv.Atoi(v)
+		if err != nil || days < 1 || days > FitnessMaxDays {
+			http.Error(w, fmt.Sprintf("days must be between 1 and %d", FitnessMaxDays), http.StatusBadRequest)
+			return
+		}
+	}
+
+	points := FitnessMaxPoints
+	if v := query.Get("points"); v != "" {
+		var err error
+		points, err = strconv.Atoi(v)
+		if err != nil || points < 2 || points > FitnessMaxPoints {
+			http.Error(w, "points must be between 2 and 320", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if !providesIntervals() {
+		writeNotSupported(w, "The fitness history")
+		return
+	}
+
+	// Without credentials the stored records of any athlete are served
+	athleteID := trainingAthleteID()
+
+	now := time.Now()
+	today := now.Format("2006-01-02")
+	oldest := now.AddDate(0, 0, 1-days).Format("2006-01-02")
+
+	records, updatedAt, err := GetWellness(athleteID, oldest, today)
+	if err != nil {
+		log.Printf("Error reading wellness: %v", err)
+		http.Error(w, "Failed to read fitness history", http.StatusInternalServerError)
+		return
+	}
+
+	if fitnessNeedsFetch(records, updatedAt, oldest, fitnessFetched.from(athleteID), today) {
+		if err := refreshFitness(oldest, today); err != nil {
+			log.Printf("Failed to fetch wellness from intervals.icu: %v", err)
+			if len(records) == 0 {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("X-Cache-Stale", "true")
+		} else if records, _, err = GetWellness(athleteID, oldest, today); err != nil {
+			log.Printf("Error reading wellness: %v", err)
+			http.Error(w, "Failed to read fitness history", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	response := FitnessHistory{
+		Days:   days,
+		From:   oldest,
+		To:     today,
+		Points: downsampleFitness(records, points),
+	}
+	if len(records) > 0 {
+		latest := records[len(records)-1]
+		response.Training = newTrainingState(latest.Ctl, latest.Atl, latest.RampRate)
+	}
+
+	json.NewEncoder(w).Encode(response)
+	log.Printf("[%s] GET /api/intervals/fitness?days=%d -> %d points",
+		time.Now().Format("15:04:05"), days, len(response.Points))
+}
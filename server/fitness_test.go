@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetWellnessRange(t *testing.T) {
+	useIntervalsFixtures(t)
+
+	client, err := defaultIntervalsClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := client.GetWellness("2026-01-24", "2026-02-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 10 || records[0].ID != "2026-01-24" || records[9].Atl != 68.56 {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+
+	if err := SaveWellness("i42", records); err != nil {
+		t.Fatal(err)
+	}
+	stored, updatedAt, err := GetWellness("i42", "2026-01-30", "2026-02-05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 4 || stored[0].ID != "2026-01-30" || stored[3].Ctl != 51.85 || updatedAt.IsZero() {
+		t.Errorf("stored range = %+v, %v", stored, updatedAt)
+	}
+
+	if stored, _, _ := GetWellness("i43", "2026-01-24", "2026-02-02"); len(stored) != 0 {
+		t.Errorf("other athlete got %d records", len(stored))
+	}
+}
+
+func TestDownsampleFitness(t *testing.T) {
+	var records []Fitness
+	for i := range 10 {
+		records = append(records, Fitness{ID: fmt.Sprintf("2026-01-%02d", i+1), Ctl: float64(50 + i), Atl: 40})
+	}
+
+	points := downsampleFitness(records, 4)
+	want := []FitnessPoint{
+		{Date: "2026-01-02", Ctl: 50.5, Atl: 40, Tsb: 10.5},
+		{Date: "2026-01-05", Ctl: 53, Atl: 40, Tsb: 13},
+		{Date: "2026-01-07", Ctl: 55.5, Atl: 40, Tsb: 15.5},
+		{Date: "2026-01-10", Ctl: 58, Atl: 40, Tsb: 18},
+	}
+	if len(points) != len(want) {
+		t.Fatalf("got %d points, want %d: %+v", len(points), len(want), points)
+	}
+	for i := range want {
+		if points[i] != want[i] {
+			t.Errorf("point %d = %+v, want %+v", i, points[i], want[i])
+		}
+	}
+
+	if points := downsampleFitness(records, 320); len(points) != 10 || points[9].Tsb != 19 {
+		t.Errorf("undersampled points = %+v", points)
+	}
+	if points := downsampleFitness(nil, 320); len(points) != 0 {
+		t.Errorf("points without records = %+v", points)
+	}
+}
+
+// newWellnessServer serves one wellness record per requested day from first
+// on (any day if empty) for athlete "i42", with CTL rising by one a day up to
+// 60 today
+func newWellnessServer(t *testing.T, calls *int, first string) {
+	t.Helper()
+	setupTestDB(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		oldest, _ := time.Parse("2006-01-02", r.URL.Query().Get("oldest"))
+		newest, _ := time.Parse("2006-01-02", r.URL.Query().Get("newest"))
+
+		var records []Fitness
+		for d := oldest; !d.After(newest); d = d.AddDate(0, 0, 1) {
+			if d.Format("2006-01-02") < first {
+				continue
+			}
+			daysAgo := newest.Sub(d).Hours() / 24
+			records = append(records, Fitness{ID: d.Format("2006-01-02"), Ctl: 60 - daysAgo, Atl: 65})
+		}
+		json.NewEncoder(w).Encode(records)
+	}))
+	t.Cleanup(server.Close)
+
+	previous := intervalsAPI
+	intervalsAPI = newIntervalsClient(server.URL, "i42", "secret", server.Client())
+	t.Cleanup(func() { intervalsAPI = previous })
+}
+
+func TestHandleFitnessHistory(t *testing.T) {
+	calls := 0
+	newWellnessServer(t, &calls, "")
+
+	get := func(query string) FitnessHistory {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handleFitnessHistory(rec, httptest.NewRequest("GET", "/api/intervals/fitness"+query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", query, rec.Code, rec.Body)
+		}
+		var history FitnessHistory
+		if err := json.NewDecoder(rec.Body).Decode(&history); err != nil {
+			t.Fatal(err)
+		}
+		return history
+	}
+
+	history := get("")
+	if history.Days != 42 || len(history.Points) != 42 || history.To != time.Now().Format("2006-01-02") {
+		t.Errorf("default history: %d days, %d points, to %s", history.Days, len(history.Points), history.To)
+	}
+	if history.Training == nil || history.Training.Ctl != 60 || history.Training.Tsb != -5 || history.Training.Form != StatusGreyZone {
+		t.Errorf("training = %+v", history.Training)
+	}
+
+	// The stored range answers the next poll without calling upstream
+	get("?days=30")
+	if calls != 1 {
+		t.Errorf("upstream calls = %d, want 1", calls)
+	}
+
+	// A longer range is fetched and downsampled to the pixel width
+	history = get("?days=730&points=100")
+	if calls != 2 || len(history.Points) != 100 {
+		t.Errorf("long range: %d calls, %d points", calls, len(history.Points))
+	}
+	if last := history.Points[99]; last.Date != history.To || last.Tsb > -5 || last.Tsb < -10 {
+		t.Errorf("last point = %+v", last)
+	}
+
+	// Every shorter range is sliced from the widest one fetched
+	for _, query := range []string{"?days=30", "?days=90", "?days=300"} {
+		if history := get(query); len(history.Points) != history.Days {
+			t.Errorf("%s: %d points", query, len(history.Points))
+		}
+	}
+	if calls != 2 {
+		t.Errorf("shorter ranges called upstream: %d calls", calls)
+	}
+
+	for _, query := range []string{"?days=0", "?days=731", "?points=1", "?points=abc"} {
+		rec := httptest.NewRecorder()
+		handleFitnessHistory(rec, httptest.NewRequest("GET", "/api/intervals/fitness"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", query, rec.Code)
+		}
+	}
+}
+
+func TestHandleFitnessHistoryStartsLater(t *testing.T) {
+	calls := 0
+	first := time.Now().AddDate(0, 0, -9).Format("2006-01-02")
+	newWellnessServer(t, &calls, first)
+
+	// An athlete with 10 days of history is fetched once, not on every poll
+	for range 3 {
+		rec := httptest.NewRecorder()
+		handleFitnessHistory(rec, httptest.NewRequest("GET", "/api/intervals/fitness", nil))
+		var history FitnessHistory
+		if err := json.NewDecoder(rec.Body).Decode(&history); err != nil {
+			t.Fatal(err)
+		}
+		if len(history.Points) != 10 || history.Points[0].Date != first {
+			t.Fatalf("points = %+v", history.Points)
+		}
+		if rec.Header().Get("X-Cache-Stale") != "" {
+			t.Errorf("headers %v", rec.Header())
+		}
+	}
+	if calls != 1 {
+		t.Errorf("upstream calls = %d, want 1", calls)
+	}
+}
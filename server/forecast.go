@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// openMeteoTimeLayout is the local time format of Open-Meteo hourly entries
+const openMeteoTimeLayout = "2006-01-02T15:04"
+
+// Slot specs for the legacy Forecast3h / ForecastTom fields
+const (
+	slot3h       = "+3h"
+	slotTomorrow = "tomorrow-noon"
+)
+
+// HourlyWeather is one hour of forecast, in metric units
+type HourlyWeather struct {
+	Time       time.Time `json:"time"`
+	Temp       float64   `json:"temp"`
+	Humidity   int       `json:"humidity"`
+	WindSpeed  float64   `json:"wind_speed"`
+	Precip     float64   `json:"precip"`      // mm over the hour
+	PrecipProb int       `json:"precip_prob"` // %
+	Code       int       `json:"code"`        // WMO weather code
+	IsDay      bool      `json:"is_day"`
+}
+
+// HourlyWeatherProvider is implemented by weather providers that expose their
+// hourly forecast, used for alerts and other look-ahead features
+type HourlyWeatherProvider interface {
+	GetHourly(loc Location) ([]HourlyWeather, error)
+}
+
+// getHourlyCached returns the cached hourly forecast for loc, trimmed to start
+// at the current hour
+func getHourlyCached(loc Location) ([]HourlyWeather, cacheInfo, error) {
+	provider, ok := weatherProvider.(HourlyWeatherProvider)
+	if !ok {
+		return nil, cacheInfo{}, fmt.Errorf("hourly forecast not supported by %s", weatherProvider.Name())
+	}
+
+	hours, info, err := fetchWeatherCached(hourlyWeatherSource(loc, provider))
+	if err != nil {
+		return nil, info, err
+	}
+	return hoursFrom(*hours, time.Now()), info, nil
+}
+
+// hourlyWeatherKey is the cache key of the hourly forecast of loc
+func hourlyWeatherKey(loc Location) string {
+	return "hourly:" + loc.Key
+}
+
+// hourlyWeatherSource returns the cache key and upstream call for the hourly
+// forecast of loc
+func hourlyWeatherSource(loc Location, provider HourlyWeatherProvider) (string, func() (*[]HourlyWeather, error)) {
+	return hourlyWeatherKey(loc), func() (*[]HourlyWeather, error) {
+		hours, err := provider.GetHourly(loc)
+		return &hours, err
+	}
+}
+
+// hoursFrom drops the entries that ended before now
+func hoursFrom(hours []HourlyWeather, now time.Time) []HourlyWeather {
+	for i, h := range hours {
+		if now.Before(h.Time.Add(time.Hour)) {
+			return hours[i:]
+		}
+	}
+	return nil
+}
+
+// forecastSlot is a point in time the weather payload forecasts for, parsed
+// from specs like "+3h", "tomorrow-noon" or "tomorrow-18"
+type forecastSlot struct {
+	Spec     string
+	Hours    int  // Offset from now, when not Tomorrow
+	Tomorrow bool // Fixed hour of the next local day
+	Hour     int  // Local hour for Tomorrow slots
+}
+
+// parseForecastSlot parses a forecast slot spec
+func parseForecastSlot(spec string) (forecastSlot, error) {
+	slot := forecastSlot{Spec: spec}
+
+	if rest, ok := strings.CutPrefix(spec, "tomorrow-"); ok {
+		slot.Tomorrow = true
+		if rest == "noon" {
+			slot.Hour = 12
+			return slot, nil
+		}
+		hour, err := strconv.Atoi(rest)
+		if err != nil || hour < 0 || hour > 23 {
+			return slot, fmt.Errorf("invalid forecast slot %q", spec)
+		}
+		slot.Hour = hour
+		return slot, nil
+	}
+
+	if rest, ok := strings.CutPrefix(spec, "+"); ok {
+		hours, err := strconv.Atoi(strings.TrimSuffix(rest, "h"))
+		if err != nil || !strings.HasSuffix(rest, "h") || hours < 1 || hours > 48 {
+			return slot, fmt.Errorf("invalid forecast slot %q", spec)
+		}
+		slot.Hours = hours
+		return slot, nil
+	}
+
+	return slot, fmt.Errorf("invalid forecast slot %q", spec)
+}
+
+// parseForecastSlots parses a list of slot specs
+func parseForecastSlots(specs []string) ([]forecastSlot, error) {
+	slots := make([]forecastSlot, 0, len(specs))
+	for _, spec := range specs {
+		slot, err := parseForecastSlot(spec)
+		if err != nil {
+			return nil, err
+		}
+		slots = append(slots, slot)
+	}
+	return slots, nil
+}
+
+// Target returns the instant the slot refers to, relative to now in the given zone
+func (s forecastSlot) Target(now time.Time, zone *time.Location) time.Time {
+	if !s.Tomorrow {
+		// Add works on absolute time, so "+3h" stays 3 real hours across DST changes
+		return now.Add(time.Duration(s.Hours) * time.Hour)
+	}
+
+	y, m, d := now.In(zone).Date()
+	return time.Date(y, m, d+1, s.Hour, 0, 0, 0, zone)
+}
+
+// parseHourlyTimes converts Open-Meteo local hourly timestamps into instants.
+// Entries are one real hour apart, which disambiguates the wall clock hour that
+// repeats when DST ends and skips the one missing when it starts.
+func parseHourlyTimes(times []string, zone *time.Location) ([]time.Time, error) {
+	parsed := make([]time.Time, 0, len(times))
+	for i, s := range times {
+		if i > 0 {
+			next := parsed[i-1].Add(time.Hour)
+			if next.In(zone).Format(openMeteoTimeLayout) == s {
+				parsed = append(parsed, next)
+				continue
+			}
+		}
+
+		t, err := time.ParseInLocation(openMeteoTimeLayout, s, zone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hourly time %q: %w", s, err)
+		}
+		parsed = append(parsed, t)
+	}
+	return parsed, nil
+}
+
+// hourIndex returns the index of the hourly entry covering t, or -1 when t is
+// outside the series
+func hourIndex(times []time.Time, t time.Time) int {
+	for i, start := range times {
+		if !t.Before(start) && t.Before(start.Add(time.Hour)) {
+			return i
+		}
+	}
+	return -1
+}
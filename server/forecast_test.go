@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+// loadOpenMeteoFixture decodes a canned Open-Meteo payload from testdata.
+// Fixture temperatures equal the number of hours since the first entry, so a
+// forecast's temperature tells which absolute hour it was taken from.
+func loadOpenMeteoFixture(t *testing.T, name string) *OpenMeteoResponse {
+	t.Helper()
+
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var om OpenMeteoResponse
+	if err := json.Unmarshal(data, &om); err != nil {
+		t.Fatal(err)
+	}
+	return &om
+}
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+
+	zone, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return zone
+}
+
+func TestParseForecastSlot(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    forecastSlot
+		wantErr bool
+	}{
+		{spec: "+1h", want: forecastSlot{Spec: "+1h", Hours: 1}},
+		{spec: "+6h", want: forecastSlot{Spec: "+6h", Hours: 6}},
+		{spec: "tomorrow-noon", want: forecastSlot{Spec: "tomorrow-noon", Tomorrow: true, Hour: 12}},
+		{spec: "tomorrow-18", want: forecastSlot{Spec: "tomorrow-18", Tomorrow: true, Hour: 18}},
+		{spec: "+0h", wantErr: true},
+		{spec: "+3", wantErr: true},
+		{spec: "tomorrow-25", wantErr: true},
+		{spec: "later", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseForecastSlot(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseForecastSlot(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("parseForecastSlot(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestOpenMeteoForecastSlots(t *testing.T) {
+	slots, err := parseForecastSlots([]string{"+1h", "+3h", "+6h", "tomorrow-noon"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type slotWant struct {
+		temp float64
+		time string
+	}
+
+	tests := []struct {
+		name         string
+		fixture      string
+		zone         string
+		now          time.Time
+		wantHumidity int
+		wantSlots    []slotWant
+	}{
+		{
+			// Clocks jump from 02:00 CET to 03:00 CEST; the series starts 23:00Z
+			name:         "spring forward",
+			fixture:      "openmeteo_paris_dst_spring.json",
+			zone:         "Europe/Paris",
+			now:          time.Date(2026, 3, 29, 0, 30, 0, 0, time.UTC), // 01:30 CET
+			wantHumidity: 1,
+			wantSlots: []slotWant{
+				{temp: 2, time: "03:30"},
+				{temp: 4, time: "05:30"},
+				{temp: 7, time: "08:30"},
+				{temp: 35, time: "12:00"},
+			},
+		},
+		{
+			// Clocks fall back from 03:00 CEST to 02:00 CET, so 02:xx happens twice;
+			// the series starts 22:00Z
+			name:         "fall back",
+			fixture:      "openmeteo_paris_dst_autumn.json",
+			zone:         "Europe/Paris",
+			now:          time.Date(2026, 10, 25, 0, 30, 0, 0, time.UTC), // first 02:30 CEST
+			wantHumidity: 2,
+			wantSlots: []slotWant{
+				{temp: 3, time: "02:30"}, // second 02:30, CET
+				{temp: 5, time: "04:30"},
+				{temp: 8, time: "07:30"},
+				{temp: 37, time: "12:00"},
+			},
+		},
+		{
+			// Server clock in a far away zone must not change the result
+			name:         "server zone differs",
+			fixture:      "openmeteo_paris_dst_autumn.json",
+			zone:         "Europe/Paris",
+			now:          time.Date(2026, 10, 25, 0, 30, 0, 0, time.UTC).In(time.FixedZone("JST", 9*3600)),
+			wantHumidity: 2,
+			wantSlots: []slotWant{
+				{temp: 3, time: "02:30"},
+				{temp: 5, time: "04:30"},
+				{temp: 8, time: "07:30"},
+				{temp: 37, time: "12:00"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			om := loadOpenMeteoFixture(t, tt.fixture)
+
+			weather, err := om.toWeather(tt.now, mustLoadLocation(t, tt.zone), slots)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if weather.Humidity != tt.wantHumidity {
+				t.Errorf("humidity = %d, want %d", weather.Humidity, tt.wantHumidity)
+			}
+			if len(weather.Forecasts) != len(tt.wantSlots) {
+				t.Fatalf("got %d forecasts, want %d: %+v", len(weather.Forecasts), len(tt.wantSlots), weather.Forecasts)
+			}
+			for i, want := range tt.wantSlots {
+				got := weather.Forecasts[i]
+				if got.Temp != want.temp || got.Time != want.time {
+					t.Errorf("slot %s = %.0f at %s, want %.0f at %s", got.Slot, got.Temp, got.Time, want.temp, want.time)
+				}
+			}
+			if weather.Forecast3h != weather.Forecasts[1] {
+				t.Errorf("forecast_3h = %+v, want the +3h slot", weather.Forecast3h)
+			}
+			if weather.ForecastTom != weather.Forecasts[3] {
+				t.Errorf("forecast_tom = %+v, want the tomorrow-noon slot", weather.ForecastTom)
+			}
+		})
+	}
+}
+
+func TestOpenMeteoShortArrays(t *testing.T) {
+	// The fixture has 48 hourly temperatures but only 20 weather codes
+	om := loadOpenMeteoFixture(t, "openmeteo_newyork_short.json")
+
+	slots, err := parseForecastSlots([]string{"+6h", "+12h", "tomorrow-noon"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2026, 6, 1, 14, 0, 0, 0, time.UTC) // 10:00 EDT, index 10
+	weather, err := om.toWeather(now, mustLoadLocation(t, "America/New_York"), slots)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(weather.Forecasts) != 1 || weather.Forecasts[0].Temp != 16 {
+		t.Errorf("expected only the +6h slot, got %+v", weather.Forecasts)
+	}
+	if weather.ForecastTom != (Forecast{}) {
+		t.Errorf("forecast_tom past the weather codes should be empty, got %+v", weather.ForecastTom)
+	}
+}
+
+func TestParseHourlyTimesOutsideSeries(t *testing.T) {
+	zone := mustLoadLocation(t, "Europe/Paris")
+	times, err := parseHourlyTimes([]string{"2026-01-01T00:00", "2026-01-01T01:00"}, zone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if idx := hourIndex(times, time.Date(2026, 1, 1, 5, 0, 0, 0, zone)); idx != -1 {
+		t.Errorf("hourIndex past the series = %d, want -1", idx)
+	}
+	if _, err := parseHourlyTimes([]string{"yesterday"}, zone); err == nil {
+		t.Errorf("expected error for invalid time")
+	}
+}
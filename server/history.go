@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Weather history limits
+const (
+	HistoryDefaultHours = 48
+	HistoryMaxHours     = 7 * 24
+	HistoryMaxPoints    = 320 // One point per pixel of the device screen
+)
+
+// WeatherObservation is a recorded snapshot of the current weather
+type WeatherObservation struct {
+	Time      time.Time
+	Temp      float64
+	Humidity  int
+	WindSpeed float64
+	Code      int
+}
+
+// WeatherHistory is the response for the weather history endpoint
+type WeatherHistory struct {
+	Location string         `json:"location"`
+	Hours    int            `json:"hours"`
+	Points   []HistoryPoint `json:"points"`
+	TempMin  float64        `json:"temp_min"`
+	TempMax  float64        `json:"temp_max"`
+
+	// Delta24h is the current temperature minus the one 24 hours earlier,
+	// omitted when there is no observation from yesterday
+	Delta24h *float64 `json:"delta_24h,omitempty"`
+	Trend    string   `json:"trend,omitempty"` // "warmer", "colder" or "same"
+
+	Units UnitLabels `json:"units"`
+}
+
+// HistoryPoint is one chart point; keys are short to keep 320 points small
+type HistoryPoint struct {
+	Time      int64   `json:"t"` // Unix seconds
+	Temp      float64 `json:"temp"`
+	Humidity  int     `json:"hum"`
+	WindSpeed float64 `json:"wind"`
+	Code      int     `json:"code"`
+}
+
+// recordWeather stores an observation for every configured location. It goes
+// through the weather cache, so it shares upstream calls with device polls.
+func recordWeather() error {
+	var errs []error
+	for key := range config.Weather.Locations {
+		loc, err := config.GetLocation(key)
+		if err != nil {
+			continue
+		}
+
+		weather, info, err := fetchWeatherCached(currentWeatherSource(loc))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to record weather for %s: %w", loc.Name, err))
+			continue
+		}
+		if info.Stale {
+			log.Printf("Skipping weather history for %s, upstream unavailable", loc.Name)
+			continue
+		}
+
+		obs := WeatherObservation{
+			Time:      time.Now().Add(-info.Age),
+			Temp:      weather.Temp,
+			Humidity:  weather.Humidity,
+			WindSpeed: weather.WindSpeed,
+			Code:      weather.Code,
+		}
+		if err := SaveWeatherObservation(loc.Key, obs); err != nil {
+			errs = append(errs, fmt.Errorf("failed to save weather history for %s: %w", loc.Name, err))
+		}
+	}
+
+	if err := PruneWeatherHistory(time.Now().Add(-config.Weather.HistoryRetention.Duration)); err != nil {
+		errs = append(errs, fmt.Errorf("failed to prune weather history: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// downsampleHistory averages observations into at most points equal time
+// buckets between since and until. Each bucket keeps its last weather code.
+func downsampleHistory(history []WeatherObservation, since, until time.Time, points int) []HistoryPoint {
+	if len(history) <= points {
+		result := make([]HistoryPoint, 0, len(history))
+		for _, obs := range history {
+			result = append(result, HistoryPoint{
+				Time:      obs.Time.Unix(),
+				Temp:      obs.Temp,
+				Humidity:  obs.Humidity,
+				WindSpeed: obs.WindSpeed,
+				Code:      obs.Code,
+			})
+		}
+		return result
+	}
+
+	bucketSize := until.Sub(since) / time.Duration(points)
+	bucketOf := func(obs WeatherObservation) int {
+		return min(int(obs.Time.Sub(since)/bucketSize), points-1)
+	}
+
+	var result []HistoryPoint
+	for start := 0; start < len(history); {
+		bucket := bucketOf(history[start])
+
+		end := start
+		var temp, wind, humidity, t float64
+		for end < len(history) && bucketOf(history[end]) == bucket {
+			temp += history[end].Temp
+			wind += history[end].WindSpeed
+			humidity += float64(history[end].Humidity)
+			t += float64(history[end].Time.Unix())
+			end++
+		}
+
+		n := float64(end - start)
+		result = append(result, HistoryPoint{
+			Time:      int64(t / n),
+			Temp:      math.Round(temp/n*10) / 10,
+			Humidity:  int(math.Round(humidity / n)),
+			WindSpeed: math.Round(wind/n*10) / 10,
+			Code:      history[end-1].Code,
+		})
+		start = end
+	}
+	return result
+}
+
+// temperatureDelta24h compares the latest observation with the one closest
+// to 24 hours before it, within an hour
+func temperatureDelta24h(history []WeatherObservation) (float64, bool) {
+	if len(history) == 0 {
+		return 0, false
+	}
+
+	latest := history[len(history)-1]
+	target := latest.Time.Add(-24 * time.Hour)
+
+	idx := sort.Search(len(history), func(i int) bool {
+		return !history[i].Time.Before(target)
+	})
+
+	best := -1
+	bestDiff := time.Hour + 1
+	for _, i := range []int{idx - 1, idx} {
+		if i < 0 || i >= len(history) {
+			continue
+		}
+		diff := history[i].Time.Sub(target).Abs()
+		if diff < bestDiff {
+			best, bestDiff = i, diff
+		}
+	}
+	if best < 0 || bestDiff > time.Hour {
+		return 0, false
+	}
+
+	return math.Round((latest.Temp-history[best].Temp)*10) / 10, true
+}
+
+// handleWeatherHistory returns recorded observations for the last ?hours=
+// hours, downsampled to at most ?points= points
+func handleWeatherHistory(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	loc, err := config.GetLocation(query.Get("loc"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	units, err := unitsFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hours := HistoryDefaultHours
+	if v := query.Get("hours"); v != "" {
+		hours, err = strconv.Atoi(v)
+		if err != nil || hours < 1 || hours > HistoryMaxHours {
+			http.Error(w, "hours must be between 1 and 168", http.StatusBadRequest)
+			return
+		}
+	}
+
+	points := HistoryMaxPoints
+	if v := query.Get("points"); v != "" {
+		points, err = strconv.Atoi(v)
+		if err != nil || points < 2 || points > HistoryMaxPoints {
+			http.Error(w, "points must be between 2 and 320", http.StatusBadRequest)
+			return
+		}
+	}
+
+	now := time.Now()
+	since := now.Add(-time.Duration(hours) * time.Hour)
+
+	// At least 25 hours are read so the 24 hour delta is always available
+	readSince := since
+	if hours < 25 {
+		readSince = now.Add(-25 * time.Hour)
+	}
+	history, err := GetWeatherHistory(loc.Key, readSince)
+	if err != nil {
+		log.Printf("Error reading weather history: %v", err)
+		http.Error(w, "Failed to read weather history", http.StatusInternalServerError)
+		return
+	}
+
+	response := WeatherHistory{
+		Location: loc.Name,
+		Hours:    hours,
+		Units:    units.Labels(),
+	}
+
+	if delta, ok := temperatureDelta24h(history); ok {
+		converted := units.TempDelta(delta)
+		response.Delta24h = &converted
+		switch {
+		case delta >= 1:
+			response.Trend = "warmer"
+		case delta <= -1:
+			response.Trend = "colder"
+		default:
+			response.Trend = "same"
+		}
+	}
+
+	// Drop the extra observations read for the delta
+	start := sort.Search(len(history), func(i int) bool {
+		return !history[i].Time.Before(since)
+	})
+	history = history[start:]
+
+	response.Points = downsampleHistory(history, since, now, points)
+	for i := range response.Points {
+		p := &response.Points[i]
+		p.Temp = units.Temp(p.Temp)
+		p.WindSpeed = units.Speed(p.WindSpeed)
+
+		if i == 0 || p.Temp < response.TempMin {
+			response.TempMin = p.Temp
+		}
+		if i == 0 || p.Temp > response.TempMax {
+			response.TempMax = p.Temp
+		}
+	}
+	if response.Points == nil {
+		response.Points = []HistoryPoint{}
+	}
+
+	json.NewEncoder(w).Encode(response)
+	log.Printf("[%s] GET /api/weather/history?hours=%d -> %d points for %s",
+		time.Now().Format("15:04:05"), hours, len(response.Points), loc.Name)
+}
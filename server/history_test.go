@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDownsampleHistory(t *testing.T) {
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := since.Add(10 * time.Hour)
+
+	// One observation every 15 minutes, temperature rising by one per hour
+	var history []WeatherObservation
+	for i := 0; i < 40; i++ {
+		history = append(history, WeatherObservation{
+			Time: since.Add(time.Duration(i) * 15 * time.Minute),
+			Temp: float64(i) / 4,
+			Code: i,
+		})
+	}
+
+	points := downsampleHistory(history, since, until, 10)
+	if len(points) != 10 {
+		t.Fatalf("got %d points, want 10", len(points))
+	}
+	first := points[0]
+	if first.Temp != 0.4 || first.Code != 3 || first.Time != since.Add(22*time.Minute+30*time.Second).Unix() {
+		t.Errorf("first point = %+v", first)
+	}
+
+	if got := downsampleHistory(history, since, until, 320); len(got) != 40 {
+		t.Errorf("got %d points, want all 40 observations", len(got))
+	}
+}
+
+func TestTemperatureDelta24h(t *testing.T) {
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+
+	history := []WeatherObservation{
+		{Time: now.Add(-24*time.Hour - 20*time.Minute), Temp: 8},
+		{Time: now.Add(-12 * time.Hour), Temp: 5},
+		{Time: now, Temp: 11.5},
+	}
+	if delta, ok := temperatureDelta24h(history); !ok || delta != 3.5 {
+		t.Errorf("delta = %v, %v, want 3.5", delta, ok)
+	}
+
+	if _, ok := temperatureDelta24h(history[1:]); ok {
+		t.Errorf("expected no delta without an observation from yesterday")
+	}
+}
+
+func TestHandleWeatherHistory(t *testing.T) {
+	setupTestDB(t)
+
+	now := time.Now()
+	for h := 30; h >= 0; h-- {
+		obs := WeatherObservation{Time: now.Add(-time.Duration(h) * time.Hour), Temp: 10, Humidity: 50}
+		if h == 0 {
+			obs.Temp = 7
+		}
+		if err := SaveWeatherObservation("aix", obs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := SaveWeatherObservation("office", WeatherObservation{Time: now, Temp: 30}); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	handleWeatherHistory(rec, httptest.NewRequest("GET", "/api/weather/history?hours=6", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	var history WeatherHistory
+	if err := json.NewDecoder(rec.Body).Decode(&history); err != nil {
+		t.Fatal(err)
+	}
+
+	// Observations at -5h .. 0h; the one exactly 6h ago may fall either side of the window
+	if len(history.Points) < 6 || len(history.Points) > 7 {
+		t.Errorf("got %d points, want 6 or 7", len(history.Points))
+	}
+	if history.TempMin != 7 || history.TempMax != 10 {
+		t.Errorf("temp range = %v..%v, want 7..10", history.TempMin, history.TempMax)
+	}
+	if history.Delta24h == nil || *history.Delta24h != -3 || history.Trend != "colder" {
+		t.Errorf("delta = %v, trend = %q, want -3 colder", history.Delta24h, history.Trend)
+	}
+
+	rec = httptest.NewRecorder()
+	handleWeatherHistory(rec, httptest.NewRequest("GET", "/api/weather/history?hours=1000", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("hours=1000 status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestRecordWeather(t *testing.T) {
+	useFakeWeather(t)
+
+	if err := recordWeather(); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := GetWeatherHistory("aix", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].Temp != 18.5 {
+		t.Errorf("recorded history = %+v", history)
+	}
+}
+
+func TestHistoryRetentionValidated(t *testing.T) {
+	for _, retention := range []time.Duration{0, -time.Hour} {
+		cfg := DefaultConfig()
+		cfg.Weather.HistoryRetention = Duration{retention}
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("history_retention %v accepted", retention)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Weather icon sizes (square, RGB565 format = 2 bytes per pixel)
+const (
+	IconDefaultSize = 64
+	IconMinSize     = 16
+	IconMaxSize     = 128
+)
+
+// Weather icon kinds
+const (
+	IconClearDay          = "clear_day"
+	IconClearNight        = "clear_night"
+	IconPartlyCloudyDay   = "partly_cloudy_day"
+	IconPartlyCloudyNight = "partly_cloudy_night"
+	IconCloudy            = "cloudy"
+	IconFog               = "fog"
+	IconDrizzle           = "drizzle"
+	IconRain              = "rain"
+	IconSnow              = "snow"
+	IconShowers           = "showers"
+	IconStorm             = "storm"
+)
+
+// weatherIconKinds lists every icon generateWeatherIcon can draw
+var weatherIconKinds = map[string]bool{
+	IconClearDay: true, IconClearNight: true, IconPartlyCloudyDay: true, IconPartlyCloudyNight: true,
+	IconCloudy: true, IconFog: true, IconDrizzle: true, IconRain: true, IconSnow: true,
+	IconShowers: true, IconStorm: true,
+}
+
+// Icon colors (RGB565)
+const (
+	iconSun        = 0xFE60 // Orange-yellow
+	iconMoon       = 0xEF5D // Pale gray
+	iconCloud      = 0xDEFB // Light gray
+	iconCloudDark  = 0x7BEF // Gray
+	iconRain       = 0x249F // Blue
+	iconSnow       = 0xFFFF // White
+	iconFog        = 0xA514 // Mid gray
+	iconLightning  = 0xFFE0 // Yellow
+	iconBackground = 0x0000 // Black, same as the device background
+)
+
+// WeatherIconResponse is the JSON response for the weather icon endpoint
+type WeatherIconResponse struct {
+	Icon      string `json:"icon"`
+	Image     string `json:"image"` // Base64 RGB565 data
+	ImgWidth  int    `json:"img_width"`
+	ImgHeight int    `json:"img_height"`
+}
+
+// weatherIconKind picks the icon for a WMO weather code from its category
+func weatherIconKind(code int, isDay bool) string {
+	switch describeWeatherCode(code, isDay).Category {
+	case CategoryClear:
+		if isDay {
+			return IconClearDay
+		}
+		return IconClearNight
+	case CategoryPartlyCloudy:
+		if isDay {
+			return IconPartlyCloudyDay
+		}
+		return IconPartlyCloudyNight
+	case CategoryFog:
+		return IconFog
+	case CategoryDrizzle:
+		return IconDrizzle
+	case CategoryRain:
+		return IconRain
+	case CategorySnow:
+		return IconSnow
+	case CategoryShowers:
+		return IconShowers
+	case CategoryStorm:
+		return IconStorm
+	default:
+		return IconCloudy
+	}
+}
+
+// iconCanvas draws shapes on a square RGB565 image. Coordinates are relative
+// to the icon size (0.0 - 1.0) so every icon scales to any size.
+type iconCanvas struct {
+	size   int
+	pixels []uint16
+}
+
+func newIconCanvas(size int) *iconCanvas {
+	c := &iconCanvas{size: size, pixels: make([]uint16, size*size)}
+	for i := range c.pixels {
+		c.pixels[i] = iconBackground
+	}
+	return c
+}
+
+// fill colors every pixel whose center satisfies inside
+func (c *iconCanvas) fill(color uint16, inside func(x, y float64) bool) {
+	for py := 0; py < c.size; py++ {
+		for px := 0; px < c.size; px++ {
+			x := (float64(px) + 0.5) / float64(c.size)
+			y := (float64(py) + 0.5) / float64(c.size)
+			if inside(x, y) {
+				c.pixels[py*c.size+px] = color
+			}
+		}
+	}
+}
+
+func (c *iconCanvas) circle(cx, cy, r float64, color uint16) {
+	c.fill(color, func(x, y float64) bool {
+		return (x-cx)*(x-cx)+(y-cy)*(y-cy) < r*r
+	})
+}
+
+func (c *iconCanvas) rect(x0, y0, x1, y1 float64, color uint16) {
+	c.fill(color, func(x, y float64) bool {
+		return x >= x0 && x < x1 && y >= y0 && y < y1
+	})
+}
+
+// line draws a segment of the given width
+func (c *iconCanvas) line(x0, y0, x1, y1, width float64, color uint16) {
+	dx, dy := x1-x0, y1-y0
+	length2 := dx*dx + dy*dy
+	c.fill(color, func(x, y float64) bool {
+		t := ((x-x0)*dx + (y-y0)*dy) / length2
+		t = math.Max(0, math.Min(1, t))
+		px, py := x0+t*dx-x, y0+t*dy-y
+		return px*px+py*py < width*width/4
+	})
+}
+
+func (c *iconCanvas) triangle(x0, y0, x1, y1, x2, y2 float64, color uint16) {
+	side := func(ax, ay, bx, by, x, y float64) float64 {
+		return (x-bx)*(ay-by) - (ax-bx)*(y-by)
+	}
+	c.fill(color, func(x, y float64) bool {
+		d0 := side(x, y, x0, y0, x1, y1)
+		d1 := side(x, y, x1, y1, x2, y2)
+		d2 := side(x, y, x2, y2, x0, y0)
+		hasNeg := d0 < 0 || d1 < 0 || d2 < 0
+		hasPos := d0 > 0 || d1 > 0 || d2 > 0
+		return !(hasNeg && hasPos)
+	})
+}
+
+func (c *iconCanvas) sun(cx, cy, r float64) {
+	for i := 0; i < 8; i++ {
+		angle := float64(i) * math.Pi / 4
+		c.line(cx+math.Cos(angle)*r*1.35, cy+math.Sin(angle)*r*1.35,
+			cx+math.Cos(angle)*r*1.75, cy+math.Sin(angle)*r*1.75, r*0.25, iconSun)
+	}
+	c.circle(cx, cy, r, iconSun)
+}
+
+func (c *iconCanvas) moon(cx, cy, r float64) {
+	c.circle(cx, cy, r, iconMoon)
+	c.circle(cx+r*0.45, cy-r*0.3, r*0.8, iconBackground)
+}
+
+// cloud draws a cloud whose flat base spans x0-x1 at y
+func (c *iconCanvas) cloud(x0, x1, y float64, color uint16) {
+	w := x1 - x0
+	c.circle(x0+w*0.25, y-w*0.16, w*0.22, color)
+	c.circle(x0+w*0.55, y-w*0.28, w*0.3, color)
+	c.circle(x0+w*0.8, y-w*0.14, w*0.2, color)
+	c.rect(x0+w*0.08, y-w*0.18, x1-w*0.05, y, color)
+}
+
+// drops draws slanted rain streaks under a cloud
+func (c *iconCanvas) drops(y, length, width float64) {
+	for _, x := range []float64{0.3, 0.5, 0.7} {
+		c.line(x, y, x-length*0.3, y+length, width, iconRain)
+	}
+}
+
+func (c *iconCanvas) rgb565() []byte {
+	// Write RGB565 color (little-endian for ESP32)
+	data := make([]byte, len(c.pixels)*2)
+	for i, color := range c.pixels {
+		data[i*2] = byte(color & 0xFF)
+		data[i*2+1] = byte(color >> 8)
+	}
+	return data
+}
+
+// generateWeatherIcon renders a size x size RGB565 icon of the given kind
+func generateWeatherIcon(kind string, size int) []byte {
+	c := newIconCanvas(size)
+
+	switch kind {
+	case IconClearDay:
+		c.sun(0.5, 0.5, 0.22)
+	case IconClearNight:
+		c.moon(0.5, 0.5, 0.3)
+	case IconPartlyCloudyDay:
+		c.sun(0.62, 0.38, 0.16)
+		c.cloud(0.12, 0.78, 0.78, iconCloud)
+	case IconPartlyCloudyNight:
+		c.moon(0.62, 0.36, 0.2)
+		c.cloud(0.12, 0.78, 0.78, iconCloud)
+	case IconFog:
+		c.cloud(0.15, 0.85, 0.5, iconCloudDark)
+		for _, y := range []float64{0.62, 0.74, 0.86} {
+			c.line(0.15, y, 0.85, y, 0.06, iconFog)
+		}
+	case IconDrizzle:
+		c.cloud(0.12, 0.88, 0.6, iconCloud)
+		for _, p := range [][2]float64{{0.3, 0.72}, {0.5, 0.78}, {0.7, 0.72}, {0.4, 0.88}, {0.6, 0.9}} {
+			c.circle(p[0], p[1], 0.03, iconRain)
+		}
+	case IconRain:
+		c.cloud(0.12, 0.88, 0.6, iconCloudDark)
+		c.drops(0.68, 0.22, 0.06)
+	case IconSnow:
+		c.cloud(0.12, 0.88, 0.6, iconCloud)
+		for _, p := range [][2]float64{{0.3, 0.72}, {0.5, 0.8}, {0.7, 0.72}, {0.38, 0.9}, {0.62, 0.9}} {
+			c.line(p[0]-0.04, p[1], p[0]+0.04, p[1], 0.025, iconSnow)
+			c.line(p[0], p[1]-0.04, p[0], p[1]+0.04, 0.025, iconSnow)
+		}
+	case IconShowers:
+		c.sun(0.66, 0.3, 0.13)
+		c.cloud(0.1, 0.8, 0.6, iconCloud)
+		c.drops(0.68, 0.18, 0.05)
+	case IconStorm:
+		c.cloud(0.12, 0.88, 0.55, iconCloudDark)
+		c.triangle(0.52, 0.5, 0.36, 0.74, 0.5, 0.74, iconLightning)
+		c.triangle(0.46, 0.7, 0.6, 0.7, 0.42, 0.95, iconLightning)
+	default: // cloudy
+		c.cloud(0.1, 0.9, 0.72, iconCloud)
+	}
+
+	return c.rgb565()
+}
+
+// GetWeatherIcon returns the icon data for the given kind as base64
+func GetWeatherIcon(kind string, size int) (string, int, int) {
+	icon := generateWeatherIcon(kind, size)
+	encoded := base64.StdEncoding.EncodeToString(icon)
+	return encoded, size, size
+}
+
+// GetWeatherIconRaw returns raw icon bytes (for direct binary transfer)
+func GetWeatherIconRaw(kind string, size int) ([]byte, int, int) {
+	icon := generateWeatherIcon(kind, size)
+	return icon, size, size
+}
+
+// handleWeatherIcon renders the icon for ?code= and ?is_day= (or ?icon=) at
+// ?size= pixels, as base64 JSON or, with ?format=raw, as raw RGB565 bytes
+func handleWeatherIcon(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	kind := query.Get("icon")
+	if kind == "" {
+		code, err := strconv.Atoi(query.Get("code"))
+		if err != nil {
+			http.Error(w, "code or icon is required", http.StatusBadRequest)
+			return
+		}
+		kind = weatherIconKind(code, query.Get("is_day") != "0")
+	}
+	if !weatherIconKinds[kind] {
+		http.Error(w, "unknown icon "+kind, http.StatusBadRequest)
+		return
+	}
+
+	size := IconDefaultSize
+	if v := query.Get("size"); v != "" {
+		var err error
+		size, err = strconv.Atoi(v)
+		if err != nil || size < IconMinSize || size > IconMaxSize {
+			http.Error(w, "size must be between 16 and 128", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if query.Get("format") == "raw" {
+		icon, width, height := GetWeatherIconRaw(kind, size)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("X-Image-Width", strconv.Itoa(width))
+		w.Header().Set("X-Image-Height", strconv.Itoa(height))
+		w.Write(icon)
+	} else {
+		image, width, height := GetWeatherIcon(kind, size)
+		json.NewEncoder(w).Encode(WeatherIconResponse{
+			Icon:      kind,
+			Image:     image,
+			ImgWidth:  width,
+			ImgHeight: height,
+		})
+	}
+
+	log.Printf("[%s] GET /api/weather/icon -> %s (%dx%d)",
+		time.Now().Format("15:04:05"), kind, size, size)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWeatherIconKind(t *testing.T) {
+	tests := []struct {
+		code  int
+		isDay bool
+		want  string
+	}{
+		{0, true, IconClearDay},
+		{0, false, IconClearNight},
+		{2, false, IconPartlyCloudyNight},
+		{3, true, IconCloudy},
+		{48, true, IconFog},
+		{57, true, IconDrizzle},
+		{66, true, IconRain},
+		{86, true, IconSnow},
+		{81, false, IconShowers},
+		{99, true, IconStorm},
+		{-1, true, IconCloudy},
+	}
+
+	for _, tt := range tests {
+		if got := weatherIconKind(tt.code, tt.isDay); got != tt.want {
+			t.Errorf("weatherIconKind(%d, %v) = %s, want %s", tt.code, tt.isDay, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateWeatherIcon(t *testing.T) {
+	for kind := range weatherIconKinds {
+		for _, size := range []int{IconMinSize, 48, IconMaxSize} {
+			icon := generateWeatherIcon(kind, size)
+			if len(icon) != size*size*2 {
+				t.Fatalf("%s at %d: got %d bytes, want %d", kind, size, len(icon), size*size*2)
+			}
+
+			drawn := 0
+			for i := 0; i < len(icon); i += 2 {
+				if icon[i] != 0 || icon[i+1] != 0 {
+					drawn++
+				}
+			}
+			if drawn < size*size/20 {
+				t.Errorf("%s at %d: only %d pixels drawn", kind, size, drawn)
+			}
+		}
+	}
+}
+
+func TestHandleWeatherIcon(t *testing.T) {
+	rec := httptest.NewRecorder()
+	handleWeatherIcon(rec, httptest.NewRequest("GET", "/api/weather/icon?code=0&is_day=0&size=32", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	var response WeatherIconResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	image, err := base64.StdEncoding.DecodeString(response.Image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Icon != IconClearNight || response.ImgWidth != 32 || len(image) != 32*32*2 {
+		t.Errorf("unexpected icon %s %dx%d, %d bytes", response.Icon, response.ImgWidth, response.ImgHeight, len(image))
+	}
+
+	rec = httptest.NewRecorder()
+	handleWeatherIcon(rec, httptest.NewRequest("GET", "/api/weather/icon?icon=storm&format=raw", nil))
+	if rec.Header().Get("Content-Type") != "application/octet-stream" || rec.Body.Len() != IconDefaultSize*IconDefaultSize*2 {
+		t.Errorf("raw icon: content type %q, %d bytes", rec.Header().Get("Content-Type"), rec.Body.Len())
+	}
+
+	for _, query := range []string{"", "?code=61&size=512", "?icon=tornado"} {
+		rec = httptest.NewRecorder()
+		handleWeatherIcon(rec, httptest.NewRequest("GET", "/api/weather/icon"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: status = %d, want %d", query, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
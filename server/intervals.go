@@ -1,11 +1,18 @@
 package main
 
 import (
+	"bytes"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -14,7 +21,7 @@ const (
 	INTERVALS_ID      = "INTERVALS_ID"
 )
 
-type intervals struct{}
+const intervalsBaseURL = "https://intervals.icu/api/v1"
 
 type Activity struct {
 	ID                     string  `json:"id"`
@@ -303,14 +310,28 @@ type DisplayData struct {
 	Atl      float64 `json:"atl"`
 	RampRate float64 `json:"rampRate"`
 
+	// Status classifies the form and ramp rate for the CTL/ATL row
+	Status *TrainingStatus `json:"status,omitempty"`
+
 	// Activities
 	Activities []MinimumActivity `json:"activities"`
+
+	// WeekZones is the time-in-zone of the last 7 days of activities
+	WeekZones *ZoneBreakdown `json:"week_zones,omitempty"`
+
+	// Weather alerts banner
+	Alerts []Alert `json:"alerts,omitempty"`
+
+	// Units labels the display fields of the activities
+	Units UnitLabels `json:"units"`
 }
 
 type MinimumActivity struct {
 	ID                  string  `json:"id"`
-	Distance            float64 `json:"distance"`
-	MovingTime          float64 `json:"moving_time"`
+	Distance            float64 `json:"distance"`            // Meters, the firmware converts it
+	MovingTime          float64 `json:"moving_time"`         // Seconds, the firmware converts it
+	DistanceDisplay     float64 `json:"distance_display"`    // km or mi
+	MovingTimeDisplay   string  `json:"moving_time_display"` // "1:05"
 	StartDateLocal      string  `json:"start_date_local"`
 	IcuAverageWatts     float64 `json:"icu_average_watts"`
 	IcuWeightedAvgWatts float64 `json:"icu_weighted_avg_watts"`
@@ -318,99 +339,338 @@ type MinimumActivity struct {
 	AvgLrBalance        float64 `json:"avg_lr_balance"`
 	IcuRollingFtp       float64 `json:"icu_rolling_ftp"`
 	Calories            float64 `json:"calories"`
+
+	// Zones is the time-in-zone, omitted for activities without zone data
+	Zones *ZoneBreakdown `json:"zones,omitempty"`
+}
+
+// intervalsClient talks to the intervals.icu API for one athlete
+type intervalsClient struct {
+	baseURL   string
+	athleteID string
+	apiKey    string
+	client    *http.Client
+	retry     retryPolicy
+	breaker   *circuitBreaker
+}
+
+// newIntervalsClient creates a client with the configured retry policy and
+// circuit breaker
+func newIntervalsClient(baseURL, athleteID, apiKey string, client *http.Client) *intervalsClient {
+	cfg := config.Intervals
+	return &intervalsClient{
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		athleteID: athleteID,
+		apiKey:    apiKey,
+		client:    client,
+		retry: retryPolicy{
+			Attempts:  cfg.Retries + 1,
+			BaseDelay: cfg.RetryDelay.Duration,
+			MaxDelay:  upstreamMaxRetryDelay,
+		},
+		breaker: newCircuitBreaker("intervals.icu", cfg.BreakerFailures, cfg.BreakerCooldown.Duration),
+	}
 }
 
-func (i *intervals) makeRequest(url string, target any) error {
+// errNotSupported marks data the configured training provider does not have
+var errNotSupported = errors.New("not supported by the training provider")
+
+// providesIntervals reports whether intervals.icu is the configured training
+// provider. The fitness history, calendar, power curves and wellness
+// check-ins only exist there.
+func providesIntervals() bool {
+	return config.TrainingProvider == "" || config.TrainingProvider == ProviderIntervals
+}
+
+// intervalsProvider returns the intervals.icu client when it is the
+// configured training provider, and errNotSupported otherwise
+func intervalsProvider() (*intervalsClient, error) {
+	if !providesIntervals() {
+		return nil, fmt.Errorf("%w %q", errNotSupported, config.TrainingProvider)
+	}
+	return defaultIntervalsClient()
+}
+
+// writeNotSupported answers that the configured training provider does not
+// have what was asked for
+func writeNotSupported(w http.ResponseWriter, what string) {
+	http.Error(w, fmt.Sprintf("%s is not supported by the %s training provider", what, config.TrainingProvider),
+		http.StatusNotImplemented)
+}
+
+var (
+	intervalsMu  sync.Mutex
+	intervalsAPI *intervalsClient // Created on first use, see defaultIntervalsClient
+)
+
+// defaultIntervalsClient returns the shared client for the configured API,
+// reading the credentials from KWallet once. A failed read is retried on the
+// next call.
+func defaultIntervalsClient() (*intervalsClient, error) {
+	intervalsMu.Lock()
+	defer intervalsMu.Unlock()
+
+	if intervalsAPI != nil {
+		return intervalsAPI, nil
+	}
+
+	athleteID, err := GetSecret(INTERVALS_ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get athlete ID: %w", err)
+	}
 	apiKey, err := GetSecret(INTERVALS_API_KEY)
 	if err != nil {
-		return fmt.Errorf("failed to get API key: %w", err)
+		return nil, fmt.Errorf("failed to get API key: %w", err)
 	}
 
-	req, err := http.NewRequest("GET", url, nil)
+	intervalsAPI = newIntervalsClient(config.Intervals.BaseURL, athleteID, apiKey,
+		&http.Client{Timeout: config.Intervals.Timeout.Duration})
+	registerBreaker(intervalsAPI.breaker)
+	return intervalsAPI, nil
+}
+
+// Name returns the provider name used in the config
+func (c *intervalsClient) Name() string {
+	return ProviderIntervals
+}
+
+// AthleteID returns the athlete the client reads
+func (c *intervalsClient) AthleteID() string {
+	return c.athleteID
+}
+
+// get requests path below the athlete, e.g. "/activities", and decodes the
+// response into target
+func (c *intervalsClient) get(path string, query url.Values, target any) error {
+	return c.do("GET", path, query, nil, target)
+}
+
+// put sends body as JSON to path below the athlete. The PUTs of the API are
+// idempotent, so they are retried like reads.
+func (c *intervalsClient) put(path string, body any) error {
+	payload, err := json.Marshal(body)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return &upstreamError{Kind: ErrorRejected, Err: fmt.Errorf("failed to encode request: %w", err)}
 	}
+	return c.do("PUT", path, nil, payload, nil)
+}
 
-	req.SetBasicAuth("API_KEY", apiKey)
+// do requests path below the athlete and decodes the response into target,
+// unless nil. Transient failures are retried, and nothing is sent while the
+// circuit breaker is open.
+func (c *intervalsClient) do(method, path string, query url.Values, body []byte, target any) error {
+	endpoint := fmt.Sprintf("%s/athlete/%s%s", c.baseURL, url.PathEscape(c.athleteID), path)
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	if err := c.breaker.allow(); err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := c.doOnce(method, endpoint, body, target)
+		if err == nil {
+			c.breaker.record(nil)
+			return nil
+		}
+
+		wait, retry := c.retry.delay(attempt, err)
+		if !retry {
+			c.breaker.record(err)
+			log.Printf("intervals.icu %s %s failed after %d attempt(s): %v", method, path, attempt, err)
+			return err
+		}
+		log.Printf("intervals.icu %s %s failed (attempt %d/%d), retrying in %v: %v",
+			method, path, attempt, c.retry.Attempts, wait.Round(time.Millisecond), err)
+		time.Sleep(wait)
+	}
+}
+
+// doOnce performs a single request, returning a classified *upstreamError
+func (c *intervalsClient) doOnce(method, endpoint string, body []byte, target any) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, endpoint, reader)
 	if err != nil {
-		return fmt.Errorf("failed to perform request: %w", err)
+		return &upstreamError{Kind: ErrorRejected, Err: fmt.Errorf("failed to create request: %w", err)}
+	}
+
+	req.SetBasicAuth("API_KEY", c.apiKey)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return &upstreamError{Kind: ErrorTransient, Err: fmt.Errorf("failed to perform request: %w", err)}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return classifyResponse(resp, body)
 	}
 
+	if target == nil {
+		return nil
+	}
 	if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
-		return fmt.Errorf("failed to decode response: %w", err)
+		return &upstreamError{Kind: ErrorDecode, Status: resp.StatusCode, Err: fmt.Errorf("failed to decode response: %w", err)}
 	}
 
 	return nil
 }
 
-func (i *intervals) GetActivities() ([]Activity, error) {
-	athleteID, err := GetSecret(INTERVALS_ID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get athlete ID: %w", err)
-	}
-
-	now := time.Now()
-	oldest := now.AddDate(0, 0, -14).Format("2006-01-02")
+// activityFields are the Activity fields the server reads. Only these are
+// requested, the two weeks of activities would otherwise come with every
+// stream summary, zone definition and interval.
+var activityFields = []string{
+	"id", "name", "type", "start_date_local", "distance", "moving_time",
+	"icu_average_watts", "icu_weighted_avg_watts", "average_heartrate", "avg_lr_balance",
+	"calories", "icu_training_load", "paired_event_id",
+	"icu_rolling_ftp", "icu_rolling_cp", "icu_rolling_w_prime", "icu_rolling_p_max",
+	"icu_zone_times", "icu_hr_zone_times", "polarization_index", "icu_achievements",
+}
 
-	url := fmt.Sprintf("https://intervals.icu/api/v1/athlete/%s/activities?oldest=%s&limit=3", athleteID, oldest)
+// GetActivities returns the activities of the past two weeks, newest first.
+// The device shows the last 3, the others count towards the weekly zones.
+func (c *intervalsClient) GetActivities() ([]Activity, error) {
+	query := url.Values{}
+	query.Set("oldest", time.Now().AddDate(0, 0, -14).Format("2006-01-02"))
+	query.Set("fields", strings.Join(activityFields, ","))
 
 	var activities []Activity
-	if err := i.makeRequest(url, &activities); err != nil {
+	if err := c.get("/activities", query, &activities); err != nil {
 		return nil, err
 	}
 
+	sort.SliceStable(activities, func(i, j int) bool {
+		return activities[i].StartDateLocal > activities[j].StartDateLocal
+	})
 	return activities, nil
 }
 
-func (i *intervals) GetFitness(date string) (*Fitness, error) {
-	athleteID, err := GetSecret(INTERVALS_ID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get athlete ID: %w", err)
+// GetFitness returns the wellness record, with CTL and ATL, for a date
+func (c *intervalsClient) GetFitness(date string) (*Fitness, error) {
+	var fitness Fitness
+	if err := c.get("/wellness/"+date, nil, &fitness); err != nil {
+		return nil, err
 	}
 
-	url := fmt.Sprintf("https://intervals.icu/api/v1/athlete/%s/wellness/%s", athleteID, date)
+	return &fitness, nil
+}
 
-	var fitness Fitness
-	if err := i.makeRequest(url, &fitness); err != nil {
+// GetWellness returns the wellness records from oldest to newest (inclusive,
+// YYYY-MM-DD), oldest first
+func (c *intervalsClient) GetWellness(oldest, newest string) ([]Fitness, error) {
+	query := url.Values{}
+	query.Set("oldest", oldest)
+	query.Set("newest", newest)
+
+	var records []Fitness
+	if err := c.get("/wellness", query, &records); err != nil {
 		return nil, err
 	}
 
-	return &fitness, nil
+	return records, nil
 }
 
-func (i *intervals) GetDisplayData(date string) (*DisplayData, error) {
-	activities, err := i.GetActivities()
+// GetDay fetches the wellness record for date and the latest activities
+func (c *intervalsClient) GetDay(date string) (*TrainingDay, error) {
+	activities, err := c.GetActivities()
 	if err != nil {
 		return nil, err
 	}
 
-	fitness, err := i.GetFitness(date)
+	fitness, err := c.GetFitness(date)
+	if err != nil {
+		return nil, err
+	}
+
+	day := &TrainingDay{
+		AthleteID: c.athleteID,
+		Date:      date,
+		Fitness:   fitness.trainingFitness(),
+		UpdatedAt: time.Now(),
+	}
+	for _, a := range activities {
+		day.Activities = append(day.Activities, a.trainingActivity())
+	}
+	return day, nil
+}
+
+// trainingFitness converts the wellness record to the provider-neutral shape
+func (f Fitness) trainingFitness() TrainingFitness {
+	fitness := TrainingFitness{Ctl: f.Ctl, Atl: f.Atl, RampRate: f.RampRate}
+	fitness.Eftp, fitness.WPrime, fitness.PMax, _ = sportInfo(f, PowerSport)
+	return fitness
+}
+
+// trainingActivity converts the activity to the provider-neutral shape
+func (a Activity) trainingActivity() TrainingActivity {
+	activity := TrainingActivity{
+		ID:               a.ID,
+		Name:             a.Name,
+		Type:             a.Type,
+		StartDateLocal:   a.StartDateLocal,
+		Distance:         a.Distance,
+		MovingTime:       a.MovingTime,
+		AverageWatts:     a.IcuAverageWatts,
+		WeightedAvgWatts: a.IcuWeightedAvgWatts,
+		AverageHeartrate: a.AverageHeartrate,
+		LRBalance:        a.AvgLrBalance,
+		Calories:         a.Calories,
+		Load:             a.IcuTrainingLoad,
+		RollingFtp:       a.IcuRollingFtp,
+		RollingCp:        a.IcuRollingCp,
+		RollingWPrime:    a.IcuRollingWPrime,
+		RollingPMax:      a.IcuRollingPMax,
+		PairedEventID:    int64(a.PairedEventID),
+		PowerZoneSecs:    powerZoneSecs(a),
+		HRZoneSecs:       a.IcuHrZoneTimes,
+		Polarization:     a.PolarizationIndex,
+	}
+	for _, achievement := range a.IcuAchievements {
+		activity.Achievements = append(activity.Achievements, ActivityAchievement{
+			ID:      achievement.ID,
+			Type:    achievement.Type,
+			Message: achievement.Message,
+			Watts:   achievement.Watts,
+			Secs:    achievement.Secs,
+			Value:   achievement.Value,
+		})
+	}
+	return activity
+}
+
+func (c *intervalsClient) GetDisplayData(date string) (*DisplayData, error) {
+	day, err := c.GetDay(date)
 	if err != nil {
 		return nil, err
 	}
 
+	return day.DisplayData(), nil
+}
+
+// DisplayData reduces the stored records to the payload sent to the device
+func (d *TrainingDay) DisplayData() *DisplayData {
 	displayData := &DisplayData{
-		ID:       fitness.ID,
-		Ctl:      fitness.Ctl,
-		Atl:      fitness.Atl,
-		RampRate: fitness.RampRate,
+		ID:        d.Date,
+		Ctl:       d.Fitness.Ctl,
+		Atl:       d.Fitness.Atl,
+		RampRate:  d.Fitness.RampRate,
+		WeekZones: weeklyZones(d.Activities, d.Date),
 	}
 
-	// Process activities: reverse order (oldest to newest) and format date
-	// API returns newest first due to limit=3 on usually sorted chronological list
-	count := min(len(activities), 3)
+	// Process the last 3 activities: reverse order (oldest to newest) and
+	// format date. Activities are stored newest first.
+	count := min(len(d.Activities), 3)
 
 	for j := count - 1; j >= 0; j-- {
-		a := activities[j]
+		a := d.Activities[j]
 
 		// Parse and format date: Mon 15:04
 		formattedDate := a.StartDateLocal
@@ -428,135 +688,248 @@ func (i *intervals) GetDisplayData(date string) (*DisplayData, error) {
 		displayData.Activities = append(displayData.Activities, MinimumActivity{
 			ID:                  a.ID,
 			StartDateLocal:      formattedDate,
-			IcuAverageWatts:     a.IcuAverageWatts,
-			IcuWeightedAvgWatts: a.IcuWeightedAvgWatts,
+			IcuAverageWatts:     a.AverageWatts,
+			IcuWeightedAvgWatts: a.WeightedAvgWatts,
 			AverageHeartrate:    a.AverageHeartrate,
-			AvgLrBalance:        a.AvgLrBalance,
-			IcuRollingFtp:       a.IcuRollingFtp,
+			AvgLrBalance:        a.LRBalance,
+			IcuRollingFtp:       a.RollingFtp,
 			Calories:            a.Calories,
 			Distance:            a.Distance,
 			MovingTime:          a.MovingTime,
+			Zones:               activityZones(a),
 		})
 	}
 
-	return displayData, nil
+	return displayData
 }
 
-// GetCachedIntervals retrieves cached intervals data from database
-func GetCachedIntervals() (*DisplayData, time.Time, error) {
+// intervalsStoreVersion is the format of the records stored in intervals_days
+// and the other training tables. Bump it when a stored record changes
+// incompatibly: rows of other versions are ignored and refetched.
+const intervalsStoreVersion = 2
+
+// scanIntervalsDay decodes one intervals_days row
+func scanIntervalsDay(row *sql.Row) (*TrainingDay, error) {
 	var (
-		ctl            float64
-		atl            float64
-		rampRate       float64
-		fatigue        float64
-		stress         float64
+		day            TrainingDay
+		wellnessJSON   string
 		activitiesJSON string
 		lastUpdated    string
 	)
 
-	row := db.QueryRow(`
-		SELECT ctl, atl, ramp_rate, activities_json, last_updated
-		FROM intervals_cache
-		WHERE id = 1
-	`)
-
-	err := row.Scan(&ctl, &atl, &rampRate, &fatigue, &stress, &activitiesJSON, &lastUpdated)
-	if err != nil {
-		// No cache exists
-		return nil, time.Time{}, err
+	if err := row.Scan(&day.AthleteID, &day.Date, &wellnessJSON, &activitiesJSON, &lastUpdated); err != nil {
+		return nil, err
 	}
 
-	// Parse last updated time
 	updatedAt, err := time.Parse(time.RFC3339, lastUpdated)
 	if err != nil {
-		return nil, time.Time{}, err
+		return nil, err
 	}
+	day.UpdatedAt = updatedAt
 
-	// Parse activities JSON
-	var activities []MinimumActivity
-	if err := json.Unmarshal([]byte(activitiesJSON), &activities); err != nil {
-		return nil, time.Time{}, err
+	if err := json.Unmarshal([]byte(wellnessJSON), &day.Fitness); err != nil {
+		return nil, err
 	}
-
-	displayData := &DisplayData{
-		Ctl:        ctl,
-		Atl:        atl,
-		RampRate:   rampRate,
-		Activities: activities,
+	if err := json.Unmarshal([]byte(activitiesJSON), &day.Activities); err != nil {
+		return nil, err
 	}
 
-	return displayData, updatedAt, nil
+	return &day, nil
+}
+
+// GetCachedIntervals retrieves the stored day of an athlete. An empty
+// athleteID matches any athlete. Returns sql.ErrNoRows when the day is not
+// stored.
+func GetCachedIntervals(athleteID, date string) (*TrainingDay, error) {
+	row := db.QueryRow(`
+		SELECT athlete_id, date, wellness_json, activities_json, last_updated
+		FROM intervals_days
+		WHERE (? = '' OR athlete_id = ?) AND date = ? AND version = ?
+		ORDER BY last_updated DESC
+		LIMIT 1
+	`, athleteID, athleteID, date, intervalsStoreVersion)
+
+	return scanIntervalsDay(row)
+}
+
+// GetLatestIntervals retrieves the most recent stored day of an athlete. An
+// empty athleteID matches any athlete.
+func GetLatestIntervals(athleteID string) (*TrainingDay, error) {
+	row := db.QueryRow(`
+		SELECT athlete_id, date, wellness_json, activities_json, last_updated
+		FROM intervals_days
+		WHERE (? = '' OR athlete_id = ?) AND version = ?
+		ORDER BY date DESC, last_updated DESC
+		LIMIT 1
+	`, athleteID, athleteID, intervalsStoreVersion)
+
+	return scanIntervalsDay(row)
 }
 
-// SaveIntervalsCache stores intervals data in database
-func SaveIntervalsCache(data *DisplayData) error {
-	// Serialize activities to JSON
-	activitiesJSON, err := json.Marshal(data.Activities)
+// SaveIntervalsCache stores the day, replacing any previous record of the
+// same athlete and date
+func SaveIntervalsCache(day *TrainingDay) error {
+	wellnessJSON, err := json.Marshal(day.Fitness)
+	if err != nil {
+		return err
+	}
+	activitiesJSON, err := json.Marshal(day.Activities)
 	if err != nil {
 		return err
 	}
 
-	now := time.Now().Format(time.RFC3339)
+	// Stored in UTC so last_updated sorts and compares as text
+	now := time.Now().UTC().Format(time.RFC3339)
 
-	// Use INSERT OR REPLACE to ensure only one row exists
 	_, err = db.Exec(`
-		INSERT OR REPLACE INTO intervals_cache (id, ctl, atl, ramp_rate, activities_json, last_updated)
-		VALUES (1, ?, ?, ?, ?, ?, ?, ?)
-	`, data.Ctl, data.Atl, data.RampRate, string(activitiesJSON), now)
+		INSERT OR REPLACE INTO intervals_days
+			(athlete_id, date, version, wellness_json, activities_json, last_updated)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, day.AthleteID, day.Date, intervalsStoreVersion, string(wellnessJSON), string(activitiesJSON), now)
 
 	if err != nil {
 		return err
 	}
 
-	log.Printf("Intervals cache updated at %s", now)
+	log.Printf("Intervals day %s stored at %s", day.Date, now)
 	return nil
 }
 
+// prepareDisplayData fills in the per-request fields: the training status,
+// display values in units and the weather alerts
+func prepareDisplayData(data *DisplayData, units Units) {
+	data.Status = ClassifyTraining(config.Training, data.Ctl, data.Atl, data.RampRate)
+	for i := range data.Activities {
+		a := &data.Activities[i]
+		a.DistanceDisplay = units.Distance(a.Distance)
+		a.MovingTimeDisplay = formatHoursMinutes(a.MovingTime)
+	}
+	data.Units = units.Labels()
+	data.Alerts = defaultAlerts(units)
+}
+
+// intervalsMaxAge is how long today's stored data is served before a poll
+// refreshes it, when the scheduler does not
+const intervalsMaxAge = 8 * time.Hour
+
+// refreshIntervals fetches today's data from the training provider and
+// stores it
+func refreshIntervals() error {
+	provider, err := defaultTrainingProvider()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	day, err := provider.GetDay(now.Format("2006-01-02"))
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Saving %s data to db", provider.Name())
+	if err := SaveIntervalsCache(day); err != nil {
+		return err
+	}
+	if _, err := recordAchievements(day.AthleteID, day.Activities); err != nil {
+		log.Printf("Failed to record achievements: %v", err)
+	}
+
+	// The fitness chart, calendar and power curves only exist on intervals.icu
+	if provider.Name() != ProviderIntervals {
+		return nil
+	}
+
+	// Keep the default fitness chart, calendar and power curves up to date
+	// too, today's data is stored whatever happens to them
+	oldest := now.AddDate(0, 0, 1-FitnessDefaultDays).Format("2006-01-02")
+	if err := refreshFitness(oldest, day.Date); err != nil {
+		log.Printf("Failed to refresh fitness history: %v", err)
+	}
+	newest := now.AddDate(0, 0, CalendarDefaultDays-1).Format("2006-01-02")
+	if err := refreshCalendar(day.Date, newest); err != nil {
+		log.Printf("Failed to refresh calendar: %v", err)
+	}
+	if err := refreshPowerCurves(); err != nil {
+		log.Printf("Failed to refresh power curves: %v", err)
+	}
+	return nil
+}
+
+// writeIntervals sends the stored day in units
+func writeIntervals(w http.ResponseWriter, day *TrainingDay, units Units) {
+	displayData := day.DisplayData()
+	prepareDisplayData(displayData, units)
+	json.NewEncoder(w).Encode(displayData)
+}
+
 func handleIntervals(w http.ResponseWriter, r *http.Request) {
-	const cacheMaxAge = 8 * time.Hour
+	units, err := unitsFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Without credentials the stored days of any athlete are served
+	athleteID := trainingAthleteID()
+
+	today := time.Now().Format("2006-01-02")
 
-	// Try to get cached data
-	cachedData, lastUpdated, err := GetCachedIntervals()
+	// Past days are only served from the store
+	if date := r.URL.Query().Get("date"); date != "" && date != today {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			http.Error(w, fmt.Sprintf("invalid date %q, want YYYY-MM-DD", date), http.StatusBadRequest)
+			return
+		}
+		day, err := GetCachedIntervals(athleteID, date)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("no intervals data stored for %s", date), http.StatusNotFound)
+			return
+		}
+		writeIntervals(w, day, units)
+		return
+	}
+
+	// Serve the latest stored day while it is today's and recent, or while the
+	// scheduler keeps it up to date
+	cached, err := GetLatestIntervals(athleteID)
 	if err == nil {
-		// Check if cache is still valid (less than 8 hours old)
-		age := time.Since(lastUpdated)
-		if age < cacheMaxAge {
-			log.Printf("Using cached intervals data (age: %v)", age.Round(time.Minute))
+		age := time.Since(cached.UpdatedAt)
+		fresh := cached.Date == today && age < intervalsMaxAge
+		if fresh || refresher.covers(RefreshIntervals, age) {
+			log.Printf("Using cached intervals data (%s, age: %v)", cached.Date, age.Round(time.Minute))
 			w.Header().Set("X-Cache-Age", age.String())
-			json.NewEncoder(w).Encode(cachedData)
+			if !fresh {
+				w.Header().Set("X-Cache-Stale", "true")
+			}
+			writeIntervals(w, cached, units)
 			return
 		}
-		log.Printf("Cache expired (age: %v), fetching fresh data", age.Round(time.Minute))
+		log.Printf("Cache expired (%s, age: %v), fetching fresh data", cached.Date, age.Round(time.Minute))
 	} else {
+		cached = nil
 		log.Printf("No cache found, fetching fresh data")
 	}
 
-	// Cache is expired or doesn't exist, fetch fresh data
-	i := &intervals{}
-	now := time.Now()
-	date := now.Format("2006-01-02")
-	displayData, err := i.GetDisplayData(date)
+	// Refresh now, joining a background refresh already in progress
+	var day *TrainingDay
+	err = refreshFlights.Do(RefreshIntervals, refreshIntervals)
+	if err == nil {
+		day, err = GetLatestIntervals(athleteID)
+	}
 	if err != nil {
 		log.Printf("Failed to fetch intervals data: %v", err)
 		// If we have cached data, return it even if expired
-		if cachedData != nil {
+		if cached != nil {
 			log.Printf("Returning stale cache due to API error")
 			w.Header().Set("X-Cache-Stale", "true")
-			json.NewEncoder(w).Encode(cachedData)
+			writeIntervals(w, cached, units)
 			return
 		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Save to cache
-	log.Printf("Saving intervals data to db")
-	if err := SaveIntervalsCache(displayData); err != nil {
-		log.Printf("Failed to save intervals cache: %v", err)
-		// Continue anyway, just log the error
-	}
-
 	log.Printf("Returning fresh intervals data")
 	w.Header().Set("X-Cache-Fresh", "true")
-	json.NewEncoder(w).Encode(displayData)
+	writeIntervals(w, day, units)
 }
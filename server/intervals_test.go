@@ -1,32 +1,467 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
+// newIntervalsFixtureServer replays the recorded intervals.icu responses in
+// testdata for athlete "i42", for any wellness date, and checks the API key
+// of every request. Calendar events are shifted so that the first day of the
+// fixture (2026-02-02) is the oldest requested day.
+func newIntervalsFixtureServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "API_KEY" || pass != "secret" {
+			t.Errorf("unexpected credentials for %s", r.URL)
+		}
+		var name string
+		switch {
+		case r.URL.Path == "/athlete/i42/activities":
+			if r.URL.Query().Get("fields") == "" {
+				t.Errorf("activities requested without fields: %s", r.URL)
+			}
+			name = "intervals_activities.json"
+		case strings.HasPrefix(r.URL.Path, "/athlete/i42/wellness/"):
+			name = "intervals_wellness.json"
+		case r.URL.Path == "/athlete/i42/wellness":
+			name = "intervals_wellness_range.json"
+		case r.URL.Path == "/athlete/i42/events":
+			name = "intervals_events.json"
+		case r.URL.Path == "/athlete/i42/power-curves":
+			name = "intervals_power_curves.json"
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		data, err := os.ReadFile("testdata/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if oldest, err := time.Parse("2006-01-02", r.URL.Query().Get("oldest")); err == nil && name == "intervals_events.json" {
+			data = []byte(strings.NewReplacer(
+				"2026-02-02", oldest.Format("2006-01-02"),
+				"2026-02-03", oldest.AddDate(0, 0, 1).Format("2006-01-02"),
+				"2026-02-04", oldest.AddDate(0, 0, 2).Format("2006-01-02"),
+			).Replace(string(data)))
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
 func TestGetActivities(t *testing.T) {
-	t.Skip()
+	server := newIntervalsFixtureServer(t)
+	client := newIntervalsClient(server.URL+"/", "i42", "secret", server.Client())
 
-	i := intervals{}
-	activities, err := i.GetActivities()
+	activities, err := client.GetActivities()
 	if err != nil {
-		t.Errorf("Failed to get activities: %v", err)
+		t.Fatal(err)
 	}
 
-	byt, _ := json.MarshalIndent(activities, "", "  ")
-	t.Logf("Fitness: %s", string(byt))
+	if len(activities) != 3 {
+		t.Fatalf("got %d activities, want 3", len(activities))
+	}
+	a := activities[0]
+	if a.ID != "i104" || a.Type != "VirtualRide" || a.IcuWeightedAvgWatts != 224 || a.PairedEventID != 8812 {
+		t.Errorf("unexpected newest activity: %+v", a)
+	}
 }
 
 func TestGetFitness(t *testing.T) {
-	t.Skip()
+	server := newIntervalsFixtureServer(t)
+	client := newIntervalsClient(server.URL, "i42", "secret", server.Client())
+
+	fitness, err := client.GetFitness("2026-02-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fitness.ID != "2026-02-02" || fitness.Ctl != 54.81 || fitness.Atl != 61.24 || fitness.RampRate != 3.2 {
+		t.Errorf("unexpected fitness: %+v", fitness)
+	}
+	if len(fitness.SportInfo) != 1 || fitness.SportInfo[0].Eftp != 258.4 {
+		t.Errorf("unexpected sport info: %+v", fitness.SportInfo)
+	}
+}
+
+func TestGetDisplayData(t *testing.T) {
+	server := newIntervalsFixtureServer(t)
+	client := newIntervalsClient(server.URL, "i42", "secret", server.Client())
+
+	data, err := client.GetDisplayData("2026-02-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data.Ctl != 54.81 || data.Atl != 61.24 || data.RampRate != 3.2 {
+		t.Errorf("unexpected form: %+v", data)
+	}
+
+	// Activities are listed oldest first with a short local date
+	wantDates := []string{"Fri 07:12", "Sun 09:05", "Mon 18:30"}
+	if len(data.Activities) != len(wantDates) {
+		t.Fatalf("got %d activities, want %d", len(data.Activities), len(wantDates))
+	}
+	for i, want := range wantDates {
+		if got := data.Activities[i].StartDateLocal; got != want {
+			t.Errorf("activity %d date = %q, want %q", i, got, want)
+		}
+	}
+	if data.Activities[2].ID != "i104" || data.Activities[2].Distance != 41250.4 {
+		t.Errorf("unexpected newest activity: %+v", data.Activities[2])
+	}
+
+	// Time-in-zone per activity, the sweet spot overlap left out
+	zones := data.Activities[2].Zones
+	if zones == nil || fmt.Sprint(zones.Power) != "[13 27 20 33 7 0 0]" || fmt.Sprint(zones.HR) != "[20 40 27 13 0]" ||
+		zones.Polarization != 0.84 {
+		t.Errorf("newest activity zones = %+v", zones)
+	}
+	if run := data.Activities[0].Zones; run == nil || run.Power != nil || len(run.HR) != 5 {
+		t.Errorf("run zones = %+v", run)
+	}
+	if week := data.WeekZones; week == nil || len(week.Power) != 7 || len(week.HR) != 5 || week.Polarization == 0 {
+		t.Errorf("week zones = %+v", week)
+	}
+}
+
+func TestGetFitnessErrors(t *testing.T) {
+	server := newIntervalsFixtureServer(t)
+
+	client := newIntervalsClient(server.URL, "i43", "secret", server.Client())
+	if _, err := client.GetFitness("2026-02-02"); err == nil {
+		t.Errorf("expected error for unknown athlete")
+	}
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slow.Close()
 
-	i := intervals{}
-	fitness, err := i.GetFitness("2026-02-02")
+	client = newIntervalsClient(slow.URL, "i42", "secret", &http.Client{Timeout: 20 * time.Millisecond})
+	client.retry.Attempts = 1
+	if _, err := client.GetFitness("2026-02-02"); err == nil {
+		t.Errorf("expected timeout error")
+	}
+}
+
+func TestIntervalsRetries(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch calls {
+		case 1:
+			w.WriteHeader(http.StatusBadGateway)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.Write([]byte(`{"id": "2026-02-02", "ctl": 54.81}`))
+		}
+	}))
+	defer server.Close()
+
+	client := newIntervalsClient(server.URL, "i42", "secret", server.Client())
+	client.retry = retryPolicy{Attempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second}
+
+	fitness, err := client.GetFitness("2026-02-02")
 	if err != nil {
-		t.Errorf("Failed to get fitness: %v", err)
+		t.Fatal(err)
+	}
+	if calls != 3 || fitness.Ctl != 54.81 {
+		t.Errorf("calls = %d, fitness = %+v", calls, fitness)
+	}
+	if s := client.breaker.status(); s.State != CircuitClosed || s.Failures != 0 {
+		t.Errorf("breaker after success = %+v", s)
+	}
+}
+
+func TestIntervalsNoRetry(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		body       string
+		want       upstreamErrorKind
+	}{
+		{name: "auth", status: http.StatusUnauthorized, want: ErrorAuth},
+		{name: "missing", status: http.StatusNotFound, want: ErrorRejected},
+		{name: "decode", status: http.StatusOK, body: "<html>", want: ErrorDecode},
+		{name: "long retry-after", status: http.StatusTooManyRequests, retryAfter: "120", want: ErrorTransient},
+	}
+
+	for _, tt := range tests {
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if tt.retryAfter != "" {
+				w.Header().Set("Retry-After", tt.retryAfter)
+			}
+			w.WriteHeader(tt.status)
+			w.Write([]byte(tt.body))
+		}))
+
+		client := newIntervalsClient(server.URL, "i42", "secret", server.Client())
+		client.retry = retryPolicy{Attempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second}
+
+		_, err := client.GetFitness("2026-02-02")
+		if kind := errorKind(err); err == nil || kind != tt.want {
+			t.Errorf("%s: error = %v, want %s", tt.name, err, tt.want)
+		}
+		if calls != 1 {
+			t.Errorf("%s: %d calls, want 1", tt.name, calls)
+		}
+		server.Close()
 	}
+}
+
+func TestIntervalsCircuitBreaker(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
 
-	byt, _ := json.MarshalIndent(fitness, "", "  ")
-	t.Logf("Fitness: %s", string(byt))
+	client := newIntervalsClient(server.URL, "i42", "secret", server.Client())
+	client.retry = retryPolicy{Attempts: 1}
+	client.breaker = newCircuitBreaker("intervals.icu", 2, time.Hour)
+
+	for range 4 {
+		if _, err := client.GetFitness("2026-02-02"); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+	if calls != 2 {
+		t.Errorf("%d calls, want 2 before the circuit opened", calls)
+	}
+
+	if s := client.breaker.status(); s.State != CircuitOpen || s.LastErrorKind != string(ErrorTransient) {
+		t.Errorf("intervals.icu breaker = %+v", s)
+	}
+}
+
+// useIntervalsFixtures points the shared intervals client at the fixture
+// server, with a fresh database
+func useIntervalsFixtures(t *testing.T) *intervalsClient {
+	t.Helper()
+	setupTestDB(t)
+
+	server := newIntervalsFixtureServer(t)
+	client := newIntervalsClient(server.URL, "i42", "secret", server.Client())
+
+	previous := intervalsAPI
+	intervalsAPI = client
+	t.Cleanup(func() { intervalsAPI = previous })
+
+	return client
+}
+
+func TestIntervalsStoreRoundTrip(t *testing.T) {
+	client := useIntervalsFixtures(t)
+
+	day, err := client.GetDay("2026-02-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveIntervalsCache(day); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := GetCachedIntervals("i42", "2026-02-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Compared as JSON: decoded times lose their original zone
+	got, _ := json.Marshal([]any{stored.Fitness, stored.Activities})
+	want, _ := json.Marshal([]any{day.Fitness, day.Activities})
+	if string(got) != string(want) {
+		t.Errorf("round trip = %s, want %s", got, want)
+	}
+	if stored.AthleteID != "i42" || stored.Date != "2026-02-02" || stored.UpdatedAt.IsZero() {
+		t.Errorf("unexpected key: %+v", stored)
+	}
+	if stored.Fitness.Eftp != 258.4 || stored.Fitness.WPrime != 18650 || stored.Fitness.PMax != 912 {
+		t.Errorf("power model not stored: %+v", stored.Fitness)
+	}
+
+	if _, err := GetCachedIntervals("i42", "2026-02-01"); err != sql.ErrNoRows {
+		t.Errorf("missing day error = %v, want sql.ErrNoRows", err)
+	}
+	if _, err := GetCachedIntervals("i43", "2026-02-02"); err != sql.ErrNoRows {
+		t.Errorf("other athlete error = %v, want sql.ErrNoRows", err)
+	}
+	if _, err := GetCachedIntervals("", "2026-02-02"); err != nil {
+		t.Errorf("any athlete lookup: %v", err)
+	}
+}
+
+func TestIntervalsStoreDays(t *testing.T) {
+	setupTestDB(t)
+
+	for _, date := range []string{"2026-02-01", "2026-02-03", "2026-02-02"} {
+		day := &TrainingDay{AthleteID: "i42", Date: date, Fitness: TrainingFitness{}}
+		if err := SaveIntervalsCache(day); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Saving a day again replaces it
+	if err := SaveIntervalsCache(&TrainingDay{AthleteID: "i42", Date: "2026-02-01", Fitness: TrainingFitness{Ctl: 50}}); err != nil {
+		t.Fatal(err)
+	}
+
+	latest, err := GetLatestIntervals("i42")
+	if err != nil || latest.Date != "2026-02-03" {
+		t.Errorf("latest = %+v, %v, want 2026-02-03", latest, err)
+	}
+
+	past, err := GetCachedIntervals("i42", "2026-02-01")
+	if err != nil || past.Fitness.Ctl != 50 {
+		t.Errorf("replaced day = %+v, %v", past, err)
+	}
+
+	// Rows written in another format are ignored
+	if _, err := db.Exec(`UPDATE intervals_days SET version = ? WHERE date = '2026-02-03'`, intervalsStoreVersion+1); err != nil {
+		t.Fatal(err)
+	}
+	latest, err = GetLatestIntervals("")
+	if err != nil || latest.Date != "2026-02-02" {
+		t.Errorf("latest after version bump = %+v, %v, want 2026-02-02", latest, err)
+	}
+}
+
+func TestHandleIntervals(t *testing.T) {
+	useIntervalsFixtures(t)
+
+	rec := httptest.NewRecorder()
+	handleIntervals(rec, httptest.NewRequest("GET", "/api/intervals", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Cache-Fresh") != "true" {
+		t.Fatalf("first request: status %d, headers %v", rec.Code, rec.Header())
+	}
+
+	var data DisplayData
+	if err := json.NewDecoder(rec.Body).Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Ctl != 54.81 || len(data.Activities) != 3 || data.Activities[2].MovingTimeDisplay == "" {
+		t.Errorf("unexpected payload: %+v", data)
+	}
+	if data.Status == nil || data.Status.State != StatusGreyZone || data.Status.Tsb != -6.4 || data.Status.RampRate != 3.2 {
+		t.Errorf("status = %+v", data.Status)
+	}
+
+	rec = httptest.NewRecorder()
+	handleIntervals(rec, httptest.NewRequest("GET", "/api/intervals", nil))
+	if rec.Header().Get("X-Cache-Age") == "" {
+		t.Errorf("second request not served from the store: %v", rec.Header())
+	}
+
+	rec = httptest.NewRecorder()
+	handleIntervals(rec, httptest.NewRequest("GET", "/api/intervals?date=2026-02-01", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unstored day status = %d, want 404", rec.Code)
+	}
+
+	if err := SaveIntervalsCache(&TrainingDay{AthleteID: "i42", Date: "2026-02-01", Fitness: TrainingFitness{Ctl: 50}}); err != nil {
+		t.Fatal(err)
+	}
+	rec = httptest.NewRecorder()
+	handleIntervals(rec, httptest.NewRequest("GET", "/api/intervals?date=2026-02-01", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"ctl":50`) {
+		t.Errorf("stored day: status %d, body %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	handleIntervals(rec, httptest.NewRequest("GET", "/api/intervals?date=yesterday", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid date status = %d, want 400", rec.Code)
+	}
+}
+
+// intervalsDocumentSize is the DynamicJsonDocument capacity the firmware
+// parses /api/intervals into
+const intervalsDocumentSize = 4096
+
+// arduinoJSONMemory estimates the ArduinoJson 6 memory taken by a payload on
+// the ESP32: a 16 byte slot per member or element, plus a copy of every key
+// and string, which are duplicated from the String the firmware reads into
+func arduinoJSONMemory(t *testing.T, data []byte) int {
+	t.Helper()
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		t.Fatal(err)
+	}
+
+	var size func(v any) int
+	size = func(v any) int {
+		switch v := v.(type) {
+		case map[string]any:
+			n := 0
+			for key, member := range v {
+				n += 16 + len(key) + 1 + size(member)
+			}
+			return n
+		case []any:
+			n := 0
+			for _, element := range v {
+				n += 16 + size(element)
+			}
+			return n
+		case string:
+			return len(v) + 1
+		}
+		return 0
+	}
+	return size(value)
+}
+
+func TestHandleIntervalsPayloadSize(t *testing.T) {
+	client := useIntervalsFixtures(t)
+
+	// The fixture day, its activities within the week of the zones
+	day, err := client.GetDay("2026-02-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveIntervalsCache(day); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every weather alert raised at once
+	loc, err := config.GetLocation("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hours := calmHours(time.Now().Add(time.Minute), 12)
+	hours[1].Code = 99
+	hours[2].WindSpeed = 120
+	hours[3].Temp = -20
+	hours[4].Precip = 40
+	if err := SaveWeatherCache(hourlyWeatherKey(loc), hours); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	handleIntervals(rec, httptest.NewRequest("GET", "/api/intervals?date=2026-02-02&units=imperial", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), `"week_zones"`) || strings.Count(rec.Body.String(), `"severity"`) != 4 {
+		t.Fatalf("payload is not the worst case: %s", rec.Body)
+	}
+
+	if size := arduinoJSONMemory(t, rec.Body.Bytes()); size > intervalsDocumentSize {
+		t.Errorf("payload takes %d bytes of the firmware's %d byte document (%d bytes of JSON)",
+			size, intervalsDocumentSize, rec.Body.Len())
+	}
 }
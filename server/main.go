@@ -10,7 +10,8 @@ import (
 
 // Message represents the response for the message endpoint
 type Message struct {
-	Message string `json:"message"`
+	Message string  `json:"message"`
+	Alerts  []Alert `json:"alerts,omitempty"` // Weather alerts banner
 }
 
 // CORS middleware to allow requests from any origin
@@ -32,9 +33,11 @@ func corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
 
 // handleHealth returns server health status
 func handleHealth(w http.ResponseWriter, r *http.Request) {
-	err := json.NewEncoder(w).Encode(map[string]string{
-		"status": "ok",
-		"time":   time.Now().Format(time.RFC3339),
+	err := json.NewEncoder(w).Encode(map[string]any{
+		"status":    "ok",
+		"time":      time.Now().Format(time.RFC3339),
+		"upstreams": upstreamStatus(),
+		"refresh":   refresher.statuses(),
 	})
 	if err != nil {
 		log.Printf("Error encoding health: %v", err)
@@ -46,14 +49,43 @@ func handleHealth(w http.ResponseWriter, r *http.Request) {
 func main() {
 	port := ":8081"
 
+	// Load configuration
+	if err := LoadConfig(); err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	provider, err := newWeatherProvider(config.Weather.Provider)
+	if err != nil {
+		log.Fatalf("Failed to create weather provider: %v", err)
+	}
+	weatherProvider = provider
+	log.Printf("Weather provider: %s", provider.Name())
+
+	airProvider, err := newAirQualityProvider(config.Weather.AirQuality)
+	if err != nil {
+		log.Fatalf("Failed to create air quality provider: %v", err)
+	}
+	airQualityProvider = airProvider
+	log.Printf("Training provider: %s", config.TrainingProvider)
+
 	// Initialize database
 	if err := InitDB(); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
+	// Refresh upstream data and record weather observations in the background
+	startRefreshScheduler()
+
 	// Register routes
 	http.HandleFunc("/api/message", corsMiddleware(handleMessage))
 	http.HandleFunc("/api/weather", corsMiddleware(handleWeather))
+	http.HandleFunc("/api/weather/daily", corsMiddleware(handleWeatherDaily))
+	http.HandleFunc("/api/weather/alerts", corsMiddleware(handleWeatherAlerts))
+	http.HandleFunc("/api/weather/icon", corsMiddleware(handleWeatherIcon))
+	http.HandleFunc("/api/weather/history", corsMiddleware(handleWeatherHistory))
+	http.HandleFunc("/api/weather/air", corsMiddleware(handleAirQuality))
+	http.HandleFunc("/api/weather/astro", corsMiddleware(handleWeatherAstro))
+	http.HandleFunc("/api/ride", corsMiddleware(handleRideAdvice))
 	http.HandleFunc("/api/tamagotchi", corsMiddleware(handleTamagotchi))
 	http.HandleFunc("/api/tamagotchi/feed", corsMiddleware(handleFeed))
 	http.HandleFunc("/api/tamagotchi/play", corsMiddleware(handlePlay))
@@ -62,6 +94,12 @@ func main() {
 	http.HandleFunc("/api/tamagotchi/cure", corsMiddleware(handleCure))
 	http.HandleFunc("/api/tamagotchi/reset", corsMiddleware(handleReset))
 	http.HandleFunc("/api/intervals", corsMiddleware(handleIntervals))
+	http.HandleFunc("/api/intervals/fitness", corsMiddleware(handleFitnessHistory))
+	http.HandleFunc("/api/intervals/calendar", corsMiddleware(handleCalendar))
+	http.HandleFunc("/api/intervals/power", corsMiddleware(handlePower))
+	http.HandleFunc("/api/intervals/wellness", corsMiddleware(handleWellnessCheckIn))
+	http.HandleFunc("/api/intervals/achievements", corsMiddleware(handleAchievements))
+	http.HandleFunc("/api/intervals/achievements/ack", corsMiddleware(handleAcknowledgeAchievements))
 	http.HandleFunc("/health", corsMiddleware(handleHealth))
 
 	// Print startup info
@@ -72,7 +110,14 @@ func main() {
 	fmt.Println("╠════════════════════════════════════════════════════════════╣")
 	fmt.Println("║  Available endpoints:                                      ║")
 	fmt.Println("║    GET  /api/message              - Text message           ║")
-	fmt.Println("║    GET  /api/weather              - Weather data           ║")
+	fmt.Println("║    GET  /api/weather?loc=         - Weather data           ║")
+	fmt.Println("║    GET  /api/weather/daily?days=  - Daily forecast (1-7)   ║")
+	fmt.Println("║    GET  /api/weather/alerts       - Weather alerts         ║")
+	fmt.Println("║    GET  /api/weather/icon?code=   - RGB565 weather icon    ║")
+	fmt.Println("║    GET  /api/weather/history      - Weather history/trend  ║")
+	fmt.Println("║    GET  /api/weather/air          - Air quality + pollen   ║")
+	fmt.Println("║    GET  /api/weather/astro        - Sun, UV and moon       ║")
+	fmt.Println("║    GET  /api/ride?hours=          - Best ride windows      ║")
 	fmt.Println("║    GET  /api/tamagotchi           - Dog state + sprite     ║")
 	fmt.Println("║    POST /api/tamagotchi/feed      - Feed (meal/snack)      ║")
 	fmt.Println("║    POST /api/tamagotchi/play      - Play with dog          ║")
@@ -81,6 +126,12 @@ func main() {
 	fmt.Println("║    POST /api/tamagotchi/cure      - Give medicine          ║")
 	fmt.Println("║    POST /api/tamagotchi/reset     - Start new game         ║")
 	fmt.Println("║    GET  /api/intervals            - Fetch intervals data   ║")
+	fmt.Println("║    GET  /api/intervals/fitness    - CTL/ATL/TSB series     ║")
+	fmt.Println("║    GET  /api/intervals/calendar   - Planned workouts       ║")
+	fmt.Println("║    GET  /api/intervals/power      - Power model + bests    ║")
+	fmt.Println("║    POST /api/intervals/wellness   - Morning check-in       ║")
+	fmt.Println("║    GET  /api/intervals/achievements - New PRs to show      ║")
+	fmt.Println("║    POST /api/intervals/achievements/ack - Mark PRs seen    ║")
 	fmt.Println("║    GET  /health                   - Server health          ║")
 	fmt.Println("╚════════════════════════════════════════════════════════════╝")
 	fmt.Println()
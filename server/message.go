@@ -11,6 +11,7 @@ import (
 func handleMessage(w http.ResponseWriter, r *http.Request) {
 	msg := Message{
 		Message: "Hello from Go Server! 🚀",
+		Alerts:  defaultAlerts(config.Units),
 	}
 
 	json.NewEncoder(w).Encode(msg)
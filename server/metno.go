@@ -0,0 +1,307 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	metNoBaseURL = "https://api.met.no/weatherapi/locationforecast/2.0"
+
+	// MET Norway rejects requests without an identifying User-Agent
+	metNoUserAgent = "t-display-s3-dashboard github.com/sayden/t-display-s3-dashboard"
+)
+
+// MetNoResponse structure for parsing the locationforecast "complete" API response
+type MetNoResponse struct {
+	Properties struct {
+		Timeseries []MetNoTimestep `json:"timeseries"`
+	} `json:"properties"`
+}
+
+type MetNoTimestep struct {
+	Time time.Time `json:"time"`
+	Data struct {
+		Instant struct {
+			Details struct {
+				AirTemperature   float64 `json:"air_temperature"`
+				RelativeHumidity float64 `json:"relative_humidity"`
+				WindSpeed        float64 `json:"wind_speed"` // m/s
+			} `json:"details"`
+		} `json:"instant"`
+		Next1Hours *MetNoPeriod `json:"next_1_hours"`
+		Next6Hours *MetNoPeriod `json:"next_6_hours"`
+	} `json:"data"`
+}
+
+type MetNoPeriod struct {
+	Summary struct {
+		SymbolCode string `json:"symbol_code"`
+	} `json:"summary"`
+	Details struct {
+		PrecipitationAmount        float64 `json:"precipitation_amount"`
+		ProbabilityOfPrecipitation float64 `json:"probability_of_precipitation"`
+	} `json:"details"`
+}
+
+// metNoProvider fetches weather from MET Norway's locationforecast API
+type metNoProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+func newMetNoProvider() *metNoProvider {
+	return &metNoProvider{
+		baseURL: metNoBaseURL,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *metNoProvider) Name() string {
+	return ProviderMetNo
+}
+
+// fetch performs the locationforecast request and decodes the response
+func (p *metNoProvider) fetch(loc Location) (*MetNoResponse, error) {
+	params := url.Values{}
+	params.Set("lat", fmt.Sprintf("%.4f", loc.Latitude))
+	params.Set("lon", fmt.Sprintf("%.4f", loc.Longitude))
+
+	req, err := http.NewRequest("GET", p.baseURL+"/complete?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", metNoUserAgent)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch weather: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("met.no request failed with status %d", resp.StatusCode)
+	}
+
+	var mn MetNoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&mn); err != nil {
+		return nil, fmt.Errorf("failed to decode weather: %w", err)
+	}
+
+	return &mn, nil
+}
+
+func (p *metNoProvider) GetWeather(loc Location) (*Weather, error) {
+	zone, err := time.LoadLocation(loc.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	slots, err := parseForecastSlots(config.Weather.ForecastSlots)
+	if err != nil {
+		return nil, err
+	}
+
+	mn, err := p.fetch(loc)
+	if err != nil {
+		return nil, err
+	}
+
+	weather, err := mn.toWeather(time.Now(), zone, slots)
+	if err != nil {
+		return nil, err
+	}
+	weather.Location = loc.Name
+	return weather, nil
+}
+
+// toWeather converts the MET Norway timeseries into the device payload
+func (mn *MetNoResponse) toWeather(now time.Time, zone *time.Location, slots []forecastSlot) (*Weather, error) {
+	series := mn.Properties.Timeseries
+	current := mn.indexAt(now)
+	if current < 0 {
+		return nil, fmt.Errorf("met.no returned no timeseries")
+	}
+
+	step := series[current]
+	period := step.period()
+	code := period.wmoCode()
+	weather := &Weather{
+		Temp:      step.Data.Instant.Details.AirTemperature,
+		Humidity:  int(step.Data.Instant.Details.RelativeHumidity + 0.5),
+		WindSpeed: msToKmh(step.Data.Instant.Details.WindSpeed),
+	}
+	weather.setCondition(code, period.isDay())
+
+	forecastAt := func(slot forecastSlot) (Forecast, bool) {
+		target := slot.Target(now, zone)
+		idx := mn.indexAt(target)
+		if idx <= current {
+			return Forecast{}, false
+		}
+		forecast := series[idx].toForecast()
+		forecast.Slot = slot.Spec
+		forecast.Time = target.In(zone).Format("15:04")
+		return forecast, true
+	}
+
+	for _, slot := range slots {
+		if forecast, ok := forecastAt(slot); ok {
+			weather.Forecasts = append(weather.Forecasts, forecast)
+		}
+	}
+
+	if forecast, ok := forecastAt(forecastSlot{Spec: slot3h, Hours: 3}); ok {
+		weather.Forecast3h = forecast
+	}
+	if forecast, ok := forecastAt(forecastSlot{Spec: slotTomorrow, Tomorrow: true, Hour: 12}); ok {
+		weather.ForecastTom = forecast
+	}
+
+	return weather, nil
+}
+
+// GetHourly returns the hourly forecast, starting with the current hour.
+// Beyond the first days met.no switches to 6 hour steps, which are skipped.
+func (p *metNoProvider) GetHourly(loc Location) ([]HourlyWeather, error) {
+	mn, err := p.fetch(loc)
+	if err != nil {
+		return nil, err
+	}
+	return hoursFrom(mn.toHourly(), time.Now()), nil
+}
+
+// toHourly converts the timesteps that carry a 1 hour forecast
+func (mn *MetNoResponse) toHourly() []HourlyWeather {
+	var hours []HourlyWeather
+	for _, step := range mn.Properties.Timeseries {
+		period := step.Data.Next1Hours
+		if period == nil {
+			continue
+		}
+
+		details := step.Data.Instant.Details
+		hours = append(hours, HourlyWeather{
+			Time:       step.Time,
+			Temp:       details.AirTemperature,
+			Humidity:   int(details.RelativeHumidity + 0.5),
+			WindSpeed:  msToKmh(details.WindSpeed),
+			Precip:     period.Details.PrecipitationAmount,
+			PrecipProb: int(period.Details.ProbabilityOfPrecipitation + 0.5),
+			Code:       period.wmoCode(),
+			IsDay:      period.isDay(),
+		})
+	}
+	return hours
+}
+
+// indexAt returns the index of the latest timestep starting at or before t,
+// or the first one when t predates the series. It returns -1 for an empty series.
+func (mn *MetNoResponse) indexAt(t time.Time) int {
+	series := mn.Properties.Timeseries
+	if len(series) == 0 {
+		return -1
+	}
+
+	idx := 0
+	for i, step := range series {
+		if step.Time.After(t) {
+			break
+		}
+		idx = i
+	}
+	return idx
+}
+
+// period returns the shortest forecast period available for the timestep.
+// Far-out timesteps only carry a 6 hour summary.
+func (s MetNoTimestep) period() *MetNoPeriod {
+	if s.Data.Next1Hours != nil {
+		return s.Data.Next1Hours
+	}
+	return s.Data.Next6Hours
+}
+
+func (s MetNoTimestep) toForecast() Forecast {
+	period := s.period()
+	code := period.wmoCode()
+
+	forecast := Forecast{
+		Temp:      s.Data.Instant.Details.AirTemperature,
+		WindSpeed: msToKmh(s.Data.Instant.Details.WindSpeed),
+	}
+	forecast.setCondition(code, period.isDay())
+	if period != nil {
+		forecast.Precip = period.Details.ProbabilityOfPrecipitation
+	}
+	return forecast
+}
+
+// wmoCode maps the MET Norway symbol code onto the closest WMO weather code
+func (p *MetNoPeriod) wmoCode() int {
+	if p == nil {
+		return -1
+	}
+
+	// Strip the _day / _night / _polartwilight variant
+	symbol, _, _ := strings.Cut(p.Summary.SymbolCode, "_")
+
+	if strings.HasSuffix(symbol, "andthunder") {
+		return 95
+	}
+
+	switch symbol {
+	case "clearsky":
+		return 0
+	case "fair":
+		return 1
+	case "partlycloudy":
+		return 2
+	case "cloudy":
+		return 3
+	case "fog":
+		return 45
+	case "lightrain":
+		return 61
+	case "rain":
+		return 63
+	case "heavyrain":
+		return 65
+	case "lightsleet", "sleet":
+		return 66
+	case "heavysleet":
+		return 67
+	case "lightsnow":
+		return 71
+	case "snow":
+		return 73
+	case "heavysnow":
+		return 75
+	case "lightrainshowers":
+		return 80
+	case "rainshowers", "lightsleetshowers", "sleetshowers":
+		return 81
+	case "heavyrainshowers", "heavysleetshowers":
+		return 82
+	case "lightsnowshowers", "snowshowers":
+		return 85
+	case "heavysnowshowers":
+		return 86
+	default:
+		return -1
+	}
+}
+
+// isDay reports whether the symbol is a daytime variant
+func (p *MetNoPeriod) isDay() bool {
+	return p == nil || !strings.HasSuffix(p.Summary.SymbolCode, "_night")
+}
+
+// msToKmh converts a wind speed from m/s to km/h
+func msToKmh(v float64) float64 {
+	return v * 3.6
+}
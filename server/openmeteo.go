@@ -0,0 +1,340 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const openMeteoBaseURL = "https://api.open-meteo.com/v1"
+
+// OpenMeteoResponse structure for parsing API response
+type OpenMeteoResponse struct {
+	CurrentWeather struct {
+		Temperature float64 `json:"temperature"`
+		Windspeed   float64 `json:"windspeed"`
+		Weathercode int     `json:"weathercode"`
+		Time        string  `json:"time"`
+		IsDay       int     `json:"is_day"`
+	} `json:"current_weather"`
+	Hourly struct {
+		Time                     []string  `json:"time"`
+		Temperature2m            []float64 `json:"temperature_2m"`
+		Relativehumidity2m       []int     `json:"relativehumidity_2m"`
+		PrecipitationProbability []int     `json:"precipitation_probability"`
+		Weathercode              []int     `json:"weathercode"`
+		Windspeed10m             []float64 `json:"windspeed_10m"`
+		Precipitation            []float64 `json:"precipitation"`
+		IsDay                    []int     `json:"is_day"`
+	} `json:"hourly"`
+}
+
+// OpenMeteoDailyResponse structure for parsing the daily= block
+type OpenMeteoDailyResponse struct {
+	Daily struct {
+		Time                        []string  `json:"time"`
+		Weathercode                 []int     `json:"weathercode"`
+		Temperature2mMax            []float64 `json:"temperature_2m_max"`
+		Temperature2mMin            []float64 `json:"temperature_2m_min"`
+		PrecipitationSum            []float64 `json:"precipitation_sum"`
+		PrecipitationProbabilityMax []int     `json:"precipitation_probability_max"`
+		Windspeed10mMax             []float64 `json:"windspeed_10m_max"`
+		Sunrise                     []string  `json:"sunrise"`
+		Sunset                      []string  `json:"sunset"`
+	} `json:"daily"`
+}
+
+// OpenMeteoSunResponse structure for parsing the daily sun and UV block
+type OpenMeteoSunResponse struct {
+	Daily struct {
+		Time             []string  `json:"time"`
+		Sunrise          []string  `json:"sunrise"`
+		Sunset           []string  `json:"sunset"`
+		DaylightDuration []float64 `json:"daylight_duration"` // Seconds
+		UVIndexMax       []float64 `json:"uv_index_max"`
+	} `json:"daily"`
+}
+
+// openMeteoProvider fetches weather from api.open-meteo.com
+type openMeteoProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+func newOpenMeteoProvider() *openMeteoProvider {
+	return &openMeteoProvider{
+		baseURL: openMeteoBaseURL,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *openMeteoProvider) Name() string {
+	return ProviderOpenMeteo
+}
+
+// forecastParams returns the query parameters shared by all forecast requests
+func (p *openMeteoProvider) forecastParams(loc Location) url.Values {
+	params := url.Values{}
+	params.Set("latitude", fmt.Sprintf("%.4f", loc.Latitude))
+	params.Set("longitude", fmt.Sprintf("%.4f", loc.Longitude))
+	params.Set("timezone", loc.Timezone)
+	params.Set("temperature_unit", "celsius")
+	params.Set("windspeed_unit", "kmh")
+	params.Set("precipitation_unit", "mm")
+	return params
+}
+
+// get performs a forecast request and decodes the response into target
+func (p *openMeteoProvider) get(params url.Values, target any) error {
+	resp, err := p.client.Get(p.baseURL + "/forecast?" + params.Encode())
+	if err != nil {
+		return fmt.Errorf("failed to fetch weather: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("open-meteo request failed with status %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+		return fmt.Errorf("failed to decode weather: %w", err)
+	}
+
+	return nil
+}
+
+// fetch requests current conditions and the hourly forecast
+func (p *openMeteoProvider) fetch(loc Location) (*OpenMeteoResponse, error) {
+	params := p.forecastParams(loc)
+	params.Set("current_weather", "true")
+	params.Set("hourly", "temperature_2m,relativehumidity_2m,precipitation_probability,weathercode,windspeed_10m,precipitation,is_day")
+
+	var om OpenMeteoResponse
+	if err := p.get(params, &om); err != nil {
+		return nil, err
+	}
+	return &om, nil
+}
+
+func (p *openMeteoProvider) GetWeather(loc Location) (*Weather, error) {
+	zone, err := time.LoadLocation(loc.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	slots, err := parseForecastSlots(config.Weather.ForecastSlots)
+	if err != nil {
+		return nil, err
+	}
+
+	om, err := p.fetch(loc)
+	if err != nil {
+		return nil, err
+	}
+
+	weather, err := om.toWeather(time.Now(), zone, slots)
+	if err != nil {
+		return nil, err
+	}
+	weather.Location = loc.Name
+	return weather, nil
+}
+
+// GetHourly returns the hourly forecast, starting with the current hour
+func (p *openMeteoProvider) GetHourly(loc Location) ([]HourlyWeather, error) {
+	zone, err := time.LoadLocation(loc.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	om, err := p.fetch(loc)
+	if err != nil {
+		return nil, err
+	}
+
+	hours, err := om.toHourly(zone)
+	if err != nil {
+		return nil, err
+	}
+	return hoursFrom(hours, time.Now()), nil
+}
+
+// toHourly converts the hourly block, stopping at the shortest array
+func (om *OpenMeteoResponse) toHourly(zone *time.Location) ([]HourlyWeather, error) {
+	times, err := parseHourlyTimes(om.Hourly.Time, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	h := om.Hourly
+	count := min(len(times), len(h.Temperature2m), len(h.Relativehumidity2m), len(h.PrecipitationProbability),
+		len(h.Weathercode), len(h.Windspeed10m), len(h.Precipitation), len(h.IsDay))
+
+	hours := make([]HourlyWeather, 0, count)
+	for i := 0; i < count; i++ {
+		hours = append(hours, HourlyWeather{
+			Time:       times[i],
+			Temp:       h.Temperature2m[i],
+			Humidity:   h.Relativehumidity2m[i],
+			WindSpeed:  h.Windspeed10m[i],
+			Precip:     h.Precipitation[i],
+			PrecipProb: h.PrecipitationProbability[i],
+			Code:       h.Weathercode[i],
+			IsDay:      h.IsDay[i] == 1,
+		})
+	}
+	return hours, nil
+}
+
+// toWeather converts the Open-Meteo response into the device payload. Hourly
+// entries are matched by their timestamp in the location's zone rather than
+// by position, so the server's own zone and DST changes don't matter.
+func (om *OpenMeteoResponse) toWeather(now time.Time, zone *time.Location, slots []forecastSlot) (*Weather, error) {
+	times, err := parseHourlyTimes(om.Hourly.Time, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	weather := &Weather{
+		Temp:      om.CurrentWeather.Temperature,
+		WindSpeed: om.CurrentWeather.Windspeed,
+	}
+	weather.setCondition(om.CurrentWeather.Weathercode, om.CurrentWeather.IsDay == 1)
+
+	// Humidity is only available hourly
+	if idx := hourIndex(times, now); idx >= 0 && idx < len(om.Hourly.Relativehumidity2m) {
+		weather.Humidity = om.Hourly.Relativehumidity2m[idx]
+	}
+
+	forecastAt := func(slot forecastSlot) (Forecast, bool) {
+		target := slot.Target(now, zone)
+		forecast, ok := om.forecastAt(hourIndex(times, target))
+		forecast.Slot = slot.Spec
+		forecast.Time = target.In(zone).Format("15:04")
+		return forecast, ok
+	}
+
+	for _, slot := range slots {
+		if forecast, ok := forecastAt(slot); ok {
+			weather.Forecasts = append(weather.Forecasts, forecast)
+		}
+	}
+
+	if forecast, ok := forecastAt(forecastSlot{Spec: slot3h, Hours: 3}); ok {
+		weather.Forecast3h = forecast
+	}
+	if forecast, ok := forecastAt(forecastSlot{Spec: slotTomorrow, Tomorrow: true, Hour: 12}); ok {
+		weather.ForecastTom = forecast
+	}
+
+	return weather, nil
+}
+
+// forecastAt builds the forecast for hourly entry idx. It reports false when
+// idx is out of range for any of the hourly arrays, which can differ in length.
+func (om *OpenMeteoResponse) forecastAt(idx int) (Forecast, bool) {
+	h := om.Hourly
+	if idx < 0 || idx >= len(h.Temperature2m) || idx >= len(h.Weathercode) ||
+		idx >= len(h.Windspeed10m) || idx >= len(h.PrecipitationProbability) {
+		return Forecast{}, false
+	}
+
+	// is_day is optional, assume daylight when missing
+	isDay := idx >= len(h.IsDay) || h.IsDay[idx] == 1
+
+	forecast := Forecast{
+		Temp:      h.Temperature2m[idx],
+		WindSpeed: h.Windspeed10m[idx],
+		Precip:    float64(h.PrecipitationProbability[idx]),
+	}
+	forecast.setCondition(h.Weathercode[idx], isDay)
+	return forecast, true
+}
+
+// GetDaily fetches the daily forecast for the next days, starting today
+func (p *openMeteoProvider) GetDaily(loc Location, days int) ([]DayForecast, error) {
+	params := p.forecastParams(loc)
+	params.Set("daily", "weathercode,temperature_2m_max,temperature_2m_min,precipitation_sum,precipitation_probability_max,windspeed_10m_max,sunrise,sunset")
+	params.Set("forecast_days", fmt.Sprint(days))
+
+	var om OpenMeteoDailyResponse
+	if err := p.get(params, &om); err != nil {
+		return nil, err
+	}
+	return om.toDays()
+}
+
+// toDays converts the daily block, stopping at the shortest array
+func (om *OpenMeteoDailyResponse) toDays() ([]DayForecast, error) {
+	d := om.Daily
+	count := min(len(d.Time), len(d.Weathercode), len(d.Temperature2mMax), len(d.Temperature2mMin),
+		len(d.PrecipitationSum), len(d.PrecipitationProbabilityMax), len(d.Windspeed10mMax),
+		len(d.Sunrise), len(d.Sunset))
+
+	days := make([]DayForecast, 0, count)
+	for i := 0; i < count; i++ {
+		date, err := time.Parse("2006-01-02", d.Time[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid daily time %q: %w", d.Time[i], err)
+		}
+
+		days = append(days, DayForecast{
+			Day:        date.Format("Mon"),
+			Date:       d.Time[i],
+			TempMax:    d.Temperature2mMax[i],
+			TempMin:    d.Temperature2mMin[i],
+			Code:       d.Weathercode[i],
+			Condition:  describeWeatherCode(d.Weathercode[i], true).Short,
+			PrecipSum:  d.PrecipitationSum[i],
+			PrecipProb: d.PrecipitationProbabilityMax[i],
+			WindMax:    d.Windspeed10mMax[i],
+			Sunrise:    clockTime(d.Sunrise[i]),
+			Sunset:     clockTime(d.Sunset[i]),
+		})
+	}
+
+	return days, nil
+}
+
+// GetSun fetches sun times and the UV index for the next days, starting today
+func (p *openMeteoProvider) GetSun(loc Location, days int) ([]SunDay, error) {
+	params := p.forecastParams(loc)
+	params.Set("daily", "sunrise,sunset,daylight_duration,uv_index_max")
+	params.Set("forecast_days", fmt.Sprint(days))
+
+	var om OpenMeteoSunResponse
+	if err := p.get(params, &om); err != nil {
+		return nil, err
+	}
+	return om.toSunDays(), nil
+}
+
+// toSunDays converts the daily block, stopping at the shortest array
+func (om *OpenMeteoSunResponse) toSunDays() []SunDay {
+	d := om.Daily
+	count := min(len(d.Time), len(d.Sunrise), len(d.Sunset), len(d.DaylightDuration), len(d.UVIndexMax))
+
+	days := make([]SunDay, 0, count)
+	for i := 0; i < count; i++ {
+		days = append(days, SunDay{
+			Date:     d.Time[i],
+			Sunrise:  clockTime(d.Sunrise[i]),
+			Sunset:   clockTime(d.Sunset[i]),
+			Daylight: int(math.Round(d.DaylightDuration[i] / 60)),
+			UVMax:    d.UVIndexMax[i],
+		})
+	}
+	return days
+}
+
+// clockTime returns the "15:04" part of an Open-Meteo local timestamp
+func clockTime(s string) string {
+	t, err := time.Parse(openMeteoTimeLayout, s)
+	if err != nil {
+		return ""
+	}
+	return t.Format("15:04")
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Power curve settings
+const (
+	PowerSport = "Ride" // intervals.icu counts virtual rides as rides
+
+	// PowerTrendDays is the window compared for the trend: the best of the
+	// last 6 weeks against the 6 weeks before
+	PowerTrendDays = 42
+
+	// PowerTrendFlat is the change, as a fraction, below which a trend is flat
+	PowerTrendFlat = 0.01
+
+	// PowerMinFetchInterval caps power curve calls, however many devices poll
+	PowerMinFetchInterval = 15 * time.Minute
+
+	// PowerRecordsLimit caps the power records returned
+	PowerRecordsLimit = 5
+)
+
+// bestEffortDurations are the durations of the best efforts table
+var bestEffortDurations = []struct {
+	Label string
+	Secs  int
+}{
+	{"5s", 5},
+	{"1m", 60},
+	{"5m", 300},
+	{"20m", 1200},
+}
+
+// Power trends
+const (
+	TrendUp   = "up"
+	TrendDown = "down"
+	TrendFlat = "flat"
+)
+
+// PowerCurve is an intervals.icu mean maximal power curve: Values[i] is the
+// best power, in watts, held for Secs[i] seconds
+type PowerCurve struct {
+	ID             string    `json:"id"`
+	Label          string    `json:"label"`
+	StartDateLocal string    `json:"start_date_local"`
+	EndDateLocal   string    `json:"end_date_local"`
+	Secs           []int     `json:"secs"`
+	Values         []float64 `json:"values"`
+}
+
+// watts returns the best power held for secs, 0 when the curve doesn't
+// reach that duration
+func (c PowerCurve) watts(secs int) float64 {
+	for i, s := range c.Secs {
+		if s == secs && i < len(c.Values) {
+			return c.Values[i]
+		}
+	}
+	return 0
+}
+
+// GetPowerCurves returns the power curves of sport in the order requested,
+// by curve id such as "s0" (current season), "42d" (last 42 days) or
+// "r.2026-01-01.2026-02-01" (a date range)
+func (c *intervalsClient) GetPowerCurves(sport string, curves ...string) ([]PowerCurve, error) {
+	query := url.Values{}
+	query.Set("type", sport)
+	query.Set("curves", strings.Join(curves, ","))
+
+	var response struct {
+		List []PowerCurve `json:"list"`
+	}
+	if err := c.get("/power-curves", query, &response); err != nil {
+		return nil, err
+	}
+	if len(response.List) != len(curves) {
+		return nil, &upstreamError{Kind: ErrorDecode, Err: fmt.Errorf("got %d power curves, want %d", len(response.List), len(curves))}
+	}
+
+	return response.List, nil
+}
+
+// PowerCurves are the curves of the best efforts table
+type PowerCurves struct {
+	Season   PowerCurve `json:"season"`
+	Recent   PowerCurve `json:"recent"`   // Last 6 weeks
+	Previous PowerCurve `json:"previous"` // The 6 weeks before
+}
+
+// bestEffortCurveIDs returns the ids of the season, recent and previous
+// curves as of date. Both windows are explicit date ranges, so they meet
+// without a gap or overlap whatever day intervals.icu counts from.
+func bestEffortCurveIDs(date time.Time) []string {
+	day := func(daysAgo int) string { return date.AddDate(0, 0, -daysAgo).Format("2006-01-02") }
+	return []string{
+		"s0",
+		fmt.Sprintf("r.%s.%s", day(PowerTrendDays-1), day(0)),
+		fmt.Sprintf("r.%s.%s", day(2*PowerTrendDays-1), day(PowerTrendDays)),
+	}
+}
+
+// GetBestEffortCurves returns the season, recent and previous curves as of date
+func (c *intervalsClient) GetBestEffortCurves(date time.Time) (*PowerCurves, error) {
+	curves, err := c.GetPowerCurves(PowerSport, bestEffortCurveIDs(date)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PowerCurves{Season: curves[0], Recent: curves[1], Previous: curves[2]}, nil
+}
+
+// PowerModel is the current power profile
+type PowerModel struct {
+	Eftp      float64  `json:"eftp"`                 // Estimated FTP, watts
+	EftpDelta *float64 `json:"eftp_delta,omitempty"` // Change since 6 weeks ago
+	Cp        float64  `json:"cp,omitempty"`         // Critical power, watts
+	WPrime    float64  `json:"w_prime,omitempty"`    // kJ above CP
+	PMax      float64  `json:"p_max,omitempty"`      // Watts
+}
+
+// BestEffort is one row of the best efforts table
+type BestEffort struct {
+	Duration string  `json:"d"`      // "5s", "1m", "5m" or "20m"
+	Season   float64 `json:"season"` // Season best, watts
+	Recent   float64 `json:"recent"` // Best of the last 6 weeks
+	Previous float64 `json:"prev"`   // Best of the 6 weeks before
+	Delta    float64 `json:"delta"`  // Recent - Previous, 0 when either is missing
+	Trend    string  `json:"trend"`  // up, down or flat
+}
+
+// PowerRecord is a best effort set by a recent activity
+type PowerRecord struct {
+	Date    string  `json:"date"` // YYYY-MM-DD
+	Message string  `json:"message"`
+	Watts   float64 `json:"watts,omitempty"`
+	Secs    float64 `json:"secs,omitempty"`
+}
+
+// PowerSummary is the response for the power endpoint
+type PowerSummary struct {
+	Date    string        `json:"date"` // Day of the curves, YYYY-MM-DD
+	Model   *PowerModel   `json:"model,omitempty"`
+	Efforts []BestEffort  `json:"efforts"`
+	Records []PowerRecord `json:"records,omitempty"`
+}
+
+// powerLimiter serialises power curve calls and remembers the last attempt
+var powerLimiter = newUpstreamLimiter()
+
+// SavePowerCurves stores the curves computed on date, replacing those of the
+// same athlete and date
+func SavePowerCurves(athleteID, date string, curves *PowerCurves) error {
+	curvesJSON, err := json.Marshal(curves)
+	if err != nil {
+		return err
+	}
+
+	// Stored in UTC so last_updated sorts and compares as text
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err = db.Exec(`
+		INSERT OR REPLACE INTO intervals_power (athlete_id, date, version, curves_json, last_updated)
+		VALUES (?, ?, ?, ?, ?)
+	`, athleteID, date, intervalsStoreVersion, string(curvesJSON), now)
+	return err
+}
+
+// GetLatestPowerCurves returns the most recently computed curves of an
+// athlete, their date and when they were stored. An empty athleteID matches
+// any athlete. Returns sql.ErrNoRows when none are stored.
+func GetLatestPowerCurves(athleteID string) (*PowerCurves, string, time.Time, error) {
+	var date, curvesJSON, lastUpdated string
+	err := db.QueryRow(`
+		SELECT date, curves_json, last_updated
+		FROM intervals_power
+		WHERE (? = '' OR athlete_id = ?) AND version = ?
+		ORDER BY date DESC, last_updated DESC
+		LIMIT 1
+	`, athleteID, athleteID, intervalsStoreVersion).Scan(&date, &curvesJSON, &lastUpdated)
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+
+	var curves PowerCurves
+	if err := json.Unmarshal([]byte(curvesJSON), &curves); err != nil {
+		return nil, "", time.Time{}, err
+	}
+	updatedAt, err := time.Parse(time.RFC3339, lastUpdated)
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+	return &curves, date, updatedAt, nil
+}
+
+// refreshPowerCurves fetches and stores today's season, recent and previous
+// curves, at most once per PowerMinFetchInterval. Returns the error of the
+// last attempt when skipped.
+func refreshPowerCurves() error {
+	now := time.Now()
+	date := now.Format("2006-01-02")
+	unlock := powerLimiter.lock(date)
+	defer unlock()
+
+	lastAttempt, lastErr := powerLimiter.lastAttempt(date)
+	if time.Since(lastAttempt) < PowerMinFetchInterval {
+		return lastErr
+	}
+
+	client, err := intervalsProvider()
+	if err != nil {
+		return err
+	}
+
+	curves, err := client.GetBestEffortCurves(now)
+	if err == nil {
+		err = SavePowerCurves(client.AthleteID(), date, curves)
+	}
+	powerLimiter.recordAttempt(date, err)
+	return err
+}
+
+// powerTrend compares recent with previous watts
+func powerTrend(recent, previous float64) string {
+	switch {
+	case previous == 0 || recent == 0:
+		return TrendFlat
+	case recent > previous*(1+PowerTrendFlat):
+		return TrendUp
+	case recent < previous*(1-PowerTrendFlat):
+		return TrendDown
+	}
+	return TrendFlat
+}
+
+// bestEfforts builds the best efforts table from the curves
+func bestEfforts(curves *PowerCurves) []BestEffort {
+	efforts := make([]BestEffort, 0, len(bestEffortDurations))
+	for _, d := range bestEffortDurations {
+		effort := BestEffort{
+			Duration: d.Label,
+			Season:   curves.Season.watts(d.Secs),
+			Recent:   curves.Recent.watts(d.Secs),
+			Previous: curves.Previous.watts(d.Secs),
+		}
+		// Without an effort in either window there is nothing to compare
+		if effort.Recent > 0 && effort.Previous > 0 {
+			effort.Delta = round1(effort.Recent - effort.Previous)
+		}
+		effort.Trend = powerTrend(effort.Recent, effort.Previous)
+		efforts = append(efforts, effort)
+	}
+	return efforts
+}
+
+// sportInfo returns the wellness model of sport
+func sportInfo(f Fitness, sport string) (eftp, wPrime, pMax float64, ok bool) {
+	for _, info := range f.SportInfo {
+		if info.Type == sport {
+			return info.Eftp, info.WPrime, info.PMax, true
+		}
+	}
+	return 0, 0, 0, false
+}
+
+// powerModel combines the latest wellness model with the rolling CP of the
+// latest activity that has one. Returns nil without either.
+func powerModel(day *TrainingDay, sixWeeksAgo []Fitness) *PowerModel {
+	var model PowerModel
+	found := false
+
+	if f := day.Fitness; f.Eftp > 0 || f.WPrime > 0 || f.PMax > 0 {
+		model.Eftp, model.WPrime, model.PMax = round1(f.Eftp), round1(f.WPrime/1000), f.PMax
+		found = true
+	}
+	for _, a := range day.Activities {
+		if a.RollingCp > 0 {
+			model.Cp = a.RollingCp
+			if model.WPrime == 0 {
+				model.WPrime = round1(a.RollingWPrime / 1000)
+			}
+			if model.PMax == 0 {
+				model.PMax = a.RollingPMax
+			}
+			if model.Eftp == 0 {
+				model.Eftp = a.RollingFtp
+			}
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	// The oldest stored record of the window stands for 6 weeks ago
+	for _, f := range sixWeeksAgo {
+		if eftp, _, _, ok := sportInfo(f, PowerSport); ok && eftp > 0 && model.Eftp > 0 {
+			delta := round1(model.Eftp - eftp)
+			model.EftpDelta = &delta
+			break
+		}
+	}
+	return &model
+}
+
+// powerRecords returns the power achievements as records, in their order
+func powerRecords(achievements []Achievement) []PowerRecord {
+	records := make([]PowerRecord, 0, len(achievements))
+	for _, a := range achievements {
+		records = append(records, PowerRecord{Date: a.Date, Message: a.Message, Watts: a.Watts, Secs: a.Secs})
+	}
+	return records
+}
+
+// handlePower returns the current power model, the best efforts table with
+// its 6 week trend and the latest power records
+func handlePower(w http.ResponseWriter, r *http.Request) {
+	if !providesIntervals() {
+		writeNotSupported(w, "The power curves")
+		return
+	}
+
+	// Without credentials the stored curves of any athlete are served
+	athleteID := trainingAthleteID()
+
+	now := time.Now()
+	today := now.Format("2006-01-02")
+
+	curves, date, updatedAt, err := GetLatestPowerCurves(athleteID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error reading power curves: %v", err)
+		http.Error(w, "Failed to read power curves", http.StatusInternalServerError)
+		return
+	}
+
+	if date != today || time.Since(updatedAt) > intervalsMaxAge {
+		if err := refreshPowerCurves(); err != nil {
+			log.Printf("Failed to fetch power curves from intervals.icu: %v", err)
+			if date == "" {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("X-Cache-Stale", "true")
+		} else if curves, date, _, err = GetLatestPowerCurves(athleteID); err != nil {
+			log.Printf("Error reading power curves: %v", err)
+			http.Error(w, "Failed to read power curves", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	response := PowerSummary{
+		Date:    date,
+		Efforts: bestEfforts(curves),
+	}
+
+	// The model and records come from the stored intervals data
+	if day, err := GetLatestIntervals(athleteID); err == nil {
+		oldest := now.AddDate(0, 0, -PowerTrendDays).Format("2006-01-02")
+		sixWeeksAgo, _, err := GetWellness(athleteID, oldest, now.AddDate(0, 0, 7-PowerTrendDays).Format("2006-01-02"))
+		if err != nil {
+			log.Printf("Error reading wellness: %v", err)
+		}
+		response.Model = powerModel(day, sixWeeksAgo)
+	}
+
+	// Records are the power achievements recorded when activities are synced
+	if achievements, err := GetPowerAchievements(athleteID, PowerRecordsLimit); err != nil {
+		log.Printf("Error reading power achievements: %v", err)
+	} else {
+		response.Records = powerRecords(achievements)
+	}
+
+	json.NewEncoder(w).Encode(response)
+	log.Printf("[%s] GET /api/intervals/power -> %s", time.Now().Format("15:04:05"), date)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetBestEffortCurves(t *testing.T) {
+	client := useIntervalsFixtures(t)
+
+	curves, err := client.GetBestEffortCurves(time.Date(2026, 2, 2, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The two 42 day windows meet without a gap
+	ids := bestEffortCurveIDs(time.Date(2026, 2, 2, 0, 0, 0, 0, time.UTC))
+	if want := []string{"s0", "r.2025-12-23.2026-02-02", "r.2025-11-11.2025-12-22"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("curve ids = %v, want %v", ids, want)
+	}
+	if curves.Season.ID != ids[0] || curves.Recent.ID != ids[1] || curves.Previous.ID != ids[2] {
+		t.Errorf("curves = %s, %s, %s", curves.Season.ID, curves.Recent.ID, curves.Previous.ID)
+	}
+	if curves.Season.watts(1200) != 271 || curves.Previous.watts(1200) != 0 {
+		t.Errorf("20m season %v, previous %v", curves.Season.watts(1200), curves.Previous.watts(1200))
+	}
+
+	efforts := bestEfforts(curves)
+	want := []BestEffort{
+		{Duration: "5s", Season: 968, Recent: 941, Previous: 968, Delta: -27, Trend: TrendDown},
+		{Duration: "1m", Season: 472, Recent: 455, Previous: 460, Delta: -5, Trend: TrendDown},
+		{Duration: "5m", Season: 318, Recent: 318, Previous: 301, Delta: 17, Trend: TrendUp},
+		{Duration: "20m", Season: 271, Recent: 268, Previous: 0, Delta: 0, Trend: TrendFlat},
+	}
+	for i := range want {
+		if efforts[i] != want[i] {
+			t.Errorf("effort %d = %+v, want %+v", i, efforts[i], want[i])
+		}
+	}
+}
+
+func TestPowerTrend(t *testing.T) {
+	tests := []struct {
+		recent, previous float64
+		want             string
+	}{
+		{300, 290, TrendUp},
+		{300, 298, TrendFlat},
+		{280, 300, TrendDown},
+		{300, 0, TrendFlat},
+	}
+	for _, tt := range tests {
+		if got := powerTrend(tt.recent, tt.previous); got != tt.want {
+			t.Errorf("powerTrend(%v, %v) = %s, want %s", tt.recent, tt.previous, got, tt.want)
+		}
+	}
+}
+
+func TestPowerModel(t *testing.T) {
+	day := &TrainingDay{Activities: []TrainingActivity{{RollingCp: 250, RollingWPrime: 20000, RollingPMax: 900, RollingFtp: 255}}}
+
+	// Without a wellness model the rolling activity values are used
+	model := powerModel(day, nil)
+	if model == nil || model.Cp != 250 || model.WPrime != 20 || model.PMax != 900 || model.Eftp != 255 || model.EftpDelta != nil {
+		t.Fatalf("model = %+v", model)
+	}
+
+	var sixWeeksAgo []Fitness
+	if err := json.Unmarshal([]byte(`[{"sportInfo": [{"type": "Run", "eftp": 4.1}, {"type": "Ride", "eftp": 248}]}]`), &sixWeeksAgo); err != nil {
+		t.Fatal(err)
+	}
+	if model := powerModel(day, sixWeeksAgo); model.EftpDelta == nil || *model.EftpDelta != 7 {
+		t.Errorf("eFTP delta = %v", model.EftpDelta)
+	}
+
+	if model := powerModel(&TrainingDay{}, nil); model != nil {
+		t.Errorf("model without data = %+v", model)
+	}
+}
+
+func TestHandlePower(t *testing.T) {
+	useIntervalsFixtures(t)
+	if err := refreshIntervals(); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	handlePower(rec, httptest.NewRequest("GET", "/api/intervals/power", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Cache-Stale") != "" {
+		t.Fatalf("status %d, headers %v: %s", rec.Code, rec.Header(), rec.Body)
+	}
+
+	var summary PowerSummary
+	if err := json.NewDecoder(rec.Body).Decode(&summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Date != time.Now().Format("2006-01-02") || len(summary.Efforts) != 4 {
+		t.Errorf("summary = %+v", summary)
+	}
+
+	// eFTP, W' and Pmax from today's wellness, CP from the latest activity
+	if m := summary.Model; m == nil || m.Eftp != 258.4 || m.PMax != 912 || m.Cp != 255 {
+		t.Errorf("model = %+v", summary.Model)
+	}
+	if len(summary.Records) != 1 || summary.Records[0].Date != "2026-02-01" || summary.Records[0].Watts != 318 {
+		t.Errorf("records = %+v", summary.Records)
+	}
+}
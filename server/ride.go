@@ -0,0 +1,333 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Ride advisor limits
+const (
+	RideMinScore     = 50 // Hours scoring below this are never part of a window
+	RideMaxWindows   = 3
+	RideDefaultHours = 2 // Ride length when the training state is unknown
+	RideMaxHours     = 6
+)
+
+// TrainingState is the current fitness, fatigue and form (TSB = CTL - ATL)
+type TrainingState struct {
+	Ctl  float64 `json:"ctl"`
+	Atl  float64 `json:"atl"`
+	Tsb  float64 `json:"tsb"`
+	Form string  `json:"form"` // Training status state, see ClassifyTraining
+}
+
+// newTrainingState classifies the form with the configured training
+// thresholds
+func newTrainingState(ctl, atl, rampRate float64) *TrainingState {
+	return &TrainingState{
+		Ctl:  round1(ctl),
+		Atl:  round1(atl),
+		Tsb:  round1(ctl - atl),
+		Form: ClassifyTraining(config.Training, ctl, atl, rampRate).State,
+	}
+}
+
+// rideHours is the ride length suggested for the form: long when rested,
+// short at high risk
+func (s *TrainingState) rideHours() int {
+	if s == nil {
+		return RideDefaultHours
+	}
+	switch s.Form {
+	case StatusTransition, StatusFresh:
+		return 3
+	case StatusHighRisk:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// RideWindow is a run of consecutive daylight hours suited for riding
+type RideWindow struct {
+	Day        string   `json:"day"`   // "today" or "tomorrow"
+	Start      string   `json:"start"` // Local "15:04"
+	End        string   `json:"end"`
+	Score      int      `json:"score"` // 0-100, average of the hours
+	Temp       float64  `json:"temp"`  // Average
+	WindSpeed  float64  `json:"wind"`  // Peak
+	PrecipProb int      `json:"pop"`   // Peak %
+	Reasons    []string `json:"reasons"`
+	Summary    string   `json:"summary"` // e.g. "14:00-16:00, dry, 12 km/h wind"
+
+	start, end time.Time
+}
+
+// RideAdvice is the response for the ride advisor endpoint
+type RideAdvice struct {
+	Location string         `json:"location"`
+	Training *TrainingState `json:"training,omitempty"` // Omitted when no intervals data is cached
+	Hours    int            `json:"hours"`              // Window length, shortened when none fit
+	Advice   string         `json:"advice"`             // One line for the device
+	Windows  []RideWindow   `json:"windows"`            // Best first
+	Units    UnitLabels     `json:"units"`
+}
+
+// scoreRideHour rates one forecast hour for outdoor riding, from 0 to 100
+func scoreRideHour(h HourlyWeather) int {
+	condition := describeWeatherCode(h.Code, h.IsDay)
+	if condition.Category == CategoryStorm || condition.Category == CategorySnow || condition.Freezing {
+		return 0
+	}
+
+	score := 100.0
+
+	// Rain chance, and actual rain on top of it
+	score -= float64(h.PrecipProb) * 0.8
+	if h.Precip >= 0.5 {
+		score -= 30
+	}
+
+	// Wind above 20 km/h gets tiring, above 40 km/h unsafe
+	switch {
+	case h.WindSpeed > 40:
+		score = 0
+	case h.WindSpeed > 20:
+		score -= (h.WindSpeed - 20) * 2
+	}
+
+	// Comfort band is 12-24°C
+	switch {
+	case h.Temp < 5:
+		score -= 21 + (5-h.Temp)*5
+	case h.Temp < 12:
+		score -= (12 - h.Temp) * 3
+	case h.Temp > 30:
+		score -= 18 + (h.Temp-30)*5
+	case h.Temp > 24:
+		score -= (h.Temp - 24) * 3
+	}
+
+	return int(max(0, min(100, score)) + 0.5)
+}
+
+// temperatureFeel describes a temperature in the words used for ride reasons
+func temperatureFeel(temp float64) string {
+	switch {
+	case temp < 5:
+		return "cold"
+	case temp < 12:
+		return "cool"
+	case temp <= 24:
+		return "mild"
+	case temp <= 30:
+		return "warm"
+	default:
+		return "hot"
+	}
+}
+
+// newRideWindow summarises consecutive hours, in units
+func newRideWindow(hours []HourlyWeather, scores []int, day string, zone *time.Location, units Units) RideWindow {
+	window := RideWindow{
+		Day:   day,
+		start: hours[0].Time,
+		end:   hours[len(hours)-1].Time.Add(time.Hour),
+	}
+	window.Start = window.start.In(zone).Format("15:04")
+	window.End = window.end.In(zone).Format("15:04")
+
+	var score int
+	for i, h := range hours {
+		score += scores[i]
+		window.Temp += h.Temp
+		window.WindSpeed = max(window.WindSpeed, h.WindSpeed)
+		window.PrecipProb = max(window.PrecipProb, h.PrecipProb)
+	}
+	window.Score = score / len(hours)
+	temp := window.Temp / float64(len(hours))
+	window.Temp = units.Temp(round1(temp))
+
+	rain := "dry"
+	if window.PrecipProb > 10 {
+		rain = fmt.Sprintf("%d%% rain", window.PrecipProb)
+	}
+	wind := units.FormatSpeed(window.WindSpeed) + " wind"
+	window.WindSpeed = units.Speed(window.WindSpeed)
+	window.Reasons = []string{rain, wind, temperatureFeel(temp)}
+	window.Summary = fmt.Sprintf("%s-%s, %s, %s", window.Start, window.End, rain, wind)
+
+	return window
+}
+
+// findRideWindows returns every window of length consecutive daylight hours
+// starting after now, today or tomorrow, where each hour scores at least
+// RideMinScore
+func findRideWindows(hours []HourlyWeather, now time.Time, zone *time.Location, length int, units Units) []RideWindow {
+	localNow := now.In(zone)
+	today := time.Date(localNow.Year(), localNow.Month(), localNow.Day(), 0, 0, 0, 0, zone)
+	tomorrow := today.AddDate(0, 0, 1)
+	end := today.AddDate(0, 0, 2)
+
+	dayOf := func(h HourlyWeather) string {
+		if h.Time.Before(tomorrow) {
+			return "today"
+		}
+		return "tomorrow"
+	}
+
+	var windows []RideWindow
+	var run []HourlyWeather
+	var scores []int
+
+	flush := func() {
+		for i := 0; i+length <= len(run); i++ {
+			windows = append(windows, newRideWindow(run[i:i+length], scores[i:i+length], dayOf(run[i]), zone, units))
+		}
+		run, scores = nil, nil
+	}
+
+	for _, h := range hours {
+		if h.Time.Before(now) {
+			continue
+		}
+		if !h.Time.Before(end) {
+			break
+		}
+
+		score := scoreRideHour(h)
+		// Windows never span two days or a gap in the forecast
+		breaks := len(run) > 0 && (dayOf(run[0]) != dayOf(h) || !h.Time.Equal(run[len(run)-1].Time.Add(time.Hour)))
+		if breaks || !h.IsDay || score < RideMinScore {
+			flush()
+		}
+		if h.IsDay && score >= RideMinScore {
+			run = append(run, h)
+			scores = append(scores, score)
+		}
+	}
+	flush()
+
+	return windows
+}
+
+// AdviseRide scores the remaining daylight hours of today and tomorrow and
+// returns the best non-overlapping ride windows. The window length follows the
+// training state, shortened when no window of that length exists.
+func AdviseRide(hours []HourlyWeather, now time.Time, zone *time.Location, training *TrainingState, length int, units Units) RideAdvice {
+	// Hours reports the length the windows were found with, the one asked
+	// for when there are none
+	advice := RideAdvice{Training: training, Hours: length, Units: units.Labels()}
+
+	var windows []RideWindow
+	for n := length; n >= 1; n-- {
+		if windows = findRideWindows(hours, now, zone, n, units); len(windows) > 0 {
+			advice.Hours = n
+			break
+		}
+	}
+
+	// Best first, earlier first on ties
+	sort.SliceStable(windows, func(i, j int) bool {
+		return windows[i].Score > windows[j].Score
+	})
+
+	overlaps := func(w RideWindow) bool {
+		for _, chosen := range advice.Windows {
+			if w.start.Before(chosen.end) && chosen.start.Before(w.end) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, w := range windows {
+		if len(advice.Windows) == RideMaxWindows {
+			break
+		}
+		if !overlaps(w) {
+			advice.Windows = append(advice.Windows, w)
+		}
+	}
+
+	if len(advice.Windows) == 0 {
+		advice.Windows = []RideWindow{}
+		advice.Advice = "No good ride window"
+		return advice
+	}
+
+	best := advice.Windows[0]
+	advice.Advice = "Best ride: " + best.Summary
+	if best.Day == "tomorrow" {
+		advice.Advice = "Best ride tomorrow: " + best.Summary
+	}
+	if training != nil && training.Form == StatusHighRisk {
+		advice.Advice += ", keep it easy"
+	}
+
+	return advice
+}
+
+// currentTrainingState returns the training state from the cached intervals
+// data, so the advisor never calls intervals.icu itself
+func currentTrainingState() *TrainingState {
+	day, err := GetLatestIntervals(trainingAthleteID())
+	if err != nil {
+		log.Printf("No training state for ride advice: %v", err)
+		return nil
+	}
+	return newTrainingState(day.Fitness.Ctl, day.Fitness.Atl, day.Fitness.RampRate)
+}
+
+// handleRideAdvice returns the best ride windows for ?loc=. ?hours= overrides
+// the ride length suggested by the training state.
+func handleRideAdvice(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	loc, err := config.GetLocation(query.Get("loc"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	units, err := unitsFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	training := currentTrainingState()
+	length := training.rideHours()
+	if v := query.Get("hours"); v != "" {
+		length, err = strconv.Atoi(v)
+		if err != nil || length < 1 || length > RideMaxHours {
+			http.Error(w, "hours must be between 1 and 6", http.StatusBadRequest)
+			return
+		}
+	}
+
+	hours, info, err := getHourlyCached(loc)
+	if err != nil {
+		log.Printf("Error fetching hourly forecast from %s: %v", weatherProvider.Name(), err)
+		http.Error(w, "Failed to fetch hourly forecast", http.StatusInternalServerError)
+		return
+	}
+
+	zone, err := time.LoadLocation(loc.Timezone)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	advice := AdviseRide(hours, time.Now(), zone, training, length, units)
+	advice.Location = loc.Name
+
+	writeCacheHeaders(w, info)
+	json.NewEncoder(w).Encode(advice)
+	log.Printf("[%s] GET /api/ride -> %s for %s",
+		time.Now().Format("15:04:05"), advice.Advice, loc.Name)
+}
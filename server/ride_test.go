@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// rideDay returns 48 mild, calm hours with a 40% rain chance from start, daylight from 08:00 to 18:00
+func rideDay(start time.Time) []HourlyWeather {
+	hours := make([]HourlyWeather, 48)
+	for i := range hours {
+		t := start.Add(time.Duration(i) * time.Hour)
+		hours[i] = HourlyWeather{
+			Time:       t,
+			Temp:       18,
+			WindSpeed:  10,
+			PrecipProb: 40,
+			Code:       3,
+			IsDay:      t.Hour() >= 8 && t.Hour() < 18,
+		}
+	}
+	return hours
+}
+
+func TestScoreRideHour(t *testing.T) {
+	ideal := HourlyWeather{Temp: 18, WindSpeed: 10, Code: 1, IsDay: true}
+	if got := scoreRideHour(ideal); got != 100 {
+		t.Errorf("ideal hour scored %d, want 100", got)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(h *HourlyWeather)
+		max    int
+	}{
+		{"storm", func(h *HourlyWeather) { h.Code = 95 }, 0},
+		{"snow", func(h *HourlyWeather) { h.Code = 73 }, 0},
+		{"gale", func(h *HourlyWeather) { h.WindSpeed = 45 }, 0},
+		{"rain", func(h *HourlyWeather) { h.PrecipProb = 70; h.Precip = 1.2 }, 20},
+		{"windy", func(h *HourlyWeather) { h.WindSpeed = 35 }, 70},
+		{"cold", func(h *HourlyWeather) { h.Temp = 2 }, 70},
+		{"hot", func(h *HourlyWeather) { h.Temp = 33 }, 70},
+	}
+	for _, tt := range tests {
+		h := ideal
+		tt.modify(&h)
+		if got := scoreRideHour(h); got > tt.max {
+			t.Errorf("%s hour scored %d, want at most %d", tt.name, got, tt.max)
+		}
+	}
+}
+
+func TestAdviseRide(t *testing.T) {
+	zone, _ := time.LoadLocation("Europe/Paris")
+	start := time.Date(2026, 10, 16, 0, 0, 0, 0, zone)
+	hours := rideDay(start)
+
+	// Today 14:00-16:00 is dry, tomorrow 10:00-11:00 is dry but windy
+	for i := 14; i < 16; i++ {
+		hours[i].PrecipProb = 0
+		hours[i].WindSpeed = 12
+	}
+	hours[24+10].PrecipProb = 0
+	hours[24+10].WindSpeed = 28
+
+	now := time.Date(2026, 10, 16, 9, 30, 0, 0, zone)
+	advice := AdviseRide(hours, now, zone, nil, 2, metricUnits)
+
+	if advice.Advice != "Best ride: 14:00-16:00, dry, 12 km/h wind" {
+		t.Errorf("advice = %q", advice.Advice)
+	}
+	if len(advice.Windows) != RideMaxWindows {
+		t.Fatalf("got %d windows, want %d", len(advice.Windows), RideMaxWindows)
+	}
+	for i, w := range advice.Windows[1:] {
+		prev := advice.Windows[i]
+		if w.Score > prev.Score {
+			t.Errorf("windows not sorted by score: %+v", advice.Windows)
+		}
+		if w.Day == prev.Day && w.Start < prev.End && prev.Start < w.End {
+			t.Errorf("windows %s-%s and %s-%s overlap", prev.Start, prev.End, w.Start, w.End)
+		}
+	}
+	for _, w := range advice.Windows {
+		if w.Day == "today" && w.Start < "10:00" {
+			t.Errorf("window %s-%s starts before now", w.Start, w.End)
+		}
+	}
+}
+
+func TestAdviseRideTrainingLength(t *testing.T) {
+	zone := time.UTC
+	start := time.Date(2026, 10, 16, 0, 0, 0, 0, zone)
+	now := start.Add(7 * time.Hour)
+
+	fresh := newTrainingState(60, 45, 2)
+	if fresh.Form != StatusFresh || fresh.rideHours() != 3 {
+		t.Errorf("fresh state = %+v, %d hours", fresh, fresh.rideHours())
+	}
+	advice := AdviseRide(rideDay(start), now, zone, fresh, fresh.rideHours(), metricUnits)
+	if w := advice.Windows[0]; w.Start != "08:00" || w.End != "11:00" {
+		t.Errorf("fresh window = %s-%s, want 08:00-11:00", w.Start, w.End)
+	}
+
+	fatigued := newTrainingState(50, 90, 0)
+	if fatigued.Form != StatusHighRisk || fatigued.rideHours() != 1 {
+		t.Errorf("fatigued state = %+v, %d hours", fatigued, fatigued.rideHours())
+	}
+	advice = AdviseRide(rideDay(start), now, zone, fatigued, fatigued.rideHours(), metricUnits)
+	if w := advice.Windows[0]; w.End != "09:00" || advice.Advice != "Best ride: 08:00-09:00, 40% rain, 10 km/h wind, keep it easy" {
+		t.Errorf("fatigued advice = %q", advice.Advice)
+	}
+}
+
+func TestTrainingStateThresholds(t *testing.T) {
+	t.Cleanup(func() { config = DefaultConfig() })
+
+	// TSB -20 is optimal with the default thresholds, high risk once they
+	// are tightened
+	if s := newTrainingState(50, 70, 0); s.Form != StatusOptimal || s.rideHours() != 2 {
+		t.Errorf("default thresholds: %+v, %d hours", s, s.rideHours())
+	}
+	config.Training.HighRiskTsb = -15
+	if s := newTrainingState(50, 70, 0); s.Form != StatusHighRisk || s.rideHours() != 1 {
+		t.Errorf("tightened thresholds: %+v, %d hours", s, s.rideHours())
+	}
+
+	// A steep ramp rate is high risk whatever the form
+	if s := newTrainingState(60, 50, 12); s.Form != StatusHighRisk {
+		t.Errorf("steep ramp: %+v", s)
+	}
+}
+
+func TestAdviseRideShortensWindows(t *testing.T) {
+	zone := time.UTC
+	start := time.Date(2026, 10, 16, 0, 0, 0, 0, zone)
+	hours := rideDay(start)
+
+	// Only isolated rideable hours
+	for i := range hours {
+		if i%2 == 0 {
+			hours[i].Code = 95
+		}
+	}
+
+	advice := AdviseRide(hours, start, zone, nil, 3, metricUnits)
+	if len(advice.Windows) == 0 || advice.Windows[0].Start != "09:00" || advice.Windows[0].End != "10:00" {
+		t.Errorf("windows = %+v, want 1 hour windows from 09:00", advice.Windows)
+	}
+	if advice.Hours != 1 {
+		t.Errorf("hours = %d, want the 1 hour the windows were found with", advice.Hours)
+	}
+
+	for i := range hours {
+		hours[i].Code = 95
+	}
+	advice = AdviseRide(hours, start, zone, nil, 2, metricUnits)
+	if len(advice.Windows) != 0 || advice.Advice != "No good ride window" {
+		t.Errorf("stormy advice = %+v", advice)
+	}
+}
+
+func TestHandleRideAdvice(t *testing.T) {
+	useFakeWeather(t)
+
+	rec := httptest.NewRecorder()
+	handleRideAdvice(rec, httptest.NewRequest("GET", "/api/ride?hours=1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	var advice RideAdvice
+	if err := json.Unmarshal(rec.Body.Bytes(), &advice); err != nil {
+		t.Fatal(err)
+	}
+	if advice.Location != "Aix-les-Bains" || advice.Hours != 1 || advice.Advice == "" {
+		t.Errorf("unexpected advice: %+v", advice)
+	}
+
+	rec = httptest.NewRecorder()
+	handleRideAdvice(rec, httptest.NewRequest("GET", "/api/ride?hours=12", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("hours=12 status = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Background refresh job names, also used as single-flight keys
+const (
+	RefreshIntervals      = "intervals"
+	RefreshWeather        = "weather"
+	RefreshAirQuality     = "air_quality"
+	RefreshWeatherHistory = "weather_history"
+	FlushWellness         = "wellness_queue"
+)
+
+// flightGroup de-duplicates concurrent calls: while a call for a key runs,
+// other callers for the same key wait for it and share its error
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done chan struct{}
+	err  error
+}
+
+// Do runs fn unless a call for key is already running, in which case it
+// waits for that one instead
+func (g *flightGroup) Do(key string, fn func() error) error {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-call.done
+		return call.err
+	}
+	call := &flightCall{done: make(chan struct{})}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	call.err = fn()
+	close(call.done)
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+
+	return call.err
+}
+
+// refreshFlights is shared by scheduled refreshes and handlers refreshing a
+// cold cache, so a device poll joins a refresh already in progress
+var refreshFlights flightGroup
+
+// refreshJob is a data source refreshed in the background
+type refreshJob struct {
+	name     string
+	interval time.Duration
+	refresh  func() error
+}
+
+// RefreshStatus is the state of a background refresh reported by /health
+type RefreshStatus struct {
+	Name      string `json:"name"`
+	Interval  string `json:"interval"`
+	LastRun   string `json:"last_run,omitempty"`
+	LastError string `json:"last_error,omitempty"`
+	NextRun   string `json:"next_run,omitempty"`
+}
+
+// refreshScheduler runs each job on its own cadence in a goroutine. Every
+// wait is spread by up to jitter of the interval, so sources don't all hit
+// their upstreams at once.
+type refreshScheduler struct {
+	jitter float64
+	jobs   map[string]refreshJob
+
+	mu     sync.Mutex
+	status map[string]*RefreshStatus
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// refresher is the running scheduler, nil until startRefreshScheduler
+var refresher *refreshScheduler
+
+func newRefreshScheduler(jitter float64, jobs ...refreshJob) *refreshScheduler {
+	s := &refreshScheduler{
+		jitter: jitter,
+		jobs:   make(map[string]refreshJob),
+		status: make(map[string]*RefreshStatus),
+		stop:   make(chan struct{}),
+	}
+	for _, job := range jobs {
+		if job.interval <= 0 {
+			log.Printf("Background refresh of %s disabled", job.name)
+			continue
+		}
+		s.jobs[job.name] = job
+		s.status[job.name] = &RefreshStatus{Name: job.name, Interval: job.interval.String()}
+	}
+	return s
+}
+
+// Start runs every job once right away, then on its cadence until Stop
+func (s *refreshScheduler) Start() {
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			for {
+				s.run(job)
+
+				wait := s.jittered(job.interval)
+				s.mu.Lock()
+				s.status[job.name].NextRun = time.Now().Add(wait).Format(time.RFC3339)
+				s.mu.Unlock()
+
+				select {
+				case <-time.After(wait):
+				case <-s.stop:
+					return
+				}
+			}
+		}()
+	}
+}
+
+// Stop ends the refresh loops and waits for running refreshes to finish
+func (s *refreshScheduler) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+// run refreshes job, sharing the call with a handler refreshing the same source
+func (s *refreshScheduler) run(job refreshJob) {
+	err := refreshFlights.Do(job.name, job.refresh)
+	if err != nil {
+		log.Printf("Background refresh of %s failed: %v", job.name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := s.status[job.name]
+	status.LastRun = time.Now().Format(time.RFC3339)
+	status.LastError = ""
+	if err != nil {
+		status.LastError = err.Error()
+	}
+}
+
+// jittered returns interval shifted randomly by up to ±jitter of it
+func (s *refreshScheduler) jittered(interval time.Duration) time.Duration {
+	spread := time.Duration(float64(interval) * s.jitter)
+	if spread <= 0 {
+		return interval
+	}
+	return interval - spread + time.Duration(rand.Int63n(int64(2*spread)+1))
+}
+
+// scheduledMaxIntervals is how many refresh intervals old the data of a
+// scheduled source may be served. Beyond, its job is taken to be failing and
+// polls fetch on demand again.
+const scheduledMaxIntervals = 2
+
+// covers reports whether the named source is refreshed in the background and
+// data of that age is recent enough to be served without fetching it
+func (s *refreshScheduler) covers(name string, age time.Duration) bool {
+	if s == nil {
+		return false
+	}
+	job, ok := s.jobs[name]
+	return ok && age < scheduledMaxIntervals*job.interval
+}
+
+// statuses returns the state of every job for /health, by name
+func (s *refreshScheduler) statuses() []RefreshStatus {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]RefreshStatus, 0, len(s.status))
+	for _, status := range s.status {
+		statuses = append(statuses, *status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// startRefreshScheduler starts refreshing every source on its configured
+// cadence, so handlers answer from the latest snapshot
+func startRefreshScheduler() {
+	cfg := config.Refresh
+
+	// Check-ins are only taken, and so queued, for intervals.icu
+	flushInterval := cfg.WellnessQueue.Duration
+	if !providesIntervals() {
+		flushInterval = 0
+	}
+
+	refresher = newRefreshScheduler(cfg.Jitter,
+		refreshJob{name: RefreshIntervals, interval: cfg.Intervals.Duration, refresh: refreshIntervals},
+		refreshJob{name: RefreshWeather, interval: cfg.Weather.Duration, refresh: refreshWeather},
+		refreshJob{name: RefreshAirQuality, interval: cfg.AirQuality.Duration, refresh: refreshAirQuality},
+		refreshJob{name: RefreshWeatherHistory, interval: config.Weather.HistoryInterval.Duration, refresh: recordWeather},
+		refreshJob{name: FlushWellness, interval: flushInterval, refresh: flushWellnessQueue},
+	)
+	refresher.Start()
+}
+
+// refreshWeather fetches every cached weather payload of every location
+func refreshWeather() error {
+	var errs []error
+	for key := range config.Weather.Locations {
+		loc, err := config.GetLocation(key)
+		if err != nil {
+			continue
+		}
+
+		errs = append(errs, refreshWeatherCached(currentWeatherSource(loc)))
+		if provider, ok := weatherProvider.(HourlyWeatherProvider); ok {
+			errs = append(errs, refreshWeatherCached(hourlyWeatherSource(loc, provider)))
+		}
+		if provider, ok := weatherProvider.(DailyWeatherProvider); ok {
+			errs = append(errs, refreshWeatherCached(dailyWeatherSource(loc, provider)))
+		}
+		if provider, ok := weatherProvider.(AstronomyProvider); ok {
+			errs = append(errs, refreshWeatherCached(sunSource(loc, provider)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// refreshAirQuality fetches the air quality of every location
+func refreshAirQuality() error {
+	var errs []error
+	for key := range config.Weather.Locations {
+		loc, err := config.GetLocation(key)
+		if err != nil {
+			continue
+		}
+		errs = append(errs, refreshWeatherCached(airQualitySource(loc)))
+	}
+	return errors.Join(errs...)
+}
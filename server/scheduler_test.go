@@ -0,0 +1,214 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// useRefresher marks the jobs as refreshed in the background, without
+// starting them
+func useRefresher(t *testing.T, names ...string) {
+	t.Helper()
+
+	var jobs []refreshJob
+	for _, name := range names {
+		jobs = append(jobs, refreshJob{name: name, interval: time.Hour, refresh: func() error { return nil }})
+	}
+
+	previous := refresher
+	refresher = newRefreshScheduler(0, jobs...)
+	t.Cleanup(func() { refresher = previous })
+}
+
+func TestFlightGroup(t *testing.T) {
+	var g flightGroup
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = g.Do("intervals", func() error {
+				calls.Add(1)
+				<-release
+				return errors.New("down")
+			})
+		}()
+	}
+
+	// Let every caller join the first call before it returns
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("calls = %d, want 1", calls.Load())
+	}
+	for i, err := range errs {
+		if err == nil {
+			t.Errorf("caller %d got no error", i)
+		}
+	}
+
+	// Once done, the next call runs again
+	g.Do("intervals", func() error { calls.Add(1); return nil })
+	if calls.Load() != 2 {
+		t.Errorf("calls after completion = %d, want 2", calls.Load())
+	}
+}
+
+func TestRefreshSchedulerJitter(t *testing.T) {
+	s := newRefreshScheduler(0.1)
+	for range 50 {
+		if d := s.jittered(time.Hour); d < 54*time.Minute || d > 66*time.Minute {
+			t.Fatalf("jittered(1h) = %v, want within 10%%", d)
+		}
+	}
+
+	if d := newRefreshScheduler(0).jittered(time.Hour); d != time.Hour {
+		t.Errorf("jittered without jitter = %v", d)
+	}
+}
+
+func TestRefreshScheduler(t *testing.T) {
+	var fast, failing, disabled atomic.Int32
+	s := newRefreshScheduler(0.5,
+		refreshJob{name: "fast", interval: 5 * time.Millisecond, refresh: func() error { fast.Add(1); return nil }},
+		refreshJob{name: "failing", interval: time.Hour, refresh: func() error { failing.Add(1); return errors.New("down") }},
+		refreshJob{name: "disabled", interval: 0, refresh: func() error { disabled.Add(1); return nil }},
+	)
+
+	s.Start()
+	time.Sleep(50 * time.Millisecond)
+	s.Stop()
+
+	if fast.Load() < 3 {
+		t.Errorf("fast job ran %d times, want several", fast.Load())
+	}
+	if failing.Load() != 1 || disabled.Load() != 0 {
+		t.Errorf("failing ran %d times, disabled %d", failing.Load(), disabled.Load())
+	}
+	if !s.covers("fast", 0) || s.covers("disabled", 0) {
+		t.Errorf("unexpected scheduled jobs")
+	}
+	// Data the job should have refreshed twice over is no longer covered
+	if !s.covers("fast", 5*time.Millisecond) || s.covers("fast", 15*time.Millisecond) {
+		t.Errorf("unexpected staleness limit")
+	}
+
+	statuses := s.statuses()
+	if len(statuses) != 2 || statuses[0].Name != "failing" || statuses[0].LastError != "down" ||
+		statuses[1].LastRun == "" || statuses[1].NextRun == "" {
+		t.Errorf("statuses = %+v", statuses)
+	}
+}
+
+func TestRefreshWeather(t *testing.T) {
+	fake := useFakeWeather(t)
+
+	if err := refreshWeather(); err != nil {
+		t.Fatal(err)
+	}
+	if fake.Calls.Load() != 1 || fake.HourlyCalls.Load() != 1 || fake.DailyCalls.Load() != 1 || fake.SunCalls.Load() != 1 {
+		t.Errorf("upstream calls = %+v", fake)
+	}
+
+	// Polls answer from the refreshed cache
+	rec := httptest.NewRecorder()
+	handleWeather(rec, httptest.NewRequest("GET", "/api/weather", nil))
+	if rec.Header().Get("X-Cache-Age") == "" || fake.Calls.Load() != 1 {
+		t.Errorf("poll after refresh: headers %v, %d calls", rec.Header(), fake.Calls.Load())
+	}
+
+	// Upstream is still called at most once per minimum fetch interval
+	if err := refreshWeather(); err != nil {
+		t.Fatal(err)
+	}
+	if fake.Calls.Load() != 1 {
+		t.Errorf("refresh within min fetch interval called upstream: %d calls", fake.Calls.Load())
+	}
+}
+
+func TestScheduledWeatherAnswersFromSnapshot(t *testing.T) {
+	fake := useFakeWeather(t)
+	useRefresher(t, RefreshWeather)
+
+	if err := refreshWeather(); err != nil {
+		t.Fatal(err)
+	}
+
+	// An expired snapshot is served as stale instead of blocking on upstream
+	config.Weather.CacheTTL = Duration{}
+	config.Weather.MinFetchInterval = Duration{}
+	t.Cleanup(func() { config = DefaultConfig() })
+
+	rec := httptest.NewRecorder()
+	handleWeather(rec, httptest.NewRequest("GET", "/api/weather", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Cache-Stale") != "true" {
+		t.Errorf("status %d, headers %v", rec.Code, rec.Header())
+	}
+	if fake.Calls.Load() != 1 {
+		t.Errorf("upstream calls = %d, want 1", fake.Calls.Load())
+	}
+
+	// Past two refresh intervals the job is taken as failing, polls fetch again
+	old := time.Now().Add(-3 * time.Hour).UTC().Format(time.RFC3339)
+	if _, err := db.Exec(`UPDATE weather_cache SET last_updated = ? WHERE cache_key = 'current:aix'`, old); err != nil {
+		t.Fatal(err)
+	}
+	rec = httptest.NewRecorder()
+	handleWeather(rec, httptest.NewRequest("GET", "/api/weather", nil))
+	if rec.Header().Get("X-Cache-Fresh") != "true" || fake.Calls.Load() != 2 {
+		t.Errorf("outdated snapshot: headers %v, %d upstream calls", rec.Header(), fake.Calls.Load())
+	}
+
+	// Air quality is not scheduled here, so it is still fetched on demand
+	if refresher.covers(weatherRefreshJob("air:aix"), 0) {
+		t.Errorf("air quality reported as scheduled")
+	}
+}
+
+func TestScheduledIntervalsAnswersFromSnapshot(t *testing.T) {
+	useIntervalsFixtures(t)
+	useRefresher(t, RefreshIntervals)
+
+	// Yesterday's data is served as stale while the scheduler catches up
+	yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	if err := SaveIntervalsCache(&TrainingDay{AthleteID: "i42", Date: yesterday, Fitness: TrainingFitness{Ctl: 50}}); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	handleIntervals(rec, httptest.NewRequest("GET", "/api/intervals", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Cache-Stale") != "true" {
+		t.Errorf("status %d, headers %v", rec.Code, rec.Header())
+	}
+
+	// Unless the scheduler has failed to refresh it for two intervals
+	old := time.Now().Add(-3 * time.Hour).UTC().Format(time.RFC3339)
+	if _, err := db.Exec(`UPDATE intervals_days SET last_updated = ?`, old); err != nil {
+		t.Fatal(err)
+	}
+	rec = httptest.NewRecorder()
+	handleIntervals(rec, httptest.NewRequest("GET", "/api/intervals", nil))
+	if rec.Header().Get("X-Cache-Fresh") != "true" {
+		t.Errorf("outdated day: headers %v", rec.Header())
+	}
+
+	if err := refreshIntervals(); err != nil {
+		t.Fatal(err)
+	}
+	rec = httptest.NewRecorder()
+	handleIntervals(rec, httptest.NewRequest("GET", "/api/intervals", nil))
+	if rec.Header().Get("X-Cache-Stale") != "" || rec.Header().Get("X-Cache-Age") == "" {
+		t.Errorf("after refresh: headers %v", rec.Header())
+	}
+}
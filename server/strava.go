@@ -0,0 +1,477 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	STRAVA_CLIENT_ID     = "STRAVA_CLIENT_ID"
+	STRAVA_CLIENT_SECRET = "STRAVA_CLIENT_SECRET"
+	STRAVA_REFRESH_TOKEN = "STRAVA_REFRESH_TOKEN"
+)
+
+const stravaBaseURL = "https://www.strava.com"
+
+// stravaPageSize is the most activities Strava returns per page
+const stravaPageSize = 200
+
+// stravaTokenMargin renews the access token this long before it expires
+const stravaTokenMargin = 5 * time.Minute
+
+// Time constants, in days, of the estimated CTL and ATL
+const (
+	ctlDays = 42
+	atlDays = 7
+)
+
+// StravaActivity is an activity summary of the Strava API
+type StravaActivity struct {
+	ID                   int64   `json:"id"`
+	Name                 string  `json:"name"`
+	Type                 string  `json:"type"`
+	SportType            string  `json:"sport_type"`
+	StartDateLocal       string  `json:"start_date_local"` // Local time, with a misleading "Z"
+	Distance             float64 `json:"distance"`         // Meters
+	MovingTime           float64 `json:"moving_time"`      // Seconds
+	AverageWatts         float64 `json:"average_watts"`
+	WeightedAverageWatts float64 `json:"weighted_average_watts"`
+	Kilojoules           float64 `json:"kilojoules"`
+	AverageHeartrate     float64 `json:"average_heartrate"`
+	SufferScore          float64 `json:"suffer_score"` // Relative effort, used as the training load
+}
+
+// activity converts the summary to a training activity
+func (a StravaActivity) activity() TrainingActivity {
+	activityType := a.SportType
+	if activityType == "" {
+		activityType = a.Type
+	}
+	return TrainingActivity{
+		ID:               strconv.FormatInt(a.ID, 10),
+		Name:             a.Name,
+		Type:             activityType,
+		StartDateLocal:   strings.TrimSuffix(a.StartDateLocal, "Z"),
+		Distance:         a.Distance,
+		MovingTime:       a.MovingTime,
+		AverageWatts:     a.AverageWatts,
+		WeightedAvgWatts: a.WeightedAverageWatts,
+		AverageHeartrate: a.AverageHeartrate,
+		// The work done in kJ is about the energy burnt in kcal on a bike
+		Calories: a.Kilojoules,
+		Load:     a.SufferScore,
+	}
+}
+
+// stravaToken is the response of the token endpoint
+type stravaToken struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresAt    int64  `json:"expires_at"` // Unix seconds
+}
+
+// stravaClient talks to the Strava API for the athlete who authorised it
+type stravaClient struct {
+	baseURL      string
+	clientID     string
+	clientSecret string
+	client       *http.Client
+	retry        retryPolicy
+	breaker      *circuitBreaker
+
+	walletToken string     // Refresh token read from KWallet
+	renewMu     sync.Mutex // One token renewal at a time
+
+	mu           sync.Mutex
+	refreshToken string // Strava may rotate it with each new access token
+	accessToken  string
+	expiresAt    time.Time
+	athleteID    string // Read with the first activities
+}
+
+// newStravaClient creates a client with the configured retry policy and
+// circuit breaker
+func newStravaClient(baseURL, clientID, clientSecret, refreshToken string, client *http.Client) *stravaClient {
+	cfg := config.Strava
+	return &stravaClient{
+		baseURL:      strings.TrimSuffix(baseURL, "/"),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		walletToken:  refreshToken,
+		refreshToken: refreshToken,
+		client:       client,
+		retry: retryPolicy{
+			Attempts:  cfg.Retries + 1,
+			BaseDelay: cfg.RetryDelay.Duration,
+			MaxDelay:  upstreamMaxRetryDelay,
+		},
+		breaker: newCircuitBreaker("strava", cfg.BreakerFailures, cfg.BreakerCooldown.Duration),
+	}
+}
+
+var (
+	stravaMu  sync.Mutex
+	stravaAPI *stravaClient // Created on first use, see defaultStravaClient
+)
+
+// defaultStravaClient returns the shared client for the configured API,
+// reading the credentials from KWallet once. A failed read is retried on the
+// next call.
+func defaultStravaClient() (*stravaClient, error) {
+	stravaMu.Lock()
+	defer stravaMu.Unlock()
+
+	if stravaAPI != nil {
+		return stravaAPI, nil
+	}
+
+	clientID, err := GetSecret(STRAVA_CLIENT_ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Strava client ID: %w", err)
+	}
+	clientSecret, err := GetSecret(STRAVA_CLIENT_SECRET)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Strava client secret: %w", err)
+	}
+	refreshToken, err := GetSecret(STRAVA_REFRESH_TOKEN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Strava refresh token: %w", err)
+	}
+
+	stravaAPI = newStravaClient(config.Strava.BaseURL, clientID, clientSecret, refreshToken,
+		&http.Client{Timeout: config.Strava.Timeout.Duration})
+	stravaAPI.restoreRefreshToken()
+	registerBreaker(stravaAPI.breaker)
+	return stravaAPI, nil
+}
+
+// restoreRefreshToken replaces the refresh token from KWallet with the one
+// it was last rotated to, which Strava accepts instead
+func (c *stravaClient) restoreRefreshToken() {
+	rotated, err := GetStravaRefreshToken(c.clientID, c.walletToken)
+	if err == sql.ErrNoRows {
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to read the rotated Strava refresh token: %v", err)
+		return
+	}
+
+	c.mu.Lock()
+	c.refreshToken = rotated
+	c.mu.Unlock()
+}
+
+// GetStravaRefreshToken returns the refresh token last rotated from the
+// walletToken of a client. Returns sql.ErrNoRows when it was not rotated, or
+// KWallet holds another token since.
+func GetStravaRefreshToken(clientID, walletToken string) (string, error) {
+	var refreshToken string
+	err := db.QueryRow(`
+		SELECT refresh_token FROM strava_tokens
+		WHERE client_id = ? AND wallet_token = ?
+	`, clientID, walletToken).Scan(&refreshToken)
+	return refreshToken, err
+}
+
+// SaveStravaRefreshToken stores the refresh token rotated from the
+// walletToken of a client, so it survives restarts
+func SaveStravaRefreshToken(clientID, walletToken, refreshToken string) error {
+	_, err := db.Exec(`
+		INSERT OR REPLACE INTO strava_tokens (client_id, wallet_token, refresh_token, updated_at)
+		VALUES (?, ?, ?, ?)
+	`, clientID, walletToken, refreshToken, time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+// Name returns the provider name used in the config
+func (c *stravaClient) Name() string {
+	return ProviderStrava
+}
+
+// AthleteID returns the athlete the client reads, empty until the first
+// activities are fetched
+func (c *stravaClient) AthleteID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.athleteID
+}
+
+// validToken returns the access token unless it is missing or about to expire
+func (c *stravaClient) validToken() (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.accessToken, c.accessToken != "" && time.Now().Add(stravaTokenMargin).Before(c.expiresAt)
+}
+
+// token returns a valid access token, exchanging the refresh token for a new
+// one when it is missing or about to expire. The exchange runs without
+// holding c.mu, so AthleteID does not wait for it.
+func (c *stravaClient) token() (string, error) {
+	if token, ok := c.validToken(); ok {
+		return token, nil
+	}
+
+	// Renewals are serialised, the first one may rotate the refresh token
+	// the others would send
+	c.renewMu.Lock()
+	defer c.renewMu.Unlock()
+	if token, ok := c.validToken(); ok {
+		return token, nil
+	}
+
+	c.mu.Lock()
+	refreshToken := c.refreshToken
+	c.mu.Unlock()
+
+	form := url.Values{}
+	form.Set("client_id", c.clientID)
+	form.Set("client_secret", c.clientSecret)
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", refreshToken)
+
+	req, err := http.NewRequest("POST", c.baseURL+"/oauth/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", &upstreamError{Kind: ErrorRejected, Err: fmt.Errorf("failed to create request: %w", err)}
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var token stravaToken
+	if err := c.doOnce(req, &token); err != nil {
+		return "", err
+	}
+	if token.AccessToken == "" {
+		return "", &upstreamError{Kind: ErrorDecode, Status: http.StatusOK, Err: fmt.Errorf("token response without access token")}
+	}
+
+	c.mu.Lock()
+	c.accessToken = token.AccessToken
+	c.expiresAt = time.Unix(token.ExpiresAt, 0)
+	if token.RefreshToken != "" {
+		c.refreshToken = token.RefreshToken
+	}
+	c.mu.Unlock()
+	log.Printf("Strava access token renewed, expires at %s", time.Unix(token.ExpiresAt, 0).Format(time.RFC3339))
+
+	// The old refresh token stops working once rotated, and KWallet still
+	// holds it
+	if token.RefreshToken != "" && token.RefreshToken != refreshToken {
+		if err := SaveStravaRefreshToken(c.clientID, c.walletToken, token.RefreshToken); err != nil {
+			log.Printf("Failed to store the rotated Strava refresh token: %v", err)
+		}
+	}
+	return token.AccessToken, nil
+}
+
+// get requests path below /api/v3 and decodes the response into target.
+// Transient failures are retried, and nothing is sent while the circuit
+// breaker is open.
+func (c *stravaClient) get(path string, query url.Values, target any) error {
+	endpoint := c.baseURL + "/api/v3" + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	if err := c.breaker.allow(); err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := c.getOnce(endpoint, target)
+		if err == nil {
+			c.breaker.record(nil)
+			return nil
+		}
+
+		wait, retry := c.retry.delay(attempt, err)
+		if !retry {
+			c.breaker.record(err)
+			log.Printf("Strava GET %s failed after %d attempt(s): %v", path, attempt, err)
+			return err
+		}
+		log.Printf("Strava GET %s failed (attempt %d/%d), retrying in %v: %v",
+			path, attempt, c.retry.Attempts, wait.Round(time.Millisecond), err)
+		time.Sleep(wait)
+	}
+}
+
+// getOnce performs a single authorised GET. A refused access token is
+// dropped, so the next call renews it.
+func (c *stravaClient) getOnce(endpoint string, target any) error {
+	token, err := c.token()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return &upstreamError{Kind: ErrorRejected, Err: fmt.Errorf("failed to create request: %w", err)}
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	err = c.doOnce(req, target)
+	if errorKind(err) == ErrorAuth {
+		c.mu.Lock()
+		c.accessToken = ""
+		c.mu.Unlock()
+	}
+	return err
+}
+
+// doOnce sends req, returning a classified *upstreamError
+func (c *stravaClient) doOnce(req *http.Request, target any) error {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return &upstreamError{Kind: ErrorTransient, Err: fmt.Errorf("failed to perform request: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return classifyResponse(resp, body)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+		return &upstreamError{Kind: ErrorDecode, Status: resp.StatusCode, Err: fmt.Errorf("failed to decode response: %w", err)}
+	}
+	return nil
+}
+
+// GetAthleteID returns the ID of the athlete who authorised the client
+func (c *stravaClient) GetAthleteID() (string, error) {
+	if id := c.AthleteID(); id != "" {
+		return id, nil
+	}
+
+	var athlete struct {
+		ID int64 `json:"id"`
+	}
+	if err := c.get("/athlete", nil, &athlete); err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.athleteID = strconv.FormatInt(athlete.ID, 10)
+	return c.athleteID, nil
+}
+
+// GetActivities returns the activities started after the given time, newest
+// first
+func (c *stravaClient) GetActivities(after time.Time) ([]StravaActivity, error) {
+	var activities []StravaActivity
+	for page := 1; ; page++ {
+		query := url.Values{}
+		query.Set("after", strconv.FormatInt(after.Unix(), 10))
+		query.Set("per_page", strconv.Itoa(stravaPageSize))
+		query.Set("page", strconv.Itoa(page))
+
+		var batch []StravaActivity
+		if err := c.get("/athlete/activities", query, &batch); err != nil {
+			return nil, err
+		}
+		activities = append(activities, batch...)
+		if len(batch) < stravaPageSize {
+			break
+		}
+	}
+
+	sort.SliceStable(activities, func(i, j int) bool {
+		return activities[i].StartDateLocal > activities[j].StartDateLocal
+	})
+	return activities, nil
+}
+
+// GetDay fetches the activities of the configured fitness window ending on
+// date, estimates the fitness of date from them and keeps those of the past
+// two weeks, like intervals.icu
+func (c *stravaClient) GetDay(date string) (*TrainingDay, error) {
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return nil, err
+	}
+
+	athleteID, err := c.GetAthleteID()
+	if err != nil {
+		return nil, err
+	}
+
+	// Activities are filtered on their local date, so the window is padded by
+	// a day for athletes east of UTC
+	oldest := day.AddDate(0, 0, -config.Strava.FitnessDays)
+	summaries, err := c.GetActivities(oldest.AddDate(0, 0, -1))
+	if err != nil {
+		return nil, err
+	}
+
+	var activities []TrainingActivity
+	for _, s := range summaries {
+		if a := s.activity(); localDate(a.StartDateLocal) <= date {
+			activities = append(activities, a)
+		}
+	}
+
+	recentFrom := day.AddDate(0, 0, -14).Format("2006-01-02")
+	var recent []TrainingActivity
+	for _, a := range activities {
+		if localDate(a.StartDateLocal) >= recentFrom {
+			recent = append(recent, a)
+		}
+	}
+
+	return &TrainingDay{
+		AthleteID:  athleteID,
+		Date:       date,
+		Fitness:    estimateFitness(activities, oldest.Format("2006-01-02"), date),
+		Activities: recent,
+		UpdatedAt:  time.Now(),
+	}, nil
+}
+
+// estimateFitness computes CTL and ATL on date as exponentially weighted
+// averages of the daily training load since oldest (YYYY-MM-DD), and the ramp
+// rate as the CTL gained over the last week. Without earlier history CTL
+// starts from 0, so it is underestimated over the first weeks.
+func estimateFitness(activities []TrainingActivity, oldest, date string) TrainingFitness {
+	loads := make(map[string]float64)
+	for _, a := range activities {
+		loads[localDate(a.StartDateLocal)] += a.Load
+	}
+
+	first, err := time.Parse("2006-01-02", oldest)
+	if err != nil {
+		return TrainingFitness{}
+	}
+	weekAgo := ""
+	if end, err := time.Parse("2006-01-02", date); err == nil {
+		weekAgo = end.AddDate(0, 0, -7).Format("2006-01-02")
+	}
+
+	var ctl, atl, ctlWeekAgo float64
+	for d := first; d.Format("2006-01-02") <= date; d = d.AddDate(0, 0, 1) {
+		day := d.Format("2006-01-02")
+		ctl += (loads[day] - ctl) / ctlDays
+		atl += (loads[day] - atl) / atlDays
+		if day == weekAgo {
+			ctlWeekAgo = ctl
+		}
+	}
+
+	round := func(v float64) float64 { return math.Round(v*10) / 10 }
+	return TrainingFitness{
+		Ctl:      round(ctl),
+		Atl:      round(atl),
+		RampRate: round(ctl - ctlWeekAgo),
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stravaStandIn is a fake Strava API replaying testdata/strava_activities.json
+// for athlete 7, shifted so that its last day (2026-02-02) is today. It
+// renews tokens from refresh tokens "r1", "r2"... and can refuse the next
+// request.
+type stravaStandIn struct {
+	tokens int  // Tokens issued
+	reject bool // Answer the next API request with 401
+}
+
+func useStravaStandIn(t *testing.T) (*stravaClient, *stravaStandIn) {
+	t.Helper()
+	setupTestDB(t)
+
+	standIn := &stravaStandIn{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/oauth/token" {
+			if r.Method != "POST" || r.FormValue("client_id") != "c1" || r.FormValue("client_secret") != "s3cret" ||
+				r.FormValue("grant_type") != "refresh_token" || r.FormValue("refresh_token") != "r"+strconv.Itoa(standIn.tokens+1) {
+				http.Error(w, `{"message": "Bad Request"}`, http.StatusBadRequest)
+				return
+			}
+			standIn.tokens++
+			json.NewEncoder(w).Encode(stravaToken{
+				AccessToken:  "a" + strconv.Itoa(standIn.tokens),
+				RefreshToken: "r" + strconv.Itoa(standIn.tokens+1),
+				ExpiresAt:    time.Now().Add(6 * time.Hour).Unix(),
+			})
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer a"+strconv.Itoa(standIn.tokens) || standIn.reject {
+			standIn.reject = false
+			http.Error(w, `{"message": "Authorization Error"}`, http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v3/athlete":
+			w.Write([]byte(`{"id": 7, "firstname": "Test"}`))
+		case "/api/v3/athlete/activities":
+			if r.URL.Query().Get("after") == "" || r.URL.Query().Get("page") != "1" {
+				w.Write([]byte(`[]`))
+				return
+			}
+			data, err := os.ReadFile("testdata/strava_activities.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			today := time.Now()
+			data = []byte(strings.NewReplacer(
+				"2026-01-10", today.AddDate(0, 0, -23).Format("2006-01-02"),
+				"2026-01-30", today.AddDate(0, 0, -3).Format("2006-01-02"),
+				"2026-02-01", today.AddDate(0, 0, -1).Format("2006-01-02"),
+				"2026-02-02", today.Format("2006-01-02"),
+			).Replace(string(data)))
+			w.Write(data)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client := newStravaClient(server.URL, "c1", "s3cret", "r1", server.Client())
+	client.retry = retryPolicy{Attempts: 1}
+	return client, standIn
+}
+
+func TestStravaGetDay(t *testing.T) {
+	client, standIn := useStravaStandIn(t)
+	today := time.Now().Format("2006-01-02")
+
+	day, err := client.GetDay(today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if day.AthleteID != "7" || client.AthleteID() != "7" || day.Date != today {
+		t.Errorf("day = %s of %q", day.Date, day.AthleteID)
+	}
+
+	// The ride of 23 days ago only counts towards the fitness
+	if len(day.Activities) != 3 {
+		t.Fatalf("got %d activities, want 3", len(day.Activities))
+	}
+	ride := day.Activities[1]
+	if day.Activities[0].ID != "9014" || ride.ID != "9013" || ride.Type != "Ride" ||
+		strings.HasSuffix(ride.StartDateLocal, "Z") || ride.WeightedAvgWatts != 205 ||
+		ride.Calories != 1966 || ride.Load != 118 {
+		t.Errorf("activities = %+v", day.Activities)
+	}
+	if f := day.Fitness; f.Ctl <= 0 || f.Atl <= f.Ctl || f.RampRate <= 0 {
+		t.Errorf("fitness = %+v", f)
+	}
+
+	// The access token is reused until refused, then renewed with the
+	// rotated refresh token
+	if _, err := client.GetDay(today); err != nil || standIn.tokens != 1 {
+		t.Fatalf("second day: %v, %d tokens", err, standIn.tokens)
+	}
+	standIn.reject = true
+	if _, err := client.GetDay(today); errorKind(err) != ErrorAuth {
+		t.Fatalf("refused: err = %v, want auth error", err)
+	}
+	if _, err := client.GetDay(today); err != nil || standIn.tokens != 2 {
+		t.Errorf("after refusal: %v, %d tokens", err, standIn.tokens)
+	}
+
+	// After a restart the rotated refresh token is used, not the stale one
+	// still in KWallet
+	if stored, err := GetStravaRefreshToken("c1", "r1"); err != nil || stored != "r3" {
+		t.Fatalf("stored refresh token = %q, %v", stored, err)
+	}
+	restarted := newStravaClient(client.baseURL, "c1", "s3cret", "r1", client.client)
+	restarted.restoreRefreshToken()
+	if _, err := restarted.GetDay(today); err != nil || standIn.tokens != 3 {
+		t.Errorf("after restart: %v, %d tokens", err, standIn.tokens)
+	}
+
+	// A token stored in KWallet since replaces the rotated one
+	if _, err := GetStravaRefreshToken("c1", "new"); err != sql.ErrNoRows {
+		t.Errorf("rotated token of another KWallet token: err = %v", err)
+	}
+}
+
+func TestEstimateFitness(t *testing.T) {
+	activities := []TrainingActivity{
+		{StartDateLocal: "2026-02-01T09:30:00", Load: 30},
+		{StartDateLocal: "2026-02-01T18:00:00", Load: 12},
+		{StartDateLocal: "2026-01-20T09:30:00", Load: 500}, // Before the window
+	}
+
+	f := estimateFitness(activities, "2026-02-01", "2026-02-01")
+	if f.Ctl != 1 || f.Atl != 6 || f.RampRate != 1 {
+		t.Errorf("first day = %+v", f)
+	}
+	// 1 - 1/42 and 6 - 6/7 the next day
+	f = estimateFitness(activities, "2026-02-01", "2026-02-02")
+	if f.Ctl != 1 || f.Atl != 5.1 {
+		t.Errorf("next day = %+v", f)
+	}
+}
+
+func TestHandleIntervalsStrava(t *testing.T) {
+	client, _ := useStravaStandIn(t)
+
+	config.TrainingProvider = ProviderStrava
+	t.Cleanup(func() { config = DefaultConfig() })
+	previous := stravaAPI
+	stravaAPI = client
+	t.Cleanup(func() { stravaAPI = previous })
+
+	rec := httptest.NewRecorder()
+	handleIntervals(rec, httptest.NewRequest("GET", "/api/intervals", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+
+	var data DisplayData
+	if err := json.NewDecoder(rec.Body).Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Ctl <= 0 || data.Status == nil || len(data.Activities) != 3 {
+		t.Fatalf("unexpected payload: %+v", data)
+	}
+	// Oldest first, as for intervals.icu
+	if a := data.Activities[0]; a.ID != "9012" || a.DistanceDisplay != 8 || a.MovingTimeDisplay != "0:45" {
+		t.Errorf("activity = %+v", a)
+	}
+
+	if _, err := GetLatestIntervals("7"); err != nil {
+		t.Errorf("day not stored for the Strava athlete: %v", err)
+	}
+}
+
+func TestDefaultTrainingProvider(t *testing.T) {
+	t.Cleanup(func() { config = DefaultConfig() })
+
+	config.TrainingProvider = "garmin"
+	if _, err := defaultTrainingProvider(); err == nil {
+		t.Error("unknown provider accepted")
+	}
+	if err := config.Validate(); err == nil {
+		t.Error("config with an unknown provider is valid")
+	}
+}
+
+func TestIntervalsOnlyEndpointsWithStrava(t *testing.T) {
+	client, _ := useStravaStandIn(t)
+
+	config.TrainingProvider = ProviderStrava
+	t.Cleanup(func() { config = DefaultConfig() })
+	previous := stravaAPI
+	stravaAPI = client
+	t.Cleanup(func() { stravaAPI = previous })
+
+	// intervals.icu credentials left over are not used
+	intervalsCalls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		intervalsCalls++
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(server.Close)
+	previousIntervals := intervalsAPI
+	intervalsAPI = newIntervalsClient(server.URL, "i42", "secret", server.Client())
+	t.Cleanup(func() { intervalsAPI = previousIntervals })
+
+	if err := refreshIntervals(); err != nil {
+		t.Fatal(err)
+	}
+
+	handlers := map[string]http.HandlerFunc{
+		"GET /api/intervals/fitness":   handleFitnessHistory,
+		"GET /api/intervals/calendar":  handleCalendar,
+		"GET /api/intervals/power":     handlePower,
+		"POST /api/intervals/wellness": handleWellnessCheckIn,
+	}
+	for route, handler := range handlers {
+		method, path, _ := strings.Cut(route, " ")
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(method, path, strings.NewReader(`{"mood": 2}`)))
+		if rec.Code != http.StatusNotImplemented || !strings.Contains(rec.Body.String(), "strava") {
+			t.Errorf("%s: status %d: %s", route, rec.Code, rec.Body)
+		}
+	}
+
+	for name, refresh := range map[string]func() error{
+		"fitness":  func() error { return refreshFitness("2026-01-01", "2026-02-01") },
+		"calendar": func() error { return refreshCalendar("2026-02-01", "2026-02-07") },
+		"power":    refreshPowerCurves,
+	} {
+		if err := refresh(); !errors.Is(err, errNotSupported) {
+			t.Errorf("%s refresh: %v, want errNotSupported", name, err)
+		}
+	}
+	if intervalsCalls != 0 {
+		t.Errorf("intervals.icu called %d times", intervalsCalls)
+	}
+}
@@ -35,6 +35,7 @@ func handleTamagotchi(w http.ResponseWriter, r *http.Request) {
 		Image:          image,
 		ImgWidth:       width,
 		ImgHeight:      height,
+		Alerts:         defaultAlerts(config.Units),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -230,6 +231,7 @@ func sendGameResponse(w http.ResponseWriter, dog *Dog, message string) {
 		Image:          image,
 		ImgWidth:       width,
 		ImgHeight:      height,
+		Alerts:         defaultAlerts(config.Units),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Training status states, from the form (TSB = CTL - ATL) and the ramp rate
+const (
+	StatusTransition = "transition" // Rested for long, fitness is fading
+	StatusFresh      = "fresh"      // Ready to race
+	StatusGreyZone   = "grey_zone"  // Neither resting nor building fitness
+	StatusOptimal    = "optimal"    // Optimal training zone
+	StatusHighRisk   = "high_risk"  // Overreaching, by form or ramp rate
+)
+
+// Status colors (RGB565) so the firmware can tint the CTL/ATL row
+var statusColors = map[string]uint16{
+	StatusTransition: 0xFFE0, // Yellow
+	StatusFresh:      0x051F, // Blue
+	StatusGreyZone:   0x8410, // Grey
+	StatusOptimal:    0x07E0, // Green
+	StatusHighRisk:   0xF800, // Red
+}
+
+var statusLabels = map[string]string{
+	StatusTransition: "Transition",
+	StatusFresh:      "Fresh",
+	StatusGreyZone:   "Grey zone",
+	StatusOptimal:    "Optimal",
+	StatusHighRisk:   "High risk",
+}
+
+// TrainingThresholds are the TSB bounds of each state and the highest safe
+// ramp rate, configurable under "training"
+type TrainingThresholds struct {
+	TransitionTsb float64 `json:"transition_tsb"` // Above: transition
+	FreshTsb      float64 `json:"fresh_tsb"`      // Above: fresh
+	OptimalTsb    float64 `json:"optimal_tsb"`    // Below: optimal, above: grey zone
+	HighRiskTsb   float64 `json:"high_risk_tsb"`  // Below: high risk
+	MaxRampRate   float64 `json:"max_ramp_rate"`  // CTL gain per week above which it is high risk, 0 disables
+}
+
+// Validate checks that the TSB bounds are in decreasing order
+func (t TrainingThresholds) Validate() error {
+	if !(t.TransitionTsb > t.FreshTsb && t.FreshTsb > t.OptimalTsb && t.OptimalTsb > t.HighRiskTsb) {
+		return fmt.Errorf("training thresholds must decrease from transition_tsb to high_risk_tsb")
+	}
+	if t.MaxRampRate < 0 {
+		return fmt.Errorf("training max_ramp_rate must not be negative")
+	}
+	return nil
+}
+
+// TrainingStatus is the classified training state
+type TrainingStatus struct {
+	State    string  `json:"state"`
+	Label    string  `json:"label"` // Short, fits the CTL/ATL row
+	Color    uint16  `json:"color"` // RGB565 color of State
+	Tsb      float64 `json:"tsb"`
+	RampRate float64 `json:"ramp_rate"`
+}
+
+// ClassifyTraining returns the training state for CTL, ATL and ramp rate. A
+// ramp rate above the maximum is high risk whatever the form.
+func ClassifyTraining(t TrainingThresholds, ctl, atl, rampRate float64) *TrainingStatus {
+	status := &TrainingStatus{Tsb: round1(ctl - atl), RampRate: round1(rampRate)}
+
+	tsb := ctl - atl
+	switch {
+	case t.MaxRampRate > 0 && rampRate > t.MaxRampRate:
+		status.State = StatusHighRisk
+	case tsb > t.TransitionTsb:
+		status.State = StatusTransition
+	case tsb > t.FreshTsb:
+		status.State = StatusFresh
+	case tsb >= t.OptimalTsb:
+		status.State = StatusGreyZone
+	case tsb >= t.HighRiskTsb:
+		status.State = StatusOptimal
+	default:
+		status.State = StatusHighRisk
+	}
+
+	status.Label = statusLabels[status.State]
+	status.Color = statusColors[status.State]
+	return status
+}
+
+// Training providers
+const (
+	ProviderIntervals = "intervals"
+	ProviderStrava    = "strava"
+)
+
+// TrainingProvider fetches the training data of one athlete, converted from
+// its API to the provider-neutral TrainingDay the intervals payloads are
+// built from
+type TrainingProvider interface {
+	Name() string
+	// AthleteID identifies the stored days, empty while unknown
+	AthleteID() string
+	// GetDay returns the fitness of date and the activities up to it
+	GetDay(date string) (*TrainingDay, error)
+}
+
+// TrainingDay is the training data stored for one athlete and date: the
+// fitness of that date and the activities of the two weeks up to it, newest
+// first
+type TrainingDay struct {
+	AthleteID  string
+	Date       string // YYYY-MM-DD
+	Fitness    TrainingFitness
+	Activities []TrainingActivity
+	UpdatedAt  time.Time
+}
+
+// TrainingFitness is the fitness model of a day. The power model is zero
+// when the provider has none.
+type TrainingFitness struct {
+	Ctl      float64 `json:"ctl"`
+	Atl      float64 `json:"atl"`
+	RampRate float64 `json:"ramp_rate"`         // CTL gained over the last week
+	Eftp     float64 `json:"eftp,omitempty"`    // Estimated ride FTP, watts
+	WPrime   float64 `json:"w_prime,omitempty"` // Joules
+	PMax     float64 `json:"p_max,omitempty"`   // Watts
+}
+
+// TrainingActivity is an activity summary. Zones, the rolling power model,
+// the paired event and achievements are empty when the provider has none.
+type TrainingActivity struct {
+	ID               string  `json:"id"`
+	Name             string  `json:"name"`
+	Type             string  `json:"type"`             // e.g. Ride, VirtualRide, Run
+	StartDateLocal   string  `json:"start_date_local"` // 2006-01-02T15:04:05
+	Distance         float64 `json:"distance"`         // Meters
+	MovingTime       float64 `json:"moving_time"`      // Seconds
+	AverageWatts     float64 `json:"average_watts"`
+	WeightedAvgWatts float64 `json:"weighted_avg_watts"`
+	AverageHeartrate float64 `json:"average_heartrate"`
+	LRBalance        float64 `json:"lr_balance"` // Left leg share, percent
+	Calories         float64 `json:"calories"`
+	Load             float64 `json:"load"` // Training load, what CTL and ATL average
+
+	RollingFtp    float64 `json:"rolling_ftp,omitempty"` // Watts
+	RollingCp     float64 `json:"rolling_cp,omitempty"`  // Watts
+	RollingWPrime float64 `json:"rolling_w_prime,omitempty"`
+	RollingPMax   float64 `json:"rolling_p_max,omitempty"`
+
+	PairedEventID int64 `json:"paired_event_id,omitempty"` // Planned event it completes
+
+	PowerZoneSecs []float64 `json:"power_zone_secs,omitempty"` // Seconds in Z1, Z2...
+	HRZoneSecs    []float64 `json:"hr_zone_secs,omitempty"`
+	Polarization  float64   `json:"polarization,omitempty"`
+
+	Achievements []ActivityAchievement `json:"achievements,omitempty"`
+}
+
+// ActivityAchievement is a best or record set by an activity
+type ActivityAchievement struct {
+	ID      string  `json:"id"` // Within the activity, may be empty
+	Type    string  `json:"type"`
+	Message string  `json:"message"`
+	Watts   float64 `json:"watts,omitempty"`
+	Secs    float64 `json:"secs,omitempty"`
+	Value   float64 `json:"value,omitempty"`
+}
+
+// defaultTrainingProvider returns the shared client of the configured
+// training provider
+func defaultTrainingProvider() (TrainingProvider, error) {
+	switch config.TrainingProvider {
+	case "", ProviderIntervals:
+		return defaultIntervalsClient()
+	case ProviderStrava:
+		return defaultStravaClient()
+	default:
+		return nil, fmt.Errorf("unknown training provider %q", config.TrainingProvider)
+	}
+}
+
+// trainingAthleteID returns the athlete of the configured training provider.
+// It is empty without credentials, or while unknown, and then the stored data
+// of any athlete is served.
+func trainingAthleteID() string {
+	provider, err := defaultTrainingProvider()
+	if err != nil {
+		return ""
+	}
+	return provider.AthleteID()
+}
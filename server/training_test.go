@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+func TestClassifyTraining(t *testing.T) {
+	thresholds := DefaultConfig().Training
+
+	tests := []struct {
+		ctl, atl, ramp float64
+		want           string
+		label          string
+	}{
+		{ctl: 60, atl: 30, ramp: 0, want: StatusTransition, label: "Transition"},
+		{ctl: 60, atl: 50, ramp: 2, want: StatusFresh, label: "Fresh"},
+		{ctl: 60, atl: 55, ramp: 2, want: StatusGreyZone, label: "Grey zone"},
+		{ctl: 60, atl: 70, ramp: 5, want: StatusGreyZone, label: "Grey zone"},
+		{ctl: 60, atl: 75, ramp: 5, want: StatusOptimal, label: "Optimal"},
+		{ctl: 60, atl: 90, ramp: 5, want: StatusOptimal, label: "Optimal"},
+		{ctl: 60, atl: 95, ramp: 5, want: StatusHighRisk, label: "High risk"},
+		// A steep ramp is high risk even when fresh
+		{ctl: 60, atl: 50, ramp: 9.5, want: StatusHighRisk, label: "High risk"},
+	}
+
+	for _, tt := range tests {
+		got := ClassifyTraining(thresholds, tt.ctl, tt.atl, tt.ramp)
+		if got.State != tt.want || got.Label != tt.label || got.Color != statusColors[tt.want] {
+			t.Errorf("ClassifyTraining(%v, %v, %v) = %+v, want %s", tt.ctl, tt.atl, tt.ramp, got, tt.want)
+		}
+		if got.Tsb != tt.ctl-tt.atl {
+			t.Errorf("ClassifyTraining(%v, %v) tsb = %v", tt.ctl, tt.atl, got.Tsb)
+		}
+	}
+
+	thresholds.MaxRampRate = 0
+	if got := ClassifyTraining(thresholds, 60, 50, 20); got.State != StatusFresh {
+		t.Errorf("ramp rate check not disabled: %+v", got)
+	}
+}
+
+func TestTrainingThresholdsValidate(t *testing.T) {
+	thresholds := DefaultConfig().Training
+	if err := thresholds.Validate(); err != nil {
+		t.Errorf("defaults invalid: %v", err)
+	}
+
+	thresholds.OptimalTsb = 10
+	if err := thresholds.Validate(); err == nil {
+		t.Errorf("expected error for unordered thresholds")
+	}
+
+	thresholds = DefaultConfig().Training
+	thresholds.MaxRampRate = -1
+	if err := thresholds.Validate(); err == nil {
+		t.Errorf("expected error for negative ramp rate")
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+)
+
+// Unit systems
+const (
+	UnitsMetric   = "metric"
+	UnitsImperial = "imperial"
+)
+
+// Wind speed units
+const (
+	WindKmh = "kmh"
+	WindMs  = "ms"
+	WindMph = "mph"
+)
+
+var windLabels = map[string]string{
+	WindKmh: "km/h",
+	WindMs:  "m/s",
+	WindMph: "mph",
+}
+
+// Units is a unit preference. Providers and caches always work in metric
+// (°C, km/h, mm, m); values are converted when a response is written.
+type Units struct {
+	System string `json:"system"` // "metric" or "imperial"
+	Wind   string `json:"wind"`   // "kmh", "ms" or "mph"; empty follows the system
+}
+
+// UnitLabels are the labels of the units a response is in, so the device
+// doesn't hardcode them
+type UnitLabels struct {
+	Temp     string `json:"temp"`     // "C" or "F"
+	Wind     string `json:"wind"`     // "km/h", "m/s" or "mph"
+	Precip   string `json:"precip"`   // "mm" or "in"
+	Distance string `json:"distance"` // "km" or "mi"
+}
+
+// metricUnits is the unit preference the providers work in
+var metricUnits = Units{System: UnitsMetric, Wind: WindKmh}
+
+// Validate checks the unit preference and fills in the wind unit of the system
+func (u *Units) Validate() error {
+	switch u.System {
+	case UnitsMetric:
+		if u.Wind == "" {
+			u.Wind = WindKmh
+		}
+	case UnitsImperial:
+		if u.Wind == "" {
+			u.Wind = WindMph
+		}
+	default:
+		return fmt.Errorf("unknown unit system %q, want metric or imperial", u.System)
+	}
+	if _, ok := windLabels[u.Wind]; !ok {
+		return fmt.Errorf("unknown wind unit %q, want kmh, ms or mph", u.Wind)
+	}
+	return nil
+}
+
+// unitsFromRequest returns the configured units, overridden by ?units= and
+// ?wind=. A ?units= without ?wind= uses the wind unit of that system.
+func unitsFromRequest(r *http.Request) (Units, error) {
+	u := config.Units
+	query := r.URL.Query()
+
+	if system := query.Get("units"); system != "" {
+		u = Units{System: system}
+	}
+	if wind := query.Get("wind"); wind != "" {
+		u.Wind = wind
+	}
+
+	err := u.Validate()
+	return u, err
+}
+
+func (u Units) imperial() bool {
+	return u.System == UnitsImperial
+}
+
+// Labels returns the labels for the unit preference
+func (u Units) Labels() UnitLabels {
+	if u.imperial() {
+		return UnitLabels{Temp: "F", Wind: windLabels[u.Wind], Precip: "in", Distance: "mi"}
+	}
+	return UnitLabels{Temp: "C", Wind: windLabels[u.Wind], Precip: "mm", Distance: "km"}
+}
+
+// Temp converts a temperature from °C
+func (u Units) Temp(c float64) float64 {
+	if u.imperial() {
+		return round1(c*9/5 + 32)
+	}
+	return c
+}
+
+// TempDelta converts a temperature difference from °C
+func (u Units) TempDelta(c float64) float64 {
+	if u.imperial() {
+		return round1(c * 9 / 5)
+	}
+	return c
+}
+
+// Speed converts a speed from km/h
+func (u Units) Speed(kmh float64) float64 {
+	switch u.Wind {
+	case WindMs:
+		return round1(kmh / 3.6)
+	case WindMph:
+		return round1(kmh / 1.609344)
+	default:
+		return kmh
+	}
+}
+
+// Precip converts a precipitation amount from mm
+func (u Units) Precip(mm float64) float64 {
+	if u.imperial() {
+		return math.Round(mm/25.4*100) / 100
+	}
+	return mm
+}
+
+// Distance converts a distance from meters to km or miles
+func (u Units) Distance(m float64) float64 {
+	if u.imperial() {
+		return round1(m / 1609.344)
+	}
+	return round1(m / 1000)
+}
+
+// FormatTemp formats a °C temperature, e.g. "-3°C" or "27°F"
+func (u Units) FormatTemp(c float64) string {
+	return fmt.Sprintf("%.0f°%s", u.Temp(c), u.Labels().Temp)
+}
+
+// FormatSpeed formats a km/h speed, e.g. "12 km/h"
+func (u Units) FormatSpeed(kmh float64) string {
+	return fmt.Sprintf("%.0f %s", u.Speed(kmh), windLabels[u.Wind])
+}
+
+// FormatPrecip formats a mm precipitation amount, e.g. "8 mm" or "0.31 in"
+func (u Units) FormatPrecip(mm float64) string {
+	if u.imperial() {
+		return fmt.Sprintf("%.2f in", u.Precip(mm))
+	}
+	return fmt.Sprintf("%.0f mm", mm)
+}
+
+// formatHoursMinutes formats seconds as "1:05"
+func formatHoursMinutes(seconds float64) string {
+	minutes := int(seconds/60 + 0.5)
+	return fmt.Sprintf("%d:%02d", minutes/60, minutes%60)
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnitConversions(t *testing.T) {
+	imperial := Units{System: UnitsImperial, Wind: WindMph}
+	ms := Units{System: UnitsMetric, Wind: WindMs}
+
+	tests := []struct {
+		name      string
+		got, want float64
+	}{
+		{"metric temp", metricUnits.Temp(21.5), 21.5},
+		{"imperial temp", imperial.Temp(-5), 23},
+		{"imperial delta", imperial.TempDelta(2.5), 4.5},
+		{"kmh", metricUnits.Speed(36), 36},
+		{"m/s", ms.Speed(36), 10},
+		{"mph", imperial.Speed(100), 62.1},
+		{"inches", imperial.Precip(25.4), 1},
+		{"km", metricUnits.Distance(42195), 42.2},
+		{"miles", imperial.Distance(42195), 26.2},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	if got := imperial.FormatTemp(0); got != "32°F" {
+		t.Errorf("FormatTemp = %q", got)
+	}
+	if got := ms.FormatSpeed(54); got != "15 m/s" {
+		t.Errorf("FormatSpeed = %q", got)
+	}
+	if labels := imperial.Labels(); labels != (UnitLabels{Temp: "F", Wind: "mph", Precip: "in", Distance: "mi"}) {
+		t.Errorf("imperial labels = %+v", labels)
+	}
+}
+
+func TestUnitsFromRequest(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    Units
+		wantErr bool
+	}{
+		{"", metricUnits, false},
+		{"?units=imperial", Units{System: UnitsImperial, Wind: WindMph}, false},
+		{"?wind=ms", Units{System: UnitsMetric, Wind: WindMs}, false},
+		{"?units=imperial&wind=kmh", Units{System: UnitsImperial, Wind: WindKmh}, false},
+		{"?units=kelvin", Units{}, true},
+		{"?wind=knots", Units{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := unitsFromRequest(httptest.NewRequest("GET", "/"+tt.query, nil))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: err = %v", tt.query, err)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("%q = %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigUnits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"units": {"system": "imperial"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("DASHBOARD_CONFIG", path)
+	defer func() { config = DefaultConfig() }()
+
+	if err := LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if config.Units != (Units{System: UnitsImperial, Wind: WindMph}) {
+		t.Errorf("units = %+v, want imperial with mph", config.Units)
+	}
+}
+
+func TestHandleWeatherUnits(t *testing.T) {
+	useFakeWeather(t)
+
+	rec := httptest.NewRecorder()
+	handleWeather(rec, httptest.NewRequest("GET", "/api/weather?units=imperial&verbose=1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	var weather Weather
+	if err := json.Unmarshal(rec.Body.Bytes(), &weather); err != nil {
+		t.Fatal(err)
+	}
+	if weather.Temp != 65.3 || weather.WindSpeed != 7.5 || weather.Forecast3h.Temp != 68 {
+		t.Errorf("unexpected imperial weather: %+v", weather)
+	}
+	if weather.Units.Temp != "F" || weather.Units.Wind != "mph" {
+		t.Errorf("units = %+v", weather.Units)
+	}
+
+	// The cache keeps metric values
+	rec = httptest.NewRecorder()
+	handleWeather(rec, httptest.NewRequest("GET", "/api/weather?verbose=1", nil))
+	json.Unmarshal(rec.Body.Bytes(), &weather)
+	if weather.Temp != 18.5 || weather.Units.Temp != "C" {
+		t.Errorf("metric weather after imperial request: %+v", weather)
+	}
+
+	rec = httptest.NewRecorder()
+	handleWeather(rec, httptest.NewRequest("GET", "/api/weather?wind=knots", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("wind=knots status = %d, want 400", rec.Code)
+	}
+}
+
+func TestPrepareDisplayData(t *testing.T) {
+	useFakeWeather(t)
+
+	data := &DisplayData{Activities: []MinimumActivity{{Distance: 52300, MovingTime: 6330}}}
+	prepareDisplayData(data, Units{System: UnitsImperial, Wind: WindMph})
+
+	a := data.Activities[0]
+	// The firmware divides the raw fields itself, so they must stay untouched
+	if a.Distance != 52300 || a.MovingTime != 6330 {
+		t.Errorf("raw fields changed: %+v", a)
+	}
+	if a.DistanceDisplay != 32.5 || a.MovingTimeDisplay != "1:46" || data.Units.Distance != "mi" {
+		t.Errorf("display fields = %v %s %s", a.DistanceDisplay, a.MovingTimeDisplay, data.Units.Distance)
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// upstreamErrorKind classifies upstream failures, so retries, the circuit
+// breaker, logs and /health can tell a bad API key from a flaky network
+type upstreamErrorKind string
+
+const (
+	// ErrorAuth is a rejected API key (401/403), retrying won't help
+	ErrorAuth upstreamErrorKind = "auth"
+	// ErrorTransient is a network error, timeout, 429 or 5xx, worth retrying
+	ErrorTransient upstreamErrorKind = "transient"
+	// ErrorRejected is any other 4xx, e.g. a missing record
+	ErrorRejected upstreamErrorKind = "rejected"
+	// ErrorDecode is a response that could not be decoded
+	ErrorDecode upstreamErrorKind = "decode"
+)
+
+// upstreamError is a classified failure of one upstream call
+type upstreamError struct {
+	Kind       upstreamErrorKind
+	Status     int           // HTTP status, 0 when no response was received
+	RetryAfter time.Duration // From the Retry-After header of a 429/503
+	Err        error
+}
+
+func (e *upstreamError) Error() string {
+	if e.Status != 0 {
+		return fmt.Sprintf("%s error (status %d): %v", e.Kind, e.Status, e.Err)
+	}
+	return fmt.Sprintf("%s error: %v", e.Kind, e.Err)
+}
+
+func (e *upstreamError) Unwrap() error {
+	return e.Err
+}
+
+// errorKind returns the kind of an upstream error, transient when unclassified
+func errorKind(err error) upstreamErrorKind {
+	var ue *upstreamError
+	if errors.As(err, &ue) {
+		return ue.Kind
+	}
+	return ErrorTransient
+}
+
+// classifyResponse returns the error for a non-200 response, or nil
+func classifyResponse(resp *http.Response, body []byte) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	err := &upstreamError{
+		Kind:   ErrorRejected,
+		Status: resp.StatusCode,
+		Err:    fmt.Errorf("%s", body),
+	}
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		err.Kind = ErrorAuth
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		err.Kind = ErrorTransient
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			err.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		}
+	}
+	return err
+}
+
+// parseRetryAfter reads a Retry-After header, either delay seconds or an HTTP
+// date. Returns 0 when missing or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0)
+	}
+	return 0
+}
+
+// upstreamMaxRetryDelay caps the wait between retries, so a device poll is
+// not held for long. A longer Retry-After fails the call instead.
+const upstreamMaxRetryDelay = 10 * time.Second
+
+// retryPolicy retries transient failures with exponential backoff and jitter
+type retryPolicy struct {
+	Attempts  int           // Total attempts, 1 disables retries
+	BaseDelay time.Duration // Delay before the first retry, doubled for each next one
+	MaxDelay  time.Duration // Longest wait, a longer Retry-After gives up instead
+}
+
+// delay returns how long to wait before retrying after attempt (1-based)
+// failed with err, and false when it should not be retried
+func (p retryPolicy) delay(attempt int, err error) (time.Duration, bool) {
+	if attempt >= p.Attempts || errorKind(err) != ErrorTransient {
+		return 0, false
+	}
+
+	var ue *upstreamError
+	if errors.As(err, &ue) && ue.RetryAfter > 0 {
+		return ue.RetryAfter, ue.RetryAfter <= p.MaxDelay
+	}
+
+	// Equal jitter: between half and all of the exponential delay
+	d := min(p.BaseDelay<<(attempt-1), p.MaxDelay)
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1)), true
+}
+
+// Circuit breaker states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// circuitBreaker stops calling an upstream after consecutive transient
+// failures, so a dead API doesn't slow every device poll. After the cooldown
+// one trial call is let through; its outcome closes or reopens the circuit.
+type circuitBreaker struct {
+	name      string
+	threshold int // Consecutive failures that open the circuit, 0 disables it
+	cooldown  time.Duration
+
+	mu          sync.Mutex
+	failures    int
+	openUntil   time.Time
+	probing     bool
+	lastErr     error
+	lastFailure time.Time
+}
+
+// BreakerStatus is the state of an upstream reported by /health
+type BreakerStatus struct {
+	Name          string `json:"name"`
+	State         string `json:"state"`
+	Failures      int    `json:"failures"`
+	OpenUntil     string `json:"open_until,omitempty"`
+	LastErrorKind string `json:"last_error_kind,omitempty"`
+	LastError     string `json:"last_error,omitempty"`
+	LastFailure   string `json:"last_failure,omitempty"`
+}
+
+var (
+	breakersMu sync.Mutex
+	breakers   = make(map[string]*circuitBreaker)
+)
+
+// newCircuitBreaker creates the breaker of an upstream
+func newCircuitBreaker(name string, threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{name: name, threshold: threshold, cooldown: cooldown}
+}
+
+// registerBreaker reports the breaker of a shared client in /health. Only the
+// default clients register, so clients built elsewhere never hide them.
+func registerBreaker(b *circuitBreaker) {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+	breakers[b.name] = b
+}
+
+// allow returns an error while the circuit is open
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.openUntil.IsZero() {
+		return nil
+	}
+	if time.Now().Before(b.openUntil) || b.probing {
+		return fmt.Errorf("%s circuit open until %s: %w",
+			b.name, b.openUntil.Format("15:04:05"), b.lastErr)
+	}
+	b.probing = true
+	return nil
+}
+
+// record updates the circuit with the outcome of an allowed call
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if err == nil || errorKind(err) != ErrorTransient {
+		// The upstream answered, even if it refused the request
+		if err != nil {
+			b.lastErr = err
+			b.lastFailure = time.Now()
+		}
+		b.failures = 0
+		b.openUntil = time.Time{}
+		return
+	}
+
+	b.failures++
+	b.lastErr = err
+	b.lastFailure = time.Now()
+
+	var until time.Time
+	if b.threshold > 0 && b.failures >= b.threshold {
+		until = b.lastFailure.Add(b.cooldown)
+	}
+	// An explicit Retry-After holds off further calls even below the threshold
+	var ue *upstreamError
+	if errors.As(err, &ue) && ue.RetryAfter > 0 {
+		if retryAt := b.lastFailure.Add(ue.RetryAfter); retryAt.After(until) {
+			until = retryAt
+		}
+	}
+	if !until.IsZero() {
+		b.openUntil = until
+	}
+}
+
+// status returns the current state for /health
+func (b *circuitBreaker) status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := BreakerStatus{Name: b.name, State: CircuitClosed, Failures: b.failures}
+	if !b.openUntil.IsZero() {
+		s.State = CircuitOpen
+		s.OpenUntil = b.openUntil.Format(time.RFC3339)
+		if !time.Now().Before(b.openUntil) {
+			s.State = CircuitHalfOpen
+		}
+	}
+	if b.lastErr != nil {
+		s.LastErrorKind = string(errorKind(b.lastErr))
+		s.LastError = b.lastErr.Error()
+		s.LastFailure = b.lastFailure.Format(time.RFC3339)
+	}
+	return s
+}
+
+// upstreamStatus returns the state of every registered upstream, by name
+func upstreamStatus() []BreakerStatus {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+
+	statuses := make([]BreakerStatus, 0, len(breakers))
+	for _, b := range breakers {
+		statuses = append(statuses, b.status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "", want: 0},
+		{value: "3", want: 3 * time.Second},
+		{value: "-5", want: 0},
+		{value: "Fri, 16 Oct 2026 12:01:30 GMT", want: 90 * time.Second},
+		{value: "Fri, 16 Oct 2026 11:00:00 GMT", want: 0},
+		{value: "soon", want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyResponse(t *testing.T) {
+	tests := []struct {
+		status int
+		want   upstreamErrorKind
+	}{
+		{status: http.StatusUnauthorized, want: ErrorAuth},
+		{status: http.StatusForbidden, want: ErrorAuth},
+		{status: http.StatusNotFound, want: ErrorRejected},
+		{status: http.StatusTooManyRequests, want: ErrorTransient},
+		{status: http.StatusInternalServerError, want: ErrorTransient},
+		{status: http.StatusServiceUnavailable, want: ErrorTransient},
+	}
+
+	for _, tt := range tests {
+		resp := &http.Response{StatusCode: tt.status, Header: http.Header{"Retry-After": {"7"}}}
+		err := classifyResponse(resp, []byte("nope"))
+		if got := errorKind(err); got != tt.want {
+			t.Errorf("status %d: kind = %s, want %s", tt.status, got, tt.want)
+		}
+
+		var ue *upstreamError
+		errors.As(err, &ue)
+		wantRetryAfter := tt.status == http.StatusTooManyRequests || tt.status == http.StatusServiceUnavailable
+		if (ue.RetryAfter == 7*time.Second) != wantRetryAfter {
+			t.Errorf("status %d: retry after = %v", tt.status, ue.RetryAfter)
+		}
+	}
+
+	if err := classifyResponse(&http.Response{StatusCode: http.StatusOK}, nil); err != nil {
+		t.Errorf("200 classified as %v", err)
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := retryPolicy{Attempts: 4, BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+	transient := &upstreamError{Kind: ErrorTransient}
+
+	// Jittered between half and all of 100ms, 200ms, then capped at 300ms
+	for attempt, want := range []time.Duration{100, 200, 300} {
+		want *= time.Millisecond
+		for range 20 {
+			d, ok := p.delay(attempt+1, transient)
+			if !ok || d < want/2 || d > want {
+				t.Fatalf("attempt %d: delay = %v, %v, want in [%v, %v]", attempt+1, d, ok, want/2, want)
+			}
+		}
+	}
+
+	if _, ok := p.delay(4, transient); ok {
+		t.Errorf("retried after the last attempt")
+	}
+	if _, ok := p.delay(1, &upstreamError{Kind: ErrorAuth}); ok {
+		t.Errorf("retried an auth error")
+	}
+	if d, ok := p.delay(1, &upstreamError{Kind: ErrorTransient, RetryAfter: 250 * time.Millisecond}); !ok || d != 250*time.Millisecond {
+		t.Errorf("retry after delay = %v, %v", d, ok)
+	}
+	if _, ok := p.delay(1, &upstreamError{Kind: ErrorTransient, RetryAfter: time.Minute}); ok {
+		t.Errorf("waited for a Retry-After above the max delay")
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	b := newCircuitBreaker("test", 2, time.Hour)
+	transient := &upstreamError{Kind: ErrorTransient, Err: errors.New("down")}
+
+	b.record(transient)
+	if err := b.allow(); err != nil {
+		t.Fatalf("open after one failure: %v", err)
+	}
+	b.record(transient)
+	if err := b.allow(); err == nil {
+		t.Fatalf("closed after two failures")
+	}
+	if s := b.status(); s.State != CircuitOpen || s.Failures != 2 || s.LastErrorKind != "transient" {
+		t.Errorf("status = %+v", s)
+	}
+
+	// After the cooldown a single trial call is let through
+	b.openUntil = time.Now().Add(-time.Second)
+	if s := b.status(); s.State != CircuitHalfOpen {
+		t.Errorf("state after cooldown = %s, want half_open", s.State)
+	}
+	if err := b.allow(); err != nil {
+		t.Fatalf("trial call refused: %v", err)
+	}
+	if err := b.allow(); err == nil {
+		t.Errorf("second call allowed during the trial")
+	}
+	b.record(nil)
+	if s := b.status(); s.State != CircuitClosed || s.Failures != 0 {
+		t.Errorf("status after successful trial = %+v", s)
+	}
+
+	// Auth errors don't open the circuit, an explicit Retry-After does
+	b.record(&upstreamError{Kind: ErrorAuth})
+	if err := b.allow(); err != nil {
+		t.Errorf("auth error opened the circuit: %v", err)
+	}
+	b.record(&upstreamError{Kind: ErrorTransient, RetryAfter: time.Minute})
+	if err := b.allow(); err == nil {
+		t.Errorf("Retry-After did not hold off calls")
+	}
+
+	disabled := newCircuitBreaker("disabled", 0, time.Hour)
+	for range 5 {
+		disabled.record(transient)
+	}
+	if err := disabled.allow(); err != nil {
+		t.Errorf("disabled breaker opened: %v", err)
+	}
+}
+
+func TestRegisterBreaker(t *testing.T) {
+	registered := func(b *circuitBreaker) bool {
+		breakersMu.Lock()
+		defer breakersMu.Unlock()
+		return breakers[b.name] == b
+	}
+
+	// Creating a client with the name of a shared one leaves /health alone
+	b := newCircuitBreaker("test-registry", 2, time.Hour)
+	if registered(b) {
+		t.Fatal("breaker registered on creation")
+	}
+
+	registerBreaker(b)
+	t.Cleanup(func() {
+		breakersMu.Lock()
+		delete(breakers, b.name)
+		breakersMu.Unlock()
+	})
+	if !registered(b) {
+		t.Error("breaker not registered")
+	}
+	newCircuitBreaker("test-registry", 2, time.Hour)
+	if !registered(b) {
+		t.Error("registered breaker replaced by a new one of the same name")
+	}
+}
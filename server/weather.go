@@ -2,133 +2,217 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"time"
 )
 
-// Weather represents the response for the weather endpoint
+// Weather represents the verbose response for the weather endpoint, see
+// WeatherSummary for the default one
 type Weather struct {
-	Temp        float64  `json:"temp"`
-	Condition   string   `json:"condition"`
-	Humidity    int      `json:"humidity"`
-	WindSpeed   float64  `json:"wind_speed"`
-	Code        int      `json:"code"` // WMO weather code
-	Forecast3h  Forecast `json:"forecast_3h"`
-	ForecastTom Forecast `json:"forecast_tom"`
+	Location       string   `json:"location"` // Display name of the location
+	Temp           float64  `json:"temp"`
+	Condition      string   `json:"condition"`       // Category label, as shown by the device
+	ConditionShort string   `json:"condition_short"` // Detailed label, fits the device screen
+	ConditionLong  string   `json:"condition_long"`  // Long label for wider screens
+	Humidity       int      `json:"humidity"`
+	WindSpeed      float64  `json:"wind_speed"`
+	Code           int      `json:"code"`     // WMO weather code
+	Category       string   `json:"category"` // Condition category, see wmo.go
+	Intensity      string   `json:"intensity,omitempty"`
+	Freezing       bool     `json:"freezing,omitempty"`
+	IsDay          bool     `json:"is_day"`
+	Icon           string   `json:"icon"` // Icon kind for /api/weather/icon
+	Forecast3h     Forecast `json:"forecast_3h"`
+	ForecastTom    Forecast `json:"forecast_tom"` // Tomorrow at noon
+
+	// Forecasts holds one entry per configured forecast slot
+	Forecasts []Forecast `json:"forecasts,omitempty"`
+
+	// Alerts are the active weather alerts for the location
+	Alerts []Alert `json:"alerts,omitempty"`
+
+	// Units labels the temperature and wind speed units
+	Units UnitLabels `json:"units"`
 }
 
 type Forecast struct {
+	Slot           string  `json:"slot,omitempty"` // Slot spec, e.g. "+3h" or "tomorrow-noon"
+	Time           string  `json:"time,omitempty"` // Local time of the slot, "15:04"
+	Temp           float64 `json:"temp"`
+	Condition      string  `json:"condition"`       // Category label
+	ConditionShort string  `json:"condition_short"` // Detailed label
+	Code           int     `json:"code"`
+	Category       string  `json:"category"`
+	Icon           string  `json:"icon,omitempty"`
+	WindSpeed      float64 `json:"wind_speed"`
+	Precip         float64 `json:"precip"`
+}
+
+// WeatherSummary is the default response for the weather endpoint, limited
+// to the fields the device firmware reads so it fits its 1KB JSON document
+type WeatherSummary struct {
+	Location    string          `json:"location"`
+	Temp        float64         `json:"temp"`
+	Condition   string          `json:"condition"`
+	Humidity    int             `json:"humidity"`
+	WindSpeed   float64         `json:"wind_speed"`
+	Code        int             `json:"code"`
+	Forecast3h  ForecastSummary `json:"forecast_3h"`
+	ForecastTom ForecastSummary `json:"forecast_tom"`
+}
+
+// ForecastSummary is a forecast of the default weather response
+type ForecastSummary struct {
 	Temp      float64 `json:"temp"`
 	Condition string  `json:"condition"`
 	WindSpeed float64 `json:"wind_speed"`
 	Precip    float64 `json:"precip"`
 }
 
-// OpenMeteoResponse structure for parsing API response
-type OpenMeteoResponse struct {
-	CurrentWeather struct {
-		Temperature float64 `json:"temperature"`
-		Windspeed   float64 `json:"windspeed"`
-		Weathercode int     `json:"weathercode"`
-		Time        string  `json:"time"`
-		IsDay       int     `json:"is_day"`
-	} `json:"current_weather"`
-	Hourly struct {
-		Time                     []string  `json:"time"`
-		Temperature2m            []float64 `json:"temperature_2m"`
-		Relativehumidity2m       []int     `json:"relativehumidity_2m"`
-		PrecipitationProbability []int     `json:"precipitation_probability"`
-		Weathercode              []int     `json:"weathercode"`
-		Windspeed10m             []float64 `json:"windspeed_10m"`
-	} `json:"hourly"`
+// setCondition fills the condition fields and icon from a WMO code
+func (w *Weather) setCondition(code int, isDay bool) {
+	c := describeWeatherCode(code, isDay)
+	w.Code = c.Code
+	w.IsDay = isDay
+	w.Condition = c.Label
+	w.ConditionShort = c.Short
+	w.ConditionLong = c.Long
+	w.Category = c.Category
+	w.Intensity = c.Intensity
+	w.Freezing = c.Freezing
+	w.Icon = weatherIconKind(code, isDay)
 }
 
-// decodeWeatherCode converts WMO code to string condition
-func decodeWeatherCode(code int) string {
-	switch code {
-	case 0:
-		return "Clear"
-	case 1, 2, 3:
-		return "Cloudy"
-	case 45, 48:
-		return "Fog"
-	case 51, 53, 55:
-		return "Drizzle"
-	case 61, 63, 65:
-		return "Rain"
-	case 71, 73, 75:
-		return "Snow"
-	case 80, 81, 82:
-		return "Showers"
-	case 95, 96, 99:
-		return "Storm"
-	default:
-		return "Unknown"
+// setCondition fills the condition fields and icon from a WMO code
+func (f *Forecast) setCondition(code int, isDay bool) {
+	c := describeWeatherCode(code, isDay)
+	f.Code = c.Code
+	f.Condition = c.Label
+	f.ConditionShort = c.Short
+	f.Category = c.Category
+	f.Icon = weatherIconKind(code, isDay)
+}
+
+// summary returns the default response for the weather
+func (w *Weather) summary() WeatherSummary {
+	return WeatherSummary{
+		Location:    w.Location,
+		Temp:        w.Temp,
+		Condition:   w.Condition,
+		Humidity:    w.Humidity,
+		WindSpeed:   w.WindSpeed,
+		Code:        w.Code,
+		Forecast3h:  w.Forecast3h.summary(),
+		ForecastTom: w.ForecastTom.summary(),
 	}
 }
 
-// handleWeather returns real weather data for Aix-les-Bains from Open-Meteo
-func handleWeather(w http.ResponseWriter, r *http.Request) {
-	// Aix-les-Bains coordinates: 45.6885, 5.9153
-	url := "https://api.open-meteo.com/v1/forecast?latitude=45.6885&longitude=5.9153&current_weather=true&hourly=temperature_2m,relativehumidity_2m,precipitation_probability,weathercode,windspeed_10m&timezone=Europe%2FParis&temperature_unit=celsius&windspeed_unit=kmh&precipitation_unit=mm"
+// summary returns the forecast of the default weather response
+func (f Forecast) summary() ForecastSummary {
+	return ForecastSummary{Temp: f.Temp, Condition: f.Condition, WindSpeed: f.WindSpeed, Precip: f.Precip}
+}
 
-	resp, err := http.Get(url)
-	if err != nil {
-		log.Printf("Error fetching weather: %v", err)
-		http.Error(w, "Failed to fetch weather", http.StatusInternalServerError)
-		return
+// convert converts the metric values to u and sets the unit labels
+func (w *Weather) convert(u Units) {
+	w.Temp = u.Temp(w.Temp)
+	w.WindSpeed = u.Speed(w.WindSpeed)
+	w.Forecast3h.convert(u)
+	w.ForecastTom.convert(u)
+
+	// The slice may be shared with the provider, convert a copy
+	forecasts := make([]Forecast, len(w.Forecasts))
+	for i, f := range w.Forecasts {
+		f.convert(u)
+		forecasts[i] = f
+	}
+	if w.Forecasts != nil {
+		w.Forecasts = forecasts
 	}
-	defer resp.Body.Close()
 
-	var om OpenMeteoResponse
-	if err := json.NewDecoder(resp.Body).Decode(&om); err != nil {
-		log.Printf("Error decoding weather: %v", err)
-		http.Error(w, "Failed to decode weather", http.StatusInternalServerError)
-		return
+	w.Units = u.Labels()
+}
+
+// convert converts the metric values to u
+func (f *Forecast) convert(u Units) {
+	f.Temp = u.Temp(f.Temp)
+	f.WindSpeed = u.Speed(f.WindSpeed)
+}
+
+// WeatherProvider fetches current conditions and short forecasts for a location.
+// Implementations always return metric values (°C, km/h, mm).
+type WeatherProvider interface {
+	Name() string
+	GetWeather(loc Location) (*Weather, error)
+}
+
+// Weather provider names accepted in the configuration
+const (
+	ProviderOpenMeteo = "open-meteo"
+	ProviderMetNo     = "met-no"
+	ProviderFake      = "fake"
+)
+
+// weatherProvider is the provider used by the weather handlers
+var weatherProvider WeatherProvider = newOpenMeteoProvider()
+
+// newWeatherProvider returns the provider registered under name
+func newWeatherProvider(name string) (WeatherProvider, error) {
+	switch name {
+	case "", ProviderOpenMeteo:
+		return newOpenMeteoProvider(), nil
+	case ProviderMetNo:
+		return newMetNoProvider(), nil
+	case ProviderFake:
+		return newFakeWeatherProvider(), nil
+	default:
+		return nil, fmt.Errorf("unknown weather provider %q", name)
+	}
+}
+
+// currentWeatherSource returns the cache key and upstream call for the
+// current weather of loc
+func currentWeatherSource(loc Location) (string, func() (*Weather, error)) {
+	return "current:" + loc.Key, func() (*Weather, error) {
+		return weatherProvider.GetWeather(loc)
 	}
+}
 
-	// Get current hour index
-	now := time.Now()
-	currentHour := now.Hour()
-
-	// Prepare response
-	weather := Weather{
-		Temp:      om.CurrentWeather.Temperature,
-		Condition: decodeWeatherCode(om.CurrentWeather.Weathercode),
-		Humidity:  0, // Will be filled from hourly
-		WindSpeed: om.CurrentWeather.Windspeed,
-		Code:      om.CurrentWeather.Weathercode,
+// handleWeather returns weather data for the location selected with ?loc=,
+// or the configured default location, in the units selected with ?units=
+// and ?wind=. The device gets a WeatherSummary, the full Weather with its
+// alerts is served with ?verbose=1.
+func handleWeather(w http.ResponseWriter, r *http.Request) {
+	loc, err := config.GetLocation(r.URL.Query().Get("loc"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	// Find humidity for current hour
-	if len(om.Hourly.Relativehumidity2m) > currentHour {
-		weather.Humidity = om.Hourly.Relativehumidity2m[currentHour]
+	units, err := unitsFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	// Forecast +3h
-	idx3h := currentHour + 3
-	if len(om.Hourly.Temperature2m) > idx3h {
-		weather.Forecast3h = Forecast{
-			Temp:      om.Hourly.Temperature2m[idx3h],
-			Condition: decodeWeatherCode(om.Hourly.Weathercode[idx3h]),
-			WindSpeed: om.Hourly.Windspeed10m[idx3h],
-			Precip:    float64(om.Hourly.PrecipitationProbability[idx3h]),
-		}
+	weather, info, err := fetchWeatherCached(currentWeatherSource(loc))
+	if err != nil {
+		log.Printf("Error fetching weather from %s: %v", weatherProvider.Name(), err)
+		http.Error(w, "Failed to fetch weather", http.StatusInternalServerError)
+		return
 	}
 
-	// Forecast Tomorrow (noon)
-	idxTom := currentHour + 24 // Same time tomorrow
-	if len(om.Hourly.Temperature2m) > idxTom {
-		weather.ForecastTom = Forecast{
-			Temp:      om.Hourly.Temperature2m[idxTom],
-			Condition: decodeWeatherCode(om.Hourly.Weathercode[idxTom]),
-			WindSpeed: om.Hourly.Windspeed10m[idxTom],
-			Precip:    float64(om.Hourly.PrecipitationProbability[idxTom]),
-		}
+	weather.convert(units)
+	var response any = weather.summary()
+	if r.URL.Query().Get("verbose") == "1" {
+		weather.Alerts = alertsFor(loc, units)
+		response = weather
 	}
 
-	json.NewEncoder(w).Encode(weather)
-	log.Printf("[%s] GET /api/weather -> %.1f°C, %s (Wind: %.1f km/h) for Aix-les-Bains",
-		time.Now().Format("15:04:05"), weather.Temp, weather.Condition, weather.WindSpeed)
+	writeCacheHeaders(w, info)
+	json.NewEncoder(w).Encode(response)
+	log.Printf("[%s] GET /api/weather -> %.1f%s, %s (Wind: %.1f %s) for %s",
+		time.Now().Format("15:04:05"), weather.Temp, weather.Units.Temp, weather.Condition,
+		weather.WindSpeed, weather.Units.Wind, loc.Name)
 }
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// fakeWeatherProvider is an in-process provider returning canned data.
+// It is used by tests and for offline development ("provider": "fake").
+type fakeWeatherProvider struct {
+	Weather Weather
+	Hourly  []HourlyWeather // Served by GetHourly; a mild day is generated when nil
+	Err     error           // Returned instead of data when set
+
+	// Number of calls per method, for tests. Atomic, the provider is
+	// called from several goroutines.
+	Calls       atomic.Int64
+	DailyCalls  atomic.Int64
+	HourlyCalls atomic.Int64
+	SunCalls    atomic.Int64
+}
+
+func newFakeWeatherProvider() *fakeWeatherProvider {
+	weather := Weather{
+		Temp:      18.5,
+		Humidity:  65,
+		WindSpeed: 12.0,
+		Forecast3h: Forecast{
+			Temp:      20.0,
+			WindSpeed: 15.0,
+			Precip:    40,
+		},
+		ForecastTom: Forecast{
+			Temp:      16.0,
+			WindSpeed: 8.0,
+			Precip:    5,
+		},
+	}
+	weather.setCondition(2, true)
+	weather.Forecast3h.setCondition(61, true)
+	weather.ForecastTom.setCondition(0, true)
+
+	return &fakeWeatherProvider{Weather: weather}
+}
+
+func (p *fakeWeatherProvider) Name() string {
+	return ProviderFake
+}
+
+func (p *fakeWeatherProvider) GetWeather(loc Location) (*Weather, error) {
+	p.Calls.Add(1)
+	if p.Err != nil {
+		return nil, p.Err
+	}
+
+	weather := p.Weather
+	weather.Location = loc.Name
+	return &weather, nil
+}
+
+func (p *fakeWeatherProvider) GetDaily(loc Location, days int) ([]DayForecast, error) {
+	p.DailyCalls.Add(1)
+	if p.Err != nil {
+		return nil, p.Err
+	}
+
+	codes := []int{0, 2, 61, 95, 3, 71, 1}
+	today := time.Now()
+	forecast := make([]DayForecast, 0, days)
+	for i := 0; i < days; i++ {
+		date := today.AddDate(0, 0, i)
+		code := codes[i%len(codes)]
+		forecast = append(forecast, DayForecast{
+			Day:        date.Format("Mon"),
+			Date:       date.Format("2006-01-02"),
+			TempMax:    20 + float64(i),
+			TempMin:    10 + float64(i),
+			Code:       code,
+			Condition:  describeWeatherCode(code, true).Short,
+			PrecipSum:  float64(i) * 1.5,
+			PrecipProb: i * 10,
+			WindMax:    15 + float64(i)*2,
+			Sunrise:    "07:30",
+			Sunset:     "19:00",
+		})
+	}
+	return forecast, nil
+}
+
+func (p *fakeWeatherProvider) GetHourly(loc Location) ([]HourlyWeather, error) {
+	p.HourlyCalls.Add(1)
+	if p.Err != nil {
+		return nil, p.Err
+	}
+	if p.Hourly != nil {
+		return p.Hourly, nil
+	}
+
+	start := time.Now().Truncate(time.Hour)
+	hours := make([]HourlyWeather, 0, 48)
+	for i := 0; i < 48; i++ {
+		t := start.Add(time.Duration(i) * time.Hour)
+		hours = append(hours, HourlyWeather{
+			Time:       t,
+			Temp:       12 + float64(t.Hour())/2,
+			Humidity:   60,
+			WindSpeed:  10,
+			PrecipProb: 10,
+			Code:       2,
+			IsDay:      t.Hour() >= 7 && t.Hour() < 19,
+		})
+	}
+	return hours, nil
+}
+
+func (p *fakeWeatherProvider) GetSun(loc Location, days int) ([]SunDay, error) {
+	p.SunCalls.Add(1)
+	if p.Err != nil {
+		return nil, p.Err
+	}
+
+	zone, err := time.LoadLocation(loc.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	today := time.Now().In(zone)
+	sun := make([]SunDay, 0, days)
+	for i := 0; i < days; i++ {
+		sun = append(sun, SunDay{
+			Date:     today.AddDate(0, 0, i).Format("2006-01-02"),
+			Sunrise:  fmt.Sprintf("07:%02d", 30+i),
+			Sunset:   fmt.Sprintf("19:%02d", 10-i),
+			Daylight: 700 - i*2,
+			UVMax:    4.5,
+		})
+	}
+	return sun, nil
+}
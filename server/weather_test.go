@@ -0,0 +1,302 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// useFakeWeather swaps the global weather provider for a fake for the duration of a test
+func useFakeWeather(t *testing.T) *fakeWeatherProvider {
+	t.Helper()
+	setupTestDB(t)
+
+	fake := newFakeWeatherProvider()
+	previous := weatherProvider
+	weatherProvider = fake
+	t.Cleanup(func() { weatherProvider = previous })
+
+	return fake
+}
+
+func TestHandleWeather(t *testing.T) {
+	useFakeWeather(t)
+
+	rec := httptest.NewRecorder()
+	handleWeather(rec, httptest.NewRequest("GET", "/api/weather", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	var weather Weather
+	if err := json.NewDecoder(rec.Body).Decode(&weather); err != nil {
+		t.Fatal(err)
+	}
+	if weather.Location != "Aix-les-Bains" {
+		t.Errorf("location = %q, want default location", weather.Location)
+	}
+	if weather.Temp != 18.5 || weather.Forecast3h.Precip != 40 {
+		t.Errorf("unexpected weather: %+v", weather)
+	}
+}
+
+// weatherPayloadBudget caps the default weather payload. The firmware parses
+// it into a StaticJsonDocument<1024>, which also holds a copy of every key and
+// string, and shows nothing when it does not fit.
+const weatherPayloadBudget = 320
+
+func TestHandleWeatherPayloadBudget(t *testing.T) {
+	fake := useFakeWeather(t)
+	// Long values everywhere
+	fake.Weather.Temp = -12.34
+	fake.Weather.WindSpeed = 123.45
+	fake.Weather.Humidity = 100
+	fake.Weather.setCondition(99, false)
+	fake.Weather.Forecast3h = Forecast{Temp: -10.55, WindSpeed: 99.99, Precip: 100}
+	fake.Weather.Forecast3h.setCondition(82, true)
+	fake.Weather.ForecastTom = Forecast{Temp: -11.45, WindSpeed: 88.88, Precip: 100}
+	fake.Weather.ForecastTom.setCondition(56, true)
+	fake.Weather.Forecasts = []Forecast{fake.Weather.Forecast3h, fake.Weather.ForecastTom}
+
+	rec := httptest.NewRecorder()
+	handleWeather(rec, httptest.NewRequest("GET", "/api/weather?units=imperial", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if rec.Body.Len() > weatherPayloadBudget {
+		t.Errorf("default payload is %d bytes, budget %d: %s", rec.Body.Len(), weatherPayloadBudget, rec.Body)
+	}
+
+	// Only the fields the firmware reads, with its condition labels
+	var payload map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if len(payload) != 8 || payload["condition"] != "Storm" || payload["forecast_3h"].(map[string]any)["condition"] != "Showers" {
+		t.Errorf("default payload = %s", rec.Body)
+	}
+
+	// The full weather is still served on request
+	rec = httptest.NewRecorder()
+	handleWeather(rec, httptest.NewRequest("GET", "/api/weather?verbose=1", nil))
+	var weather Weather
+	if err := json.Unmarshal(rec.Body.Bytes(), &weather); err != nil {
+		t.Fatal(err)
+	}
+	if weather.ConditionShort != "Storm+hail" || len(weather.Forecasts) != 2 || weather.Units.Temp != "C" {
+		t.Errorf("verbose weather = %+v", weather)
+	}
+}
+
+func TestHandleWeatherUnknownLocation(t *testing.T) {
+	useFakeWeather(t)
+
+	rec := httptest.NewRecorder()
+	handleWeather(rec, httptest.NewRequest("GET", "/api/weather?loc=nowhere", nil))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandleWeatherProviderError(t *testing.T) {
+	fake := useFakeWeather(t)
+	fake.Err = errors.New("upstream down")
+
+	rec := httptest.NewRecorder()
+	handleWeather(rec, httptest.NewRequest("GET", "/api/weather", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+}
+
+func TestHandleWeatherCache(t *testing.T) {
+	fake := useFakeWeather(t)
+
+	get := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handleWeather(rec, httptest.NewRequest("GET", "/api/weather", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+		}
+		return rec
+	}
+
+	if rec := get(); rec.Header().Get("X-Cache-Fresh") != "true" {
+		t.Errorf("first poll should be fresh, headers = %v", rec.Header())
+	}
+	if rec := get(); rec.Header().Get("X-Cache-Age") == "" {
+		t.Errorf("second poll should be cached, headers = %v", rec.Header())
+	}
+	if fake.Calls.Load() != 1 {
+		t.Errorf("upstream calls = %d, want 1", fake.Calls.Load())
+	}
+
+	// Expire the cache and break the upstream: stale data is served instead
+	config.Weather.CacheTTL = Duration{}
+	config.Weather.MinFetchInterval = Duration{}
+	t.Cleanup(func() { config = DefaultConfig() })
+	fake.Err = errors.New("upstream down")
+
+	if rec := get(); rec.Header().Get("X-Cache-Stale") != "true" {
+		t.Errorf("expected stale cache, headers = %v", rec.Header())
+	}
+	if fake.Calls.Load() != 2 {
+		t.Errorf("upstream calls = %d, want 2", fake.Calls.Load())
+	}
+
+	// Within the minimum fetch interval upstream is not called again
+	config.Weather.MinFetchInterval = Duration{time.Hour}
+	for range 5 {
+		get()
+	}
+	if fake.Calls.Load() != 2 {
+		t.Errorf("upstream calls = %d, want 2", fake.Calls.Load())
+	}
+}
+
+func TestMetNoProvider(t *testing.T) {
+	now := time.Date(2026, 6, 1, 8, 0, 0, 0, time.UTC)
+	step := func(offset int, temp float64, symbol string, prob float64) map[string]any {
+		return map[string]any{
+			"time": now.Add(time.Duration(offset) * time.Hour).Format(time.RFC3339),
+			"data": map[string]any{
+				"instant": map[string]any{"details": map[string]any{
+					"air_temperature": temp, "relative_humidity": 71.6, "wind_speed": 5.0,
+				}},
+				"next_1_hours": map[string]any{
+					"summary": map[string]any{"symbol_code": symbol},
+					"details": map[string]any{"probability_of_precipitation": prob},
+				},
+			},
+		}
+	}
+
+	var series []map[string]any
+	for h := 0; h <= 30; h++ {
+		series = append(series, step(h, float64(h), "cloudy", 0))
+	}
+	series[3] = step(3, 3, "heavyrainshowers_day", 80)
+	series[28] = step(28, 28, "lightsnowandthunder", 60) // Tomorrow at noon
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") == "" {
+			http.Error(w, "missing user agent", http.StatusForbidden)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"properties": map[string]any{"timeseries": series},
+		})
+	}))
+	defer server.Close()
+
+	provider := newMetNoProvider()
+	provider.baseURL = server.URL
+
+	mn, err := provider.fetch(Location{Name: "Oslo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slots, _ := parseForecastSlots([]string{"+1h", "+3h"})
+	weather, err := mn.toWeather(now, time.UTC, slots)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if weather.Code != 3 || weather.Humidity != 72 {
+		t.Errorf("unexpected current weather: %+v", weather)
+	}
+	if weather.WindSpeed != 18 {
+		t.Errorf("wind speed = %v km/h, want 18", weather.WindSpeed)
+	}
+	if weather.Forecast3h.Category != CategoryShowers || weather.Forecast3h.Precip != 80 {
+		t.Errorf("unexpected +3h forecast: %+v", weather.Forecast3h)
+	}
+	if weather.ForecastTom.Category != CategoryStorm || weather.ForecastTom.Time != "12:00" {
+		t.Errorf("unexpected tomorrow forecast: %+v", weather.ForecastTom)
+	}
+	if len(weather.Forecasts) != 2 || weather.Forecasts[0].Temp != 1 {
+		t.Errorf("unexpected forecast slots: %+v", weather.Forecasts)
+	}
+}
+
+func TestHandleWeatherDaily(t *testing.T) {
+	fake := useFakeWeather(t)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handleWeatherDaily(rec, httptest.NewRequest("GET", "/api/weather/daily"+query, nil))
+		return rec
+	}
+
+	rec := get("?days=3")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	var daily DailyForecast
+	if err := json.NewDecoder(rec.Body).Decode(&daily); err != nil {
+		t.Fatal(err)
+	}
+	if daily.Location != "Aix-les-Bains" || len(daily.Days) != 3 {
+		t.Errorf("unexpected daily forecast: %+v", daily)
+	}
+
+	// A full week must stay within the device's 1KB JSON budget
+	rec = get("?days=7")
+	if rec.Body.Len() > 1024 {
+		t.Errorf("7 day payload is %d bytes", rec.Body.Len())
+	}
+	if fake.DailyCalls.Load() != 1 {
+		t.Errorf("upstream calls = %d, want 1 shared by all ?days= values", fake.DailyCalls.Load())
+	}
+
+	if rec := get("?days=10"); rec.Code != http.StatusBadRequest {
+		t.Errorf("days=10 status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestOpenMeteoDaily(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("forecast_days") != "2" {
+			t.Errorf("forecast_days = %q", r.URL.Query().Get("forecast_days"))
+		}
+		w.Write([]byte(`{"daily": {
+			"time": ["2026-10-16", "2026-10-17"],
+			"weathercode": [61, 3],
+			"temperature_2m_max": [17.2, 15.0],
+			"temperature_2m_min": [8.1, 6.3],
+			"precipitation_sum": [4.2, 0],
+			"precipitation_probability_max": [80, 10],
+			"windspeed_10m_max": [21.6, 12.2],
+			"sunrise": ["2026-10-16T08:05", "2026-10-17T08:06"],
+			"sunset": ["2026-10-16T18:50"]
+		}}`))
+	}))
+	defer server.Close()
+
+	provider := newOpenMeteoProvider()
+	provider.baseURL = server.URL
+
+	days, err := provider.GetDaily(Location{Timezone: "Europe/Paris"}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The sunset array is short, so only the first day is complete
+	if len(days) != 1 {
+		t.Fatalf("got %d days, want 1", len(days))
+	}
+	want := DayForecast{
+		Day: "Fri", Date: "2026-10-16", TempMax: 17.2, TempMin: 8.1, Code: 61, Condition: "Lt rain",
+		PrecipSum: 4.2, PrecipProb: 80, WindMax: 21.6, Sunrise: "08:05", Sunset: "18:50",
+	}
+	if days[0] != want {
+		t.Errorf("day = %+v, want %+v", days[0], want)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheInfo describes where a cached response came from, for the X-Cache-* headers
+type cacheInfo struct {
+	Age   time.Duration
+	Fresh bool // Fetched from upstream by this request
+	Stale bool // Older than the TTL, upstream could not be used
+}
+
+// upstreamLimiter serialises upstream calls per cache key and remembers the
+// last attempt, so concurrent polls share one fetch and a failing upstream is
+// not hammered by every device
+type upstreamLimiter struct {
+	mu       sync.Mutex
+	locks    map[string]*sync.Mutex
+	attempts map[string]time.Time
+	errors   map[string]error
+}
+
+var weatherLimiter = newUpstreamLimiter()
+
+func newUpstreamLimiter() *upstreamLimiter {
+	return &upstreamLimiter{
+		locks:    make(map[string]*sync.Mutex),
+		attempts: make(map[string]time.Time),
+		errors:   make(map[string]error),
+	}
+}
+
+// lock acquires the per-key lock and returns its unlock function
+func (l *upstreamLimiter) lock(key string) func() {
+	l.mu.Lock()
+	keyLock, ok := l.locks[key]
+	if !ok {
+		keyLock = &sync.Mutex{}
+		l.locks[key] = keyLock
+	}
+	l.mu.Unlock()
+
+	keyLock.Lock()
+	return keyLock.Unlock
+}
+
+// lastAttempt returns when upstream was last called for key and the error it
+// returned, if any. Must be called with the key lock held.
+func (l *upstreamLimiter) lastAttempt(key string) (time.Time, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.attempts[key], l.errors[key]
+}
+
+// recordAttempt stores the outcome of an upstream call. Must be called with the key lock held.
+func (l *upstreamLimiter) recordAttempt(key string, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.attempts[key] = time.Now()
+	l.errors[key] = err
+}
+
+// fetchWeatherCached returns the payload cached under key while it is younger
+// than the configured TTL, and otherwise calls fetch. When fetch fails, or was
+// already attempted within the minimum fetch interval, stale cached data is
+// returned instead.
+func fetchWeatherCached[T any](key string, fetch func() (*T, error)) (*T, cacheInfo, error) {
+	unlock := weatherLimiter.lock(key)
+	defer unlock()
+
+	var cached *T
+	var age time.Duration
+	var entry T
+	if updatedAt, err := GetCachedWeather(key, &entry); err == nil {
+		cached = &entry
+		age = time.Since(updatedAt)
+		if age < config.Weather.CacheTTL.Duration {
+			return cached, cacheInfo{Age: age}, nil
+		}
+		// The scheduler is refreshing it, answer now rather than wait for upstream
+		if refresher.covers(weatherRefreshJob(key), age) {
+			return cached, cacheInfo{Age: age, Stale: true}, nil
+		}
+	}
+
+	lastAttempt, lastErr := weatherLimiter.lastAttempt(key)
+	if time.Since(lastAttempt) < config.Weather.MinFetchInterval.Duration {
+		if cached != nil {
+			return cached, cacheInfo{Age: age, Stale: true}, nil
+		}
+		if lastErr != nil {
+			return nil, cacheInfo{}, fmt.Errorf("upstream recently failed: %w", lastErr)
+		}
+	}
+
+	fresh, err := fetch()
+	weatherLimiter.recordAttempt(key, err)
+	if err != nil {
+		if cached != nil {
+			log.Printf("Weather fetch for %s failed, returning stale cache: %v", key, err)
+			return cached, cacheInfo{Age: age, Stale: true}, nil
+		}
+		return nil, cacheInfo{}, err
+	}
+
+	if err := SaveWeatherCache(key, fresh); err != nil {
+		log.Printf("Failed to save weather cache for %s: %v", key, err)
+		// Continue anyway, just log the error
+	}
+
+	return fresh, cacheInfo{Fresh: true}, nil
+}
+
+// refreshWeatherCached calls fetch and caches its payload under key, whatever
+// the age of the cached one. Upstream is still called at most once per
+// minimum fetch interval.
+func refreshWeatherCached[T any](key string, fetch func() (*T, error)) error {
+	unlock := weatherLimiter.lock(key)
+	defer unlock()
+
+	lastAttempt, _ := weatherLimiter.lastAttempt(key)
+	if time.Since(lastAttempt) < config.Weather.MinFetchInterval.Duration {
+		return nil
+	}
+
+	fresh, err := fetch()
+	weatherLimiter.recordAttempt(key, err)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+
+	return SaveWeatherCache(key, fresh)
+}
+
+// weatherRefreshJob returns the background job refreshing the cache key
+func weatherRefreshJob(key string) string {
+	if strings.HasPrefix(key, "air:") {
+		return RefreshAirQuality
+	}
+	return RefreshWeather
+}
+
+// writeCacheHeaders sets the X-Cache-* headers used by the cached endpoints
+func writeCacheHeaders(w http.ResponseWriter, info cacheInfo) {
+	switch {
+	case info.Fresh:
+		w.Header().Set("X-Cache-Fresh", "true")
+	case info.Stale:
+		w.Header().Set("X-Cache-Age", info.Age.Round(time.Second).String())
+		w.Header().Set("X-Cache-Stale", "true")
+	default:
+		w.Header().Set("X-Cache-Age", info.Age.Round(time.Second).String())
+	}
+}
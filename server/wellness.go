@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Check-in scale, as in the intervals.icu wellness form: 1 is the best
+// (e.g. no soreness, great mood) and 4 the worst
+const (
+	CheckInMin = 1
+	CheckInMax = 4
+)
+
+// WellnessCheckIn is the morning check-in sent by the touchscreen. Unset
+// values are left unchanged upstream.
+type WellnessCheckIn struct {
+	Fatigue      *int `json:"fatigue,omitempty"`
+	Soreness     *int `json:"soreness,omitempty"`
+	Stress       *int `json:"stress,omitempty"`
+	Mood         *int `json:"mood,omitempty"`
+	Motivation   *int `json:"motivation,omitempty"`
+	SleepQuality *int `json:"sleep_quality,omitempty"`
+}
+
+// fields returns the set values by intervals.icu wellness field name
+func (c WellnessCheckIn) fields() map[string]int {
+	fields := make(map[string]int)
+	for name, v := range map[string]*int{
+		"fatigue":      c.Fatigue,
+		"soreness":     c.Soreness,
+		"stress":       c.Stress,
+		"mood":         c.Mood,
+		"motivation":   c.Motivation,
+		"sleepQuality": c.SleepQuality,
+	} {
+		if v != nil {
+			fields[name] = *v
+		}
+	}
+	return fields
+}
+
+// Validate checks that at least one value is set and all are on the scale
+func (c WellnessCheckIn) Validate() error {
+	fields := c.fields()
+	if len(fields) == 0 {
+		return fmt.Errorf("check-in has no values")
+	}
+	for name, v := range fields {
+		if v < CheckInMin || v > CheckInMax {
+			return fmt.Errorf("%s must be between %d and %d", name, CheckInMin, CheckInMax)
+		}
+	}
+	return nil
+}
+
+// merge returns c with the values set in next replacing its own
+func (c WellnessCheckIn) merge(next WellnessCheckIn) WellnessCheckIn {
+	for _, pair := range []struct{ dst, src **int }{
+		{&c.Fatigue, &next.Fatigue},
+		{&c.Soreness, &next.Soreness},
+		{&c.Stress, &next.Stress},
+		{&c.Mood, &next.Mood},
+		{&c.Motivation, &next.Motivation},
+		{&c.SleepQuality, &next.SleepQuality},
+	} {
+		if *pair.src != nil {
+			*pair.dst = *pair.src
+		}
+	}
+	return c
+}
+
+// CheckInResponse is the response for the check-in endpoint
+type CheckInResponse struct {
+	Date   string `json:"date"`   // YYYY-MM-DD
+	Status string `json:"status"` // sent or queued
+	Error  string `json:"error,omitempty"`
+}
+
+// Check-in statuses
+const (
+	CheckInSent   = "sent"
+	CheckInQueued = "queued"
+)
+
+// UpdateWellness writes the check-in to the wellness record of date. The PUT
+// only changes the fields sent, so repeating it is harmless.
+func (c *intervalsClient) UpdateWellness(date string, checkIn WellnessCheckIn) error {
+	return c.put("/wellness/"+date, checkIn.fields())
+}
+
+// queueCheckIn stores a check-in until it is written upstream, merged with
+// any check-in still pending for the same athlete and date
+func queueCheckIn(athleteID, date string, checkIn WellnessCheckIn) (WellnessCheckIn, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return checkIn, err
+	}
+	defer tx.Rollback()
+
+	var payloadJSON string
+	err = tx.QueryRow(`SELECT payload_json FROM wellness_queue WHERE athlete_id = ? AND date = ?`,
+		athleteID, date).Scan(&payloadJSON)
+	if err == nil {
+		var pending WellnessCheckIn
+		if err := json.Unmarshal([]byte(payloadJSON), &pending); err != nil {
+			return checkIn, err
+		}
+		checkIn = pending.merge(checkIn)
+	}
+
+	payload, err := json.Marshal(checkIn)
+	if err != nil {
+		return checkIn, err
+	}
+	_, err = tx.Exec(`
+		INSERT OR REPLACE INTO wellness_queue (athlete_id, date, payload_json, attempts, last_error, queued_at)
+		VALUES (?, ?, ?, 0, '', ?)
+	`, athleteID, date, string(payload), time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return checkIn, err
+	}
+
+	return checkIn, tx.Commit()
+}
+
+// pendingCheckIn is a queued check-in
+type pendingCheckIn struct {
+	AthleteID string
+	Date      string
+	CheckIn   WellnessCheckIn
+}
+
+// pendingCheckIns returns the queued check-ins, oldest date first
+func pendingCheckIns() ([]pendingCheckIn, error) {
+	rows, err := db.Query(`SELECT athlete_id, date, payload_json FROM wellness_queue ORDER BY date, athlete_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pending []pendingCheckIn
+	for rows.Next() {
+		var (
+			p           pendingCheckIn
+			payloadJSON string
+		)
+		if err := rows.Scan(&p.AthleteID, &p.Date, &payloadJSON); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(payloadJSON), &p.CheckIn); err != nil {
+			return nil, err
+		}
+		pending = append(pending, p)
+	}
+	return pending, rows.Err()
+}
+
+// wellnessQueueMu serialises queue updates with sends, so a check-in merged
+// while an older one is in flight is not deleted with it
+var wellnessQueueMu sync.Mutex
+
+// sendCheckIn writes a queued check-in upstream and removes it from the queue.
+// A refused check-in is dropped too, retrying the same payload won't help;
+// otherwise it stays queued with the failure recorded.
+func sendCheckIn(client *intervalsClient, p pendingCheckIn) error {
+	err := client.UpdateWellness(p.Date, p.CheckIn)
+	switch kind := errorKind(err); {
+	case err == nil:
+		log.Printf("Wellness check-in for %s sent", p.Date)
+	case kind == ErrorRejected || kind == ErrorDecode:
+		log.Printf("Wellness check-in for %s refused, dropped: %v", p.Date, err)
+	default:
+		_, updateErr := db.Exec(`
+			UPDATE wellness_queue SET attempts = attempts + 1, last_error = ?
+			WHERE athlete_id = ? AND date = ?
+		`, err.Error(), p.AthleteID, p.Date)
+		return errors.Join(err, updateErr)
+	}
+
+	_, deleteErr := db.Exec(`DELETE FROM wellness_queue WHERE athlete_id = ? AND date = ?`, p.AthleteID, p.Date)
+	return errors.Join(err, deleteErr)
+}
+
+// flushWellnessQueue writes the queued check-ins of the configured athlete
+// upstream, oldest first. Check-ins queued without credentials are sent for
+// that athlete.
+func flushWellnessQueue() error {
+	wellnessQueueMu.Lock()
+	defer wellnessQueueMu.Unlock()
+
+	pending, err := pendingCheckIns()
+	if err != nil || len(pending) == 0 {
+		return err
+	}
+
+	client, err := intervalsProvider()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, p := range pending {
+		if p.AthleteID != "" && p.AthleteID != client.AthleteID() {
+			continue
+		}
+		if err := sendCheckIn(client, p); err != nil {
+			errs = append(errs, fmt.Errorf("check-in for %s: %w", p.Date, err))
+			// The next ones would most likely fail the same way
+			if errorKind(err) == ErrorTransient || errorKind(err) == ErrorAuth {
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// handleWellnessCheckIn writes a morning check-in to today's wellness record,
+// queuing it while intervals.icu is unavailable
+func handleWellnessCheckIn(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var checkIn WellnessCheckIn
+	if err := json.NewDecoder(r.Body).Decode(&checkIn); err != nil {
+		http.Error(w, "invalid check-in: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkIn.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !providesIntervals() {
+		writeNotSupported(w, "The wellness check-in")
+		return
+	}
+
+	// Without credentials the check-in waits for them in the queue
+	client, clientErr := intervalsProvider()
+	athleteID := ""
+	if clientErr == nil {
+		athleteID = client.AthleteID()
+	}
+
+	wellnessQueueMu.Lock()
+	defer wellnessQueueMu.Unlock()
+
+	// Queued first, so nothing is lost if the write fails; a check-in still
+	// pending for today is merged in and sent along
+	date := time.Now().Format("2006-01-02")
+	merged, err := queueCheckIn(athleteID, date, checkIn)
+	if err != nil {
+		log.Printf("Error queuing check-in: %v", err)
+		http.Error(w, "Failed to store check-in", http.StatusInternalServerError)
+		return
+	}
+
+	response := CheckInResponse{Date: date, Status: CheckInSent}
+	status := http.StatusOK
+	err = clientErr
+	if err == nil {
+		err = sendCheckIn(client, pendingCheckIn{AthleteID: athleteID, Date: date, CheckIn: merged})
+	}
+	if err != nil {
+		if kind := errorKind(err); kind == ErrorRejected || kind == ErrorDecode {
+			http.Error(w, "intervals.icu refused the check-in: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		log.Printf("Check-in for %s queued: %v", date, err)
+		response.Status = CheckInQueued
+		response.Error = err.Error()
+		status = http.StatusAccepted
+	}
+
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+	log.Printf("[%s] POST /api/intervals/wellness -> %s", time.Now().Format("15:04:05"), response.Status)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWellnessCheckInValidate(t *testing.T) {
+	one, four, five := 1, 4, 5
+	tests := []struct {
+		name    string
+		checkIn WellnessCheckIn
+		wantErr bool
+	}{
+		{"empty", WellnessCheckIn{}, true},
+		{"in range", WellnessCheckIn{Fatigue: &one, SleepQuality: &four}, false},
+		{"above scale", WellnessCheckIn{Mood: &five}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.checkIn.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	merged := WellnessCheckIn{Fatigue: &four, Mood: &four}.merge(WellnessCheckIn{Fatigue: &one})
+	if *merged.Fatigue != 1 || *merged.Mood != 4 || merged.Stress != nil {
+		t.Errorf("merged = %+v", merged.fields())
+	}
+}
+
+// wellnessUpstream is a fake intervals.icu wellness API for athlete "i42"
+// answering with status, recording the PUT bodies
+type wellnessUpstream struct {
+	status int
+	puts   []map[string]int
+}
+
+func useWellnessUpstream(t *testing.T) *wellnessUpstream {
+	t.Helper()
+	setupTestDB(t)
+
+	upstream := &wellnessUpstream{status: http.StatusOK}
+	today := time.Now().Format("2006-01-02")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/athlete/i42/wellness/"+today {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		var fields map[string]int
+		if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+			t.Error(err)
+		}
+		upstream.puts = append(upstream.puts, fields)
+		w.WriteHeader(upstream.status)
+	}))
+	t.Cleanup(server.Close)
+
+	client := newIntervalsClient(server.URL, "i42", "secret", server.Client())
+	client.retry = retryPolicy{Attempts: 1}
+
+	previous := intervalsAPI
+	intervalsAPI = client
+	t.Cleanup(func() { intervalsAPI = previous })
+
+	return upstream
+}
+
+func postCheckIn(t *testing.T, body string) (*httptest.ResponseRecorder, CheckInResponse) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handleWellnessCheckIn(rec, httptest.NewRequest("POST", "/api/intervals/wellness", strings.NewReader(body)))
+
+	var response CheckInResponse
+	if rec.Code == http.StatusOK || rec.Code == http.StatusAccepted {
+		if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rec, response
+}
+
+func TestHandleWellnessCheckIn(t *testing.T) {
+	upstream := useWellnessUpstream(t)
+
+	rec, response := postCheckIn(t, `{"fatigue": 2, "sleep_quality": 1}`)
+	if rec.Code != http.StatusOK || response.Status != CheckInSent {
+		t.Fatalf("status %d, response %+v", rec.Code, response)
+	}
+	if len(upstream.puts) != 1 || len(upstream.puts[0]) != 2 || upstream.puts[0]["sleepQuality"] != 1 {
+		t.Errorf("puts = %v", upstream.puts)
+	}
+	if pending, _ := pendingCheckIns(); len(pending) != 0 {
+		t.Errorf("sent check-in still queued: %+v", pending)
+	}
+
+	for _, body := range []string{`{}`, `{"mood": 0}`, `{"stress": 5}`, `not json`} {
+		if rec, _ := postCheckIn(t, body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", body, rec.Code)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	handleWellnessCheckIn(rec, httptest.NewRequest("GET", "/api/intervals/wellness", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status %d, want 405", rec.Code)
+	}
+}
+
+func TestWellnessCheckInQueue(t *testing.T) {
+	upstream := useWellnessUpstream(t)
+	upstream.status = http.StatusServiceUnavailable
+
+	// Check-ins are queued while upstream is down, the later values win
+	if rec, response := postCheckIn(t, `{"fatigue": 3, "mood": 2}`); rec.Code != http.StatusAccepted || response.Status != CheckInQueued {
+		t.Fatalf("status %d, response %+v", rec.Code, response)
+	}
+	postCheckIn(t, `{"fatigue": 2}`)
+
+	pending, err := pendingCheckIns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || *pending[0].CheckIn.Fatigue != 2 || *pending[0].CheckIn.Mood != 2 {
+		t.Fatalf("pending = %+v", pending)
+	}
+
+	// Once upstream is back the flush sends the merged check-in
+	upstream.status = http.StatusOK
+	if err := flushWellnessQueue(); err != nil {
+		t.Fatal(err)
+	}
+	last := upstream.puts[len(upstream.puts)-1]
+	if len(upstream.puts) != 3 || last["fatigue"] != 2 || last["mood"] != 2 {
+		t.Errorf("puts = %v", upstream.puts)
+	}
+	if pending, _ := pendingCheckIns(); len(pending) != 0 {
+		t.Errorf("flushed check-in still queued: %+v", pending)
+	}
+
+	// A refused check-in is dropped instead of retried forever
+	upstream.status = http.StatusUnprocessableEntity
+	if rec, _ := postCheckIn(t, `{"soreness": 1}`); rec.Code != http.StatusBadGateway {
+		t.Errorf("refused: status %d, want 502", rec.Code)
+	}
+	if pending, _ := pendingCheckIns(); len(pending) != 0 {
+		t.Errorf("refused check-in still queued: %+v", pending)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+// Weather condition categories, matching the icon families
+const (
+	CategoryClear        = "clear"
+	CategoryPartlyCloudy = "partly_cloudy"
+	CategoryCloudy       = "cloudy"
+	CategoryFog          = "fog"
+	CategoryDrizzle      = "drizzle"
+	CategoryRain         = "rain"
+	CategorySnow         = "snow"
+	CategoryShowers      = "showers"
+	CategoryStorm        = "storm"
+	CategoryUnknown      = "unknown"
+)
+
+// Precipitation intensities
+const (
+	IntensityLight    = "light"
+	IntensityModerate = "moderate"
+	IntensityHeavy    = "heavy"
+)
+
+// WeatherCondition describes a WMO 4677 weather code (as used by Open-Meteo)
+type WeatherCondition struct {
+	Code      int
+	Category  string
+	Intensity string // Empty for codes without precipitation
+	Freezing  bool
+	Label     string // Of the category, as the device firmware has always shown it
+	Short     string // Fits the device screen, at most 12 characters
+	Long      string
+
+	// Labels used at night, when they differ
+	ShortNight string
+	LongNight  string
+}
+
+// categoryLabels are the condition labels of the device firmware, one per
+// category: "Cloudy" for both partly cloudy and overcast skies
+var categoryLabels = map[string]string{
+	CategoryClear:        "Clear",
+	CategoryPartlyCloudy: "Cloudy",
+	CategoryCloudy:       "Cloudy",
+	CategoryFog:          "Fog",
+	CategoryDrizzle:      "Drizzle",
+	CategoryRain:         "Rain",
+	CategorySnow:         "Snow",
+	CategoryShowers:      "Showers",
+	CategoryStorm:        "Storm",
+	CategoryUnknown:      "Unknown",
+}
+
+// wmoCodes is the full WMO 4677 table for the codes Open-Meteo reports
+var wmoCodes = map[int]WeatherCondition{
+	0:  {Category: CategoryClear, Short: "Sunny", Long: "Clear sky", ShortNight: "Clear", LongNight: "Clear night"},
+	1:  {Category: CategoryPartlyCloudy, Short: "Fair", Long: "Mainly sunny", LongNight: "Mainly clear"},
+	2:  {Category: CategoryPartlyCloudy, Short: "Pt cloudy", Long: "Partly cloudy"},
+	3:  {Category: CategoryCloudy, Short: "Overcast", Long: "Overcast"},
+	45: {Category: CategoryFog, Short: "Fog", Long: "Fog"},
+	48: {Category: CategoryFog, Freezing: true, Short: "Rime fog", Long: "Depositing rime fog"},
+	51: {Category: CategoryDrizzle, Intensity: IntensityLight, Short: "Lt drizzle", Long: "Light drizzle"},
+	53: {Category: CategoryDrizzle, Intensity: IntensityModerate, Short: "Drizzle", Long: "Moderate drizzle"},
+	55: {Category: CategoryDrizzle, Intensity: IntensityHeavy, Short: "Hvy drizzle", Long: "Dense drizzle"},
+	56: {Category: CategoryDrizzle, Intensity: IntensityLight, Freezing: true, Short: "Frz drizzle", Long: "Light freezing drizzle"},
+	57: {Category: CategoryDrizzle, Intensity: IntensityHeavy, Freezing: true, Short: "Frz drizzle", Long: "Dense freezing drizzle"},
+	61: {Category: CategoryRain, Intensity: IntensityLight, Short: "Lt rain", Long: "Light rain"},
+	63: {Category: CategoryRain, Intensity: IntensityModerate, Short: "Rain", Long: "Moderate rain"},
+	65: {Category: CategoryRain, Intensity: IntensityHeavy, Short: "Hvy rain", Long: "Heavy rain"},
+	66: {Category: CategoryRain, Intensity: IntensityLight, Freezing: true, Short: "Frz rain", Long: "Light freezing rain"},
+	67: {Category: CategoryRain, Intensity: IntensityHeavy, Freezing: true, Short: "Hvy frz rain", Long: "Heavy freezing rain"},
+	71: {Category: CategorySnow, Intensity: IntensityLight, Short: "Lt snow", Long: "Light snowfall"},
+	73: {Category: CategorySnow, Intensity: IntensityModerate, Short: "Snow", Long: "Moderate snowfall"},
+	75: {Category: CategorySnow, Intensity: IntensityHeavy, Short: "Hvy snow", Long: "Heavy snowfall"},
+	77: {Category: CategorySnow, Intensity: IntensityLight, Short: "Snow grains", Long: "Snow grains"},
+	80: {Category: CategoryShowers, Intensity: IntensityLight, Short: "Lt showers", Long: "Light rain showers"},
+	81: {Category: CategoryShowers, Intensity: IntensityModerate, Short: "Showers", Long: "Moderate rain showers"},
+	82: {Category: CategoryShowers, Intensity: IntensityHeavy, Short: "Hvy showers", Long: "Violent rain showers"},
+	85: {Category: CategorySnow, Intensity: IntensityLight, Short: "Snow shwrs", Long: "Light snow showers"},
+	86: {Category: CategorySnow, Intensity: IntensityHeavy, Short: "Hvy snow", Long: "Heavy snow showers"},
+	95: {Category: CategoryStorm, Intensity: IntensityModerate, Short: "Storm", Long: "Thunderstorm"},
+	96: {Category: CategoryStorm, Intensity: IntensityModerate, Short: "Storm+hail", Long: "Thunderstorm with light hail"},
+	99: {Category: CategoryStorm, Intensity: IntensityHeavy, Short: "Storm+hail", Long: "Thunderstorm with heavy hail"},
+}
+
+// describeWeatherCode returns the condition for a WMO code, with the labels
+// resolved for day or night
+func describeWeatherCode(code int, isDay bool) WeatherCondition {
+	condition, ok := wmoCodes[code]
+	if !ok {
+		return WeatherCondition{Code: code, Category: CategoryUnknown, Label: "Unknown", Short: "Unknown", Long: "Unknown"}
+	}
+
+	condition.Code = code
+	condition.Label = categoryLabels[condition.Category]
+	if !isDay {
+		if condition.ShortNight != "" {
+			condition.Short = condition.ShortNight
+		}
+		if condition.LongNight != "" {
+			condition.Long = condition.LongNight
+		}
+	}
+	condition.ShortNight, condition.LongNight = "", ""
+
+	return condition
+}
@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+func TestDescribeWeatherCode(t *testing.T) {
+	tests := []struct {
+		code      int
+		isDay     bool
+		short     string
+		long      string
+		category  string
+		intensity string
+		freezing  bool
+	}{
+		{0, true, "Sunny", "Clear sky", CategoryClear, "", false},
+		{0, false, "Clear", "Clear night", CategoryClear, "", false},
+		{1, false, "Fair", "Mainly clear", CategoryPartlyCloudy, "", false},
+		{3, true, "Overcast", "Overcast", CategoryCloudy, "", false},
+		{56, true, "Frz drizzle", "Light freezing drizzle", CategoryDrizzle, IntensityLight, true},
+		{65, true, "Hvy rain", "Heavy rain", CategoryRain, IntensityHeavy, false},
+		{67, true, "Hvy frz rain", "Heavy freezing rain", CategoryRain, IntensityHeavy, true},
+		{77, true, "Snow grains", "Snow grains", CategorySnow, IntensityLight, false},
+		{85, false, "Snow shwrs", "Light snow showers", CategorySnow, IntensityLight, false},
+		{99, true, "Storm+hail", "Thunderstorm with heavy hail", CategoryStorm, IntensityHeavy, false},
+		{42, true, "Unknown", "Unknown", CategoryUnknown, "", false},
+	}
+
+	for _, tt := range tests {
+		c := describeWeatherCode(tt.code, tt.isDay)
+		if c.Code != tt.code || c.Short != tt.short || c.Long != tt.long || c.Category != tt.category ||
+			c.Intensity != tt.intensity || c.Freezing != tt.freezing {
+			t.Errorf("describeWeatherCode(%d, %v) = %+v", tt.code, tt.isDay, c)
+		}
+	}
+}
+
+func TestWMOShortLabels(t *testing.T) {
+	// Short labels are drawn next to the temperature on the device
+	for code := range wmoCodes {
+		for _, isDay := range []bool{true, false} {
+			if c := describeWeatherCode(code, isDay); len(c.Short) > 12 {
+				t.Errorf("code %d short label %q is too long", code, c.Short)
+			}
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ZoneWeekDays is the window of the weekly time-in-zone, ending on the day
+const ZoneWeekDays = 7
+
+// ZoneBreakdown is the share of time spent in each zone, as whole
+// percentages summing to 100 so the device can stack them into a bar
+type ZoneBreakdown struct {
+	Power        []int   `json:"power,omitempty"` // Z1, Z2... of the power zones
+	HR           []int   `json:"hr,omitempty"`    // Z1, Z2... of the heart rate zones
+	Polarization float64 `json:"pi,omitempty"`    // Polarisation index, above 2 is polarised
+}
+
+// zonePercentages normalises seconds per zone to whole percentages summing to
+// 100, giving the rounding remainders to the largest fractions. Returns nil
+// without any time.
+func zonePercentages(secs []float64) []int {
+	var total float64
+	for _, s := range secs {
+		total += max(s, 0)
+	}
+	if total == 0 {
+		return nil
+	}
+
+	percentages := make([]int, len(secs))
+	fractions := make([]float64, len(secs))
+	order := make([]int, len(secs))
+	left := 100
+	for i, s := range secs {
+		exact := max(s, 0) / total * 100
+		percentages[i] = int(exact)
+		fractions[i] = exact - float64(percentages[i])
+		order[i] = i
+		left -= percentages[i]
+	}
+	sort.SliceStable(order, func(a, b int) bool { return fractions[order[a]] > fractions[order[b]] })
+	for _, i := range order[:left] {
+		percentages[i]++
+	}
+	return percentages
+}
+
+// powerZoneSecs returns the seconds spent in each numbered power zone (Z1,
+// Z2...). Overlapping zones such as the sweet spot ("SS") are left out.
+func powerZoneSecs(a Activity) []float64 {
+	var secs []float64
+	for _, z := range a.IcuZoneTimes {
+		n, err := strconv.Atoi(strings.TrimPrefix(z.ID, "Z"))
+		if !strings.HasPrefix(z.ID, "Z") || err != nil || n < 1 {
+			continue
+		}
+		for len(secs) < n {
+			secs = append(secs, 0)
+		}
+		secs[n-1] += z.Secs
+	}
+	return secs
+}
+
+// polarizationIndex computes Treff's polarisation index from the seconds per
+// power zone, with Z1-Z2 as low, Z3 as threshold and Z4 and above as high
+// intensity. Returns 0 without low or high intensity, the index is undefined
+// then.
+func polarizationIndex(secs []float64) float64 {
+	var low, mid, high, total float64
+	for i, s := range secs {
+		switch {
+		case i < 2:
+			low += s
+		case i == 2:
+			mid += s
+		default:
+			high += s
+		}
+		total += s
+	}
+	if low == 0 || high == 0 {
+		return 0
+	}
+
+	// Treff et al. use 0.01 for a threshold zone without time
+	f1, f2, f3 := low/total, max(mid/total, 0.01), high/total
+	return math.Round(math.Log10(f1/f2*f3*100)*100) / 100
+}
+
+// activityZones returns the time-in-zone of an activity, nil without zone data
+func activityZones(a TrainingActivity) *ZoneBreakdown {
+	zones := &ZoneBreakdown{
+		Power:        zonePercentages(a.PowerZoneSecs),
+		HR:           zonePercentages(a.HRZoneSecs),
+		Polarization: math.Round(a.Polarization*100) / 100,
+	}
+	if zones.Power == nil && zones.HR == nil {
+		return nil
+	}
+	return zones
+}
+
+// weeklyZones sums the time-in-zone of the activities of the ZoneWeekDays days
+// ending on date (YYYY-MM-DD). Returns nil without zone data.
+func weeklyZones(activities []TrainingActivity, date string) *ZoneBreakdown {
+	end, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return nil
+	}
+	start := end.AddDate(0, 0, 1-ZoneWeekDays).Format("2006-01-02")
+
+	var power, hr []float64
+	add := func(total []float64, secs []float64) []float64 {
+		for i, s := range secs {
+			if i == len(total) {
+				total = append(total, 0)
+			}
+			total[i] += s
+		}
+		return total
+	}
+	for _, a := range activities {
+		if day := localDate(a.StartDateLocal); day < start || day > date {
+			continue
+		}
+		power = add(power, a.PowerZoneSecs)
+		hr = add(hr, a.HRZoneSecs)
+	}
+
+	zones := &ZoneBreakdown{
+		Power:        zonePercentages(power),
+		HR:           zonePercentages(hr),
+		Polarization: polarizationIndex(power),
+	}
+	if zones.Power == nil && zones.HR == nil {
+		return nil
+	}
+	return zones
+}
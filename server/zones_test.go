@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestZonePercentages(t *testing.T) {
+	tests := []struct {
+		secs []float64
+		want string
+	}{
+		{[]float64{1, 1, 1}, "[34 33 33]"},
+		{[]float64{600, 1200, 900, 1500, 300, 12, 0}, "[13 27 20 33 7 0 0]"},
+		{[]float64{0, 3600}, "[0 100]"},
+		{[]float64{0, 0}, "[]"},
+		{nil, "[]"},
+	}
+	for _, tt := range tests {
+		got := zonePercentages(tt.secs)
+		if fmt.Sprint(got) != tt.want {
+			t.Errorf("zonePercentages(%v) = %v, want %s", tt.secs, got, tt.want)
+		}
+		sum := 0
+		for _, p := range got {
+			sum += p
+		}
+		if got != nil && sum != 100 {
+			t.Errorf("zonePercentages(%v) sums to %d", tt.secs, sum)
+		}
+	}
+}
+
+func TestPolarizationIndex(t *testing.T) {
+	tests := []struct {
+		secs []float64
+		want float64
+	}{
+		// 80% low, 5% threshold, 15% high: polarised
+		{[]float64{4000, 4000, 500, 1000, 500}, 2.38},
+		// 30% threshold: below 2, not polarised
+		{[]float64{3000, 2000, 3000, 1000, 1000}, 1.52},
+		// No threshold time counts as 1%
+		{[]float64{8000, 0, 0, 2000}, 3.2},
+		{[]float64{3600}, 0},
+		// A test ride without low intensity would be -Inf
+		{[]float64{0, 0, 600, 1200}, 0},
+		{nil, 0},
+	}
+	for _, tt := range tests {
+		if got := polarizationIndex(tt.secs); got != tt.want {
+			t.Errorf("polarizationIndex(%v) = %v, want %v", tt.secs, got, tt.want)
+		}
+	}
+}
+
+func TestWeeklyZones(t *testing.T) {
+	ride := func(date string, z1, z4 float64) TrainingActivity {
+		var a Activity
+		err := json.Unmarshal([]byte(fmt.Sprintf(`{"start_date_local": "%sT10:00:00",
+			"icu_zone_times": [{"id": "Z1", "secs": %v}, {"id": "Z4", "secs": %v}],
+			"icu_hr_zone_times": [%v, %v]}`, date, z1, z4, z1, z4)), &a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a.trainingActivity()
+	}
+	activities := []TrainingActivity{
+		ride("2026-02-08", 3000, 1000), // After the day
+		ride("2026-02-02", 3000, 1000),
+		ride("2026-01-27", 1000, 3000),
+		ride("2026-01-26", 3000, 1000), // Before the week
+	}
+
+	week := weeklyZones(activities, "2026-02-02")
+	if week == nil || fmt.Sprint(week.Power) != "[50 0 0 50]" || fmt.Sprint(week.HR) != "[50 50]" {
+		t.Errorf("week = %+v", week)
+	}
+	if week := weeklyZones(activities[:1], "2026-02-02"); week != nil {
+		t.Errorf("week without activities = %+v", week)
+	}
+}